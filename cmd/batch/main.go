@@ -5,12 +5,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"upload-util/internal/progress"
 	"upload-util/pkg/upload"
 )
 
@@ -22,6 +23,8 @@ var (
 
 type UploadTask struct {
 	FilePath string
+	// Key 同步模式下由相对路径生成的对象键，为空时按文件名生成
+	Key      string
 	Result   *upload.UploadResult
 	Error    error
 	Duration time.Duration
@@ -30,12 +33,23 @@ type UploadTask struct {
 func main() {
 	var (
 		configPath = flag.String("config", "config.yaml", "配置文件路径")
+		profile    = flag.String("profile", "", "使用配置文件中 profiles 下的命名配置")
 		directory  = flag.String("dir", "", "要上传的目录路径")
 		pattern    = flag.String("pattern", "*", "文件匹配模式 (支持 *.jpg, *.png 等)")
 		recursive  = flag.Bool("r", false, "递归遍历子目录")
 		concurrent = flag.Int("c", 3, "并发上传数量")
-		dryRun     = flag.Bool("dry-run", false, "试运行，只显示将要上传的文件")
+		dryRun     = flag.Bool("dry-run", false, "试运行，只显示将要上传的文件 (同步模式下输出同步计划)")
+		syncMode   = flag.Bool("sync", false, "同步模式：按相对路径映射对象键，只上传新增或变化的文件")
+		prefix     = flag.String("prefix", "", "同步模式下远程对象键的前缀")
+		checksum   = flag.Bool("checksum", false, "同步模式下远程 ETag 不是 MD5 时读取远程内容比较 MD5，而不是比较修改时间")
+		deleteMode = flag.Bool("delete", false, "同步模式下删除本地已不存在的远程文件")
+		user       = flag.String("user", "", "上传者标识，用于 key-template 中的 {user}")
+		retries    = flag.Int("retries", 0, "遇到临时性错误时的最大尝试次数，默认使用配置文件中的 retry 设置，1 表示不重试")
+		bwLimit    = flag.String("bwlimit", "", "所有并发上传共享的带宽上限，如 2M 表示 2 MB/s，支持 K、M、G 后缀")
+		fileLimit  = flag.String("bwlimit-file", "", "单个文件的带宽上限，格式同 -bwlimit")
+		opsLimit   = flag.Float64("ops", 0, "每秒请求存储的次数上限 (上传、删除、列举)")
 		verbose    = flag.Bool("v", false, "详细输出")
+		noProgress = flag.Bool("no-progress", false, "不显示每个文件的进度条")
 		version    = flag.Bool("version", false, "显示版本信息")
 	)
 	flag.Parse()
@@ -52,6 +66,8 @@ func main() {
 		fmt.Println("  批量上传: batch-upload -dir=./photos -pattern='*.jpg' -c=5")
 		fmt.Println("  递归上传: batch-upload -dir=./docs -pattern='*.pdf' -r")
 		fmt.Println("  试运行:   batch-upload -dir=./files -dry-run")
+		fmt.Println("  指定配置: batch-upload -dir=./exports -profile=exports")
+		fmt.Println("  目录同步: batch-upload -dir=./site -r -sync -prefix=site/ -delete -dry-run")
 		os.Exit(1)
 	}
 
@@ -64,7 +80,8 @@ func main() {
 		log.Fatalf("查找文件失败: %v", err)
 	}
 
-	if len(files) == 0 {
+	// 同步模式下本地没有文件时仍需要删除远程文件
+	if len(files) == 0 && !(*syncMode && *deleteMode) {
 		fmt.Printf("📁 目录: %s\n", *directory)
 		fmt.Printf("🔍 模式: %s\n", *pattern)
 		fmt.Printf("📄 没有找到匹配的文件\n")
@@ -75,7 +92,7 @@ func main() {
 	fmt.Printf("🔍 模式: %s\n", *pattern)
 	fmt.Printf("📄 找到 %d 个文件\n", len(files))
 
-	if *dryRun {
+	if *dryRun && !*syncMode {
 		fmt.Println("\n🔍 试运行模式 - 将要上传的文件:")
 		for i, file := range files {
 			stat, _ := os.Stat(file)
@@ -89,6 +106,31 @@ func main() {
 	if err != nil {
 		log.Fatalf("❌ 加载配置失败: %v", err)
 	}
+	if *retries > 0 {
+		cfg.UploadSettings.Retry.MaxAttempts = *retries
+	}
+	rateLimit, err := parseRateLimit(*bwLimit, *fileLimit, *opsLimit)
+	if err != nil {
+		log.Fatalf("❌ 参数错误: %v", err)
+	}
+	cfg, err = cfg.Profile(*profile)
+	if err != nil {
+		log.Fatalf("❌ 加载配置失败: %v", err)
+	}
+	if *retries > 0 {
+		// profile 自己的 retry 配置覆盖顶层配置时同样使用命令行指定的次数
+		cfg.UploadSettings.Retry.MaxAttempts = *retries
+	}
+	// 限速只作用于最外层的上传器，命令行参数覆盖配置文件中的对应项
+	if rateLimit.Bandwidth > 0 {
+		cfg.UploadSettings.RateLimit.Bandwidth = rateLimit.Bandwidth
+	}
+	if rateLimit.PerUploadBandwidth > 0 {
+		cfg.UploadSettings.RateLimit.PerUploadBandwidth = rateLimit.PerUploadBandwidth
+	}
+	if rateLimit.OpsPerSecond > 0 {
+		cfg.UploadSettings.RateLimit.OpsPerSecond = rateLimit.OpsPerSecond
+	}
 
 	// 创建上传器
 	uploader, err := upload.NewUploader(cfg)
@@ -96,11 +138,24 @@ func main() {
 		log.Fatalf("❌ 创建上传器失败: %v", err)
 	}
 
+	ctx := context.Background()
+	opts := []upload.UploadOption{upload.WithUser(*user)}
+	if *syncMode {
+		runSync(ctx, uploader, *directory, files, syncPrefix(*prefix), *checksum, *deleteMode, *dryRun, *concurrent, *verbose, !*noProgress, opts)
+		return
+	}
+
 	fmt.Printf("🚀 开始批量上传 (并发: %d)...\n\n", *concurrent)
 
 	// 批量上传
 	start := time.Now()
-	results := batchUpload(context.Background(), uploader, files, *concurrent, *verbose)
+	tasks := make([]UploadTask, len(files))
+	for i, file := range files {
+		tasks[i] = UploadTask{FilePath: file}
+	}
+	results := batchUpload(ctx, uploader, tasks, *concurrent, *verbose, !*noProgress, opts)
+	// 等待 mirror async 策略的副本复制完成
+	uploader.Wait()
 	duration := time.Since(start)
 
 	// 打印结果
@@ -152,10 +207,18 @@ func findFiles(directory, pattern string, recursive bool) ([]string, error) {
 	}
 }
 
-func batchUpload(ctx context.Context, uploader upload.Uploader, files []string, concurrent int, verbose bool) []UploadTask {
-	tasks := make(chan string, len(files))
+// batchUpload 并发上传文件，showProgress 时在终端底部显示各文件的进度条和汇总进度
+func batchUpload(ctx context.Context, uploader upload.Uploader, files []UploadTask, concurrent int, verbose, showProgress bool, opts []upload.UploadOption) []UploadTask {
+	tasks := make(chan UploadTask, len(files))
 	results := make(chan UploadTask, len(files))
 
+	var bars *progress.Bars
+	if showProgress {
+		bars = progress.New(os.Stdout)
+		defer bars.Stop()
+		bars.SetStatus("⏳ 进度: 0/%d (0.0%%)", len(files))
+	}
+
 	for _, file := range files {
 		tasks <- file
 	}
@@ -165,8 +228,8 @@ func batchUpload(ctx context.Context, uploader upload.Uploader, files []string,
 		wg.Add(1)
 		go func(workerID int) {
 			defer wg.Done()
-			for filePath := range tasks {
-				result := uploadSingleFile(ctx, uploader, filePath, verbose, workerID)
+			for task := range tasks {
+				result := uploadSingleFile(ctx, uploader, task, verbose, workerID, bars, opts)
 				results <- result
 			}
 		}(i + 1)
@@ -184,23 +247,36 @@ func batchUpload(ctx context.Context, uploader upload.Uploader, files []string,
 		allResults = append(allResults, result)
 		completed++
 
-		if !verbose {
+		switch {
+		case bars != nil:
+			bars.SetStatus("⏳ 进度: %d/%d (%.1f%%)", completed, total, float64(completed)/float64(total)*100)
+		case !verbose:
 			// 显示进度
 			fmt.Printf("\r⏳ 进度: %d/%d (%.1f%%)", completed, total, float64(completed)/float64(total)*100)
 		}
 	}
 
-	if !verbose {
+	if bars == nil && !verbose {
 		fmt.Printf("\r") // 清除进度行
 	}
 
 	return allResults
-
 }
 
-func uploadSingleFile(ctx context.Context, uploader upload.Uploader, filePath string, verbose bool, workerID int) UploadTask {
+// uploadSingleFile 上传单个文件，bars 不为空时显示该文件的进度条，详细输出打印在进度条上方
+func uploadSingleFile(ctx context.Context, uploader upload.Uploader, task UploadTask, verbose bool, workerID int, bars *progress.Bars, opts []upload.UploadOption) UploadTask {
 	start := time.Now()
-	task := UploadTask{FilePath: filePath}
+	filePath := task.FilePath
+	opts = opts[:len(opts):len(opts)]
+	if task.Key != "" {
+		opts = append(opts, upload.WithKey(task.Key))
+	}
+	printf := func(format string, args ...any) {
+		fmt.Printf(format, args...)
+	}
+	if bars != nil {
+		printf = bars.Printf
+	}
 
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -222,25 +298,25 @@ func uploadSingleFile(ctx context.Context, uploader upload.Uploader, filePath st
 		return task
 	}
 
-	header := &multipart.FileHeader{
-		Filename: filepath.Base(filePath),
-		Size:     stat.Size(),
-	}
-
 	if verbose {
-		fmt.Printf("[Worker %d] ⏳ 上传: %s (%s)\n", workerID, filePath, formatFileSize(stat.Size()))
+		printf("[Worker %d] ⏳ 上传: %s (%s)\n", workerID, filePath, formatFileSize(stat.Size()))
+	}
+	if bars != nil {
+		bar := bars.Add(filepath.Base(filePath), stat.Size())
+		defer bars.Remove(bar)
+		opts = append(opts, upload.WithProgress(bar.Update))
 	}
 
-	result, err := uploader.Upload(ctx, file, header)
+	result, err := uploader.UploadReader(ctx, file, filepath.Base(filePath), stat.Size(), opts...)
 	task.Result = result
 	task.Error = err
 	task.Duration = time.Since(start)
 
 	if verbose {
 		if err != nil {
-			fmt.Printf("[Worker %d] ❌ 失败: %s - %v\n", workerID, filePath, err)
+			printf("[Worker %d] ❌ 失败: %s - %v\n", workerID, filePath, err)
 		} else {
-			fmt.Printf("[Worker %d] ✅ 完成: %s -> %s (%.2fs)\n", workerID, filepath.Base(filePath), result.URL, task.Duration.Seconds())
+			printf("[Worker %d] ✅ 完成: %s -> %s (%.2fs%s)\n", workerID, filepath.Base(filePath), result.URL, task.Duration.Seconds(), formatAttempts(result.Attempts))
 		}
 	}
 
@@ -248,7 +324,7 @@ func uploadSingleFile(ctx context.Context, uploader upload.Uploader, filePath st
 }
 
 func printBatchResults(results []UploadTask, totalDuration time.Duration) {
-	var successCount, failCount int
+	var successCount, failCount, dedupCount, retriedCount int
 	var totalSize, uploadedSize int64
 	var totalUploadTime time.Duration
 
@@ -264,9 +340,20 @@ func printBatchResults(results []UploadTask, totalDuration time.Duration) {
 		if task.Error != nil {
 			fmt.Printf("❌ %s\n   错误: %v\n", task.FilePath, task.Error)
 			failCount++
+		} else if task.Result.Deduplicated {
+			// 内容相同的文件已存在，未实际上传
+			fmt.Printf("♻️  %s (已存在，跳过)\n   URL: %s\n   Key: %s\n",
+				filepath.Base(task.FilePath), task.Result.URL, task.Result.Key)
+			successCount++
+			dedupCount++
+			totalUploadTime += task.Duration
 		} else {
 			fmt.Printf("✅ %s\n   URL: %s\n   Key: %s\n",
 				filepath.Base(task.FilePath), task.Result.URL, task.Result.Key)
+			if task.Result.Attempts > 1 {
+				fmt.Printf("   重试: 第 %d 次尝试成功\n", task.Result.Attempts)
+				retriedCount++
+			}
 			successCount++
 			uploadedSize += task.Result.Size
 			totalUploadTime += task.Duration
@@ -279,6 +366,12 @@ func printBatchResults(results []UploadTask, totalDuration time.Duration) {
 	fmt.Printf("   总文件数: %d\n", len(results))
 	fmt.Printf("   成功上传: %d\n", successCount)
 	fmt.Printf("   失败数量: %d\n", failCount)
+	if dedupCount > 0 {
+		fmt.Printf("   去重跳过: %d\n", dedupCount)
+	}
+	if retriedCount > 0 {
+		fmt.Printf("   重试成功: %d\n", retriedCount)
+	}
 	fmt.Printf("   总大小: %s\n", formatFileSize(totalSize))
 	fmt.Printf("   上传大小: %s\n", formatFileSize(uploadedSize))
 	fmt.Printf("   总耗时: %.2f 秒\n", totalDuration.Seconds())
@@ -298,6 +391,57 @@ func printBatchResults(results []UploadTask, totalDuration time.Duration) {
 	}
 }
 
+// parseRateLimit 解析命令行中的带宽和请求频率限制
+func parseRateLimit(bandwidth, perFile string, ops float64) (upload.RateLimitConfig, error) {
+	var rateLimit upload.RateLimitConfig
+	var err error
+	if rateLimit.Bandwidth, err = parseBandwidth(bandwidth); err != nil {
+		return rateLimit, fmt.Errorf("-bwlimit: %w", err)
+	}
+	if rateLimit.PerUploadBandwidth, err = parseBandwidth(perFile); err != nil {
+		return rateLimit, fmt.Errorf("-bwlimit-file: %w", err)
+	}
+	if ops < 0 {
+		return rateLimit, fmt.Errorf("-ops must not be negative")
+	}
+	rateLimit.OpsPerSecond = ops
+	return rateLimit, nil
+}
+
+// parseBandwidth 解析每秒字节数，支持 K、M、G 后缀 (1024 进制)，为空时返回 0
+func parseBandwidth(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSuffix(strings.TrimSpace(value), "/s"))
+	if value == "" {
+		return 0, nil
+	}
+	value = strings.TrimSuffix(value, "B")
+	unit := int64(1)
+	switch {
+	case strings.HasSuffix(value, "K"):
+		unit = 1024
+	case strings.HasSuffix(value, "M"):
+		unit = 1024 * 1024
+	case strings.HasSuffix(value, "G"):
+		unit = 1024 * 1024 * 1024
+	}
+	if unit > 1 {
+		value = value[:len(value)-1]
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth: %q", value)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// formatAttempts 重试后成功时显示尝试次数
+func formatAttempts(attempts int) string {
+	if attempts <= 1 {
+		return ""
+	}
+	return fmt.Sprintf(", 第 %d 次尝试", attempts)
+}
+
 func formatFileSize(bytes int64) string {
 	const (
 		KB = 1024
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"upload-util/pkg/upload"
+)
+
+// 同步计划中的操作，与 rsync 的输出类似
+const (
+	syncAdd    = "+"
+	syncUpdate = "~"
+	syncDelete = "-"
+)
+
+// syncAction 同步计划中的一项
+type syncAction struct {
+	Op     string
+	Key    string
+	Path   string // 本地文件，删除时为空
+	Size   int64
+	Reason string
+}
+
+// syncPrefix 规范化远程前缀，非空时以 / 结尾
+func syncPrefix(prefix string) string {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// syncKey 将本地文件相对于目录的路径映射为远程对象键
+func syncKey(directory, file, prefix string) (string, error) {
+	rel, err := filepath.Rel(directory, file)
+	if err != nil {
+		return "", err
+	}
+	return prefix + filepath.ToSlash(rel), nil
+}
+
+// listRemote 列举前缀下的所有远程对象
+func listRemote(ctx context.Context, uploader upload.Uploader, prefix string) (map[string]upload.ObjectInfo, error) {
+	objects := make(map[string]upload.ObjectInfo)
+	cursor := ""
+	for {
+		page, err := uploader.List(ctx, prefix, "", cursor, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Objects {
+			objects[object.Key] = object
+		}
+		if !page.Truncated {
+			return objects, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// buildSyncPlan 对比本地文件和远程对象，返回需要上传和删除的文件，以及未变化的文件数量，
+// 判断文件是否变化见 syncChanged
+func buildSyncPlan(ctx context.Context, uploader upload.Uploader, directory string, files []string, prefix string, checksum, deleteRemote bool) ([]syncAction, int, error) {
+	remote, err := listRemote(ctx, uploader, prefix)
+	if err != nil {
+		return nil, 0, fmt.Errorf("列举远程文件失败: %w", err)
+	}
+
+	var plan []syncAction
+	unchanged := 0
+	local := make(map[string]bool, len(files))
+	for _, file := range files {
+		key, err := syncKey(directory, file, prefix)
+		if err != nil {
+			return nil, 0, err
+		}
+		local[key] = true
+		stat, err := os.Stat(file)
+		if err != nil {
+			return nil, 0, err
+		}
+		object, ok := remote[key]
+		if !ok {
+			plan = append(plan, syncAction{Op: syncAdd, Key: key, Path: file, Size: stat.Size(), Reason: "new"})
+			continue
+		}
+		reason, err := syncChanged(ctx, uploader, file, stat, object, checksum)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", file, err)
+		}
+		if reason == "" {
+			unchanged++
+			continue
+		}
+		plan = append(plan, syncAction{Op: syncUpdate, Key: key, Path: file, Size: stat.Size(), Reason: reason})
+	}
+
+	if deleteRemote {
+		var deleted []syncAction
+		for key, object := range remote {
+			if !local[key] {
+				deleted = append(deleted, syncAction{Op: syncDelete, Key: key, Size: object.Size, Reason: "deleted locally"})
+			}
+		}
+		sort.Slice(deleted, func(i, j int) bool { return deleted[i].Key < deleted[j].Key })
+		plan = append(plan, deleted...)
+	}
+	return plan, unchanged, nil
+}
+
+// syncChanged 返回本地文件需要重新上传的原因，未变化时返回空。先比较大小，
+// 远程 ETag 是 MD5 时直接与本地文件的 MD5 比较；否则默认比较修改时间，checksum 时读取远程内容计算 MD5
+func syncChanged(ctx context.Context, uploader upload.Uploader, file string, stat os.FileInfo, object upload.ObjectInfo, checksum bool) (string, error) {
+	if stat.Size() != object.Size {
+		return "size", nil
+	}
+	remoteSum, isMD5 := upload.ETagMD5(object.ETag)
+	if !isMD5 && !checksum {
+		// 本地修改时间与存储的时钟比较，时钟有偏差或复制时保留了修改时间 (cp -p、rsync -t) 会漏掉修改
+		if stat.ModTime().After(object.LastModified) {
+			return "mtime", nil
+		}
+		return "", nil
+	}
+	localSum, err := fileMD5(file)
+	if err != nil {
+		return "", err
+	}
+	if !isMD5 {
+		// 分片上传等情况下 ETag 不是 MD5，读取远程内容计算
+		if remoteSum, err = remoteMD5(ctx, uploader, object.Key); err != nil {
+			return "", err
+		}
+	}
+	if localSum != remoteSum {
+		return "checksum", nil
+	}
+	return "", nil
+}
+
+func fileMD5(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func remoteMD5(ctx context.Context, uploader upload.Uploader, key string) (string, error) {
+	reader, err := uploader.Open(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// printSyncPlan 按 rsync 的风格输出同步计划
+func printSyncPlan(plan []syncAction, unchanged int) {
+	var uploads, deletes int
+	var uploadSize int64
+	for _, action := range plan {
+		fmt.Printf("%s %-50s %10s  %s\n", action.Op, action.Key, formatFileSize(action.Size), action.Reason)
+		if action.Op == syncDelete {
+			deletes++
+		} else {
+			uploads++
+			uploadSize += action.Size
+		}
+	}
+	fmt.Printf("\n📋 上传 %d 个文件 (%s)，删除 %d 个远程文件，%d 个文件未变化\n",
+		uploads, formatFileSize(uploadSize), deletes, unchanged)
+}
+
+// deleteRemote 批量删除本地已不存在的远程文件，返回删除成功的数量
+func deleteRemote(ctx context.Context, uploader upload.Uploader, plan []syncAction, verbose bool) (int, []error) {
+	var keys []string
+	for _, action := range plan {
+		if action.Op == syncDelete {
+			keys = append(keys, action.Key)
+		}
+	}
+	deleted := 0
+	var errs []error
+	// 与各厂商批量删除接口的上限一致
+	const batchSize = 1000
+	for start := 0; start < len(keys); start += batchSize {
+		end := min(start+batchSize, len(keys))
+		result, err := uploader.DeleteMany(ctx, keys[start:end])
+		if result != nil {
+			deleted += len(result.Deleted)
+			if verbose {
+				for _, key := range result.Deleted {
+					fmt.Printf("🗑️  删除: %s\n", key)
+				}
+			}
+			for _, failed := range result.Errors {
+				errs = append(errs, fmt.Errorf("%s: %s", failed.Key, failed.Message))
+			}
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return deleted, errs
+}
+
+// runSync 生成同步计划，试运行时只输出计划，否则上传新增或变化的文件并删除远程多余的文件
+func runSync(ctx context.Context, uploader upload.Uploader, directory string, files []string, prefix string, checksum, deleteMode, dryRun bool, concurrent int, verbose, showProgress bool, opts []upload.UploadOption) {
+	plan, unchanged, err := buildSyncPlan(ctx, uploader, directory, files, prefix, checksum, deleteMode)
+	if err != nil {
+		log.Fatalf("❌ 生成同步计划失败: %v", err)
+	}
+	if dryRun {
+		fmt.Println("\n🔍 试运行模式 - 同步计划:")
+		printSyncPlan(plan, unchanged)
+		return
+	}
+
+	var tasks []UploadTask
+	for _, action := range plan {
+		if action.Op != syncDelete {
+			tasks = append(tasks, UploadTask{FilePath: action.Path, Key: action.Key})
+		}
+	}
+	fmt.Printf("🚀 开始同步 (上传 %d 个文件，%d 个未变化，并发: %d)...\n\n", len(tasks), unchanged, concurrent)
+	start := time.Now()
+	results := batchUpload(ctx, uploader, tasks, concurrent, verbose, showProgress, opts)
+	uploader.Wait()
+	deleted, errs := deleteRemote(ctx, uploader, plan, verbose)
+	duration := time.Since(start)
+
+	if len(results) > 0 {
+		printBatchResults(results, duration)
+	}
+	if len(plan) == 0 {
+		fmt.Println("✅ 远程文件已是最新")
+	}
+	if deleted > 0 || len(errs) > 0 {
+		fmt.Printf("🗑️  删除远程文件: %d\n", deleted)
+		for _, err := range errs {
+			fmt.Printf("❌ 删除失败: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"upload-util/pkg/upload"
+)
+
+func TestSyncPrefix(t *testing.T) {
+	tests := []struct{ prefix, want string }{
+		{"", ""},
+		{"site", "site/"},
+		{"site/", "site/"},
+		{"/site/css", "site/css/"},
+	}
+	for _, tt := range tests {
+		if got := syncPrefix(tt.prefix); got != tt.want {
+			t.Errorf("syncPrefix(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestSyncKey(t *testing.T) {
+	dir := filepath.Join("tmp", "site")
+	tests := []struct {
+		file, prefix, want string
+	}{
+		{filepath.Join(dir, "index.html"), "", "index.html"},
+		{filepath.Join(dir, "index.html"), "site/", "site/index.html"},
+		{filepath.Join(dir, "css", "main.css"), "site/", "site/css/main.css"},
+	}
+	for _, tt := range tests {
+		got, err := syncKey(dir, tt.file, tt.prefix)
+		if err != nil || got != tt.want {
+			t.Errorf("syncKey(%q, %q) = %q, %v, want %q", tt.file, tt.prefix, got, err, tt.want)
+		}
+	}
+}
+
+func TestSyncChanged(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(file, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const helloMD5 = "5d41402abc4b2a76b9719d911017c592"
+	before, after := stat.ModTime().Add(-time.Hour), stat.ModTime().Add(time.Hour)
+	tests := []struct {
+		name     string
+		object   upload.ObjectInfo
+		checksum bool
+		want     string
+	}{
+		{"Size", upload.ObjectInfo{Size: 4, ETag: `"` + helloMD5 + `"`, LastModified: after}, false, "size"},
+		// ETag 是 MD5 时不比较修改时间
+		{"MD5Unchanged", upload.ObjectInfo{Size: 5, ETag: `"` + strings.ToUpper(helloMD5) + `"`, LastModified: before}, false, ""},
+		{"MD5Changed", upload.ObjectInfo{Size: 5, ETag: `"7d793037a0760186574b0282f2f435e7"`, LastModified: after}, false, "checksum"},
+		{"MtimeChanged", upload.ObjectInfo{Size: 5, ETag: "1-5", LastModified: before}, false, "mtime"},
+		{"MtimeUnchanged", upload.ObjectInfo{Size: 5, ETag: "1-5", LastModified: after}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := syncChanged(context.Background(), nil, file, stat, tt.object, tt.checksum)
+			if err != nil || got != tt.want {
+				t.Errorf("syncChanged = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSyncPlan(t *testing.T) {
+	uploader, err := upload.NewUploader(upload.NewConfigBuilder().
+		WithLocal(t.TempDir(), "").
+		WithAllowedExtensions([]string{".txt"}).
+		Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeLocal := func(name, content string, mtime time.Time) string {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	files := []string{
+		writeLocal("same.txt", "same", past),
+		writeLocal("resized.txt", "longer content", past),
+		writeLocal("edited.txt", "new!", past),
+		writeLocal("touched.txt", "same", future),
+		writeLocal("sub/new.txt", "new", past),
+	}
+	ctx := context.Background()
+	for key, content := range map[string]string{
+		"site/same.txt":    "same",
+		"site/resized.txt": "short",
+		"site/edited.txt":  "old!",
+		"site/touched.txt": "same",
+		"site/old.txt":     "old",
+	} {
+		if _, err := uploader.UploadReader(ctx, strings.NewReader(content), key, int64(len(content)), upload.WithKey(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name                   string
+		checksum, deleteRemote bool
+		want                   []string
+		unchanged              int
+	}{
+		{"Mtime", false, false, []string{"~ site/resized.txt size", "~ site/touched.txt mtime", "+ site/sub/new.txt new"}, 2},
+		{"Checksum", true, false, []string{"~ site/resized.txt size", "~ site/edited.txt checksum", "+ site/sub/new.txt new"}, 2},
+		{"Delete", true, true, []string{"~ site/resized.txt size", "~ site/edited.txt checksum", "+ site/sub/new.txt new", "- site/old.txt deleted locally"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, unchanged, err := buildSyncPlan(ctx, uploader, dir, files, "site/", tt.checksum, tt.deleteRemote)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, action := range plan {
+				got = append(got, action.Op+" "+action.Key+" "+action.Reason)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") || unchanged != tt.unchanged {
+				t.Errorf("got plan %q (%d unchanged), want %q (%d unchanged)", got, unchanged, tt.want, tt.unchanged)
+			}
+		})
+	}
+}
+
+func TestSyncPlanIgnoresVariants(t *testing.T) {
+	cfg := upload.NewConfigBuilder().
+		WithLocal(t.TempDir(), "").
+		WithVariants(upload.ImageVariant{Name: "thumb", Width: 10, Height: 10, Mode: "crop"}).
+		Build()
+	uploader, err := upload.NewUploader(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 20, 20))); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.png")
+	if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	result, err := uploader.UploadReader(ctx, bytes.NewReader(buf.Bytes()), "a.png", int64(buf.Len()), upload.WithKey("photos/a.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Variants) != 1 {
+		t.Fatalf("expected a thumbnail, got %+v", result.Variants)
+	}
+
+	// 缩略图不在列举结果中，-delete 不会把它当作本地已删除的文件
+	plan, unchanged, err := buildSyncPlan(ctx, uploader, dir, []string{file}, "photos/", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 0 || unchanged != 1 {
+		t.Errorf("expected nothing to sync, got %+v (%d unchanged)", plan, unchanged)
+	}
+}
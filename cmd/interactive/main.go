@@ -6,10 +6,11 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"mime/multipart"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+	"upload-util/internal/progress"
 	"upload-util/pkg/upload"
 )
 
@@ -28,6 +29,7 @@ type CLI struct {
 func main() {
 	var (
 		configPath = flag.String("config", "config.yaml", "配置文件路径")
+		profile    = flag.String("profile", "", "使用配置文件中 profiles 下的命名配置")
 		version    = flag.Bool("version", false, "显示版本信息")
 	)
 	flag.Parse()
@@ -45,6 +47,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("❌ 加载配置失败: %v", err)
 	}
+	cfg, err = cfg.Profile(*profile)
+	if err != nil {
+		log.Fatalf("❌ 加载配置失败: %v", err)
+	}
 
 	// 创建上传器
 	uploader, err := upload.NewUploader(cfg)
@@ -52,6 +58,9 @@ func main() {
 		log.Fatalf("❌ 创建上传器失败: %v", err)
 	}
 
+	// 退出前等待 mirror async 策略的副本复制完成
+	defer uploader.Wait()
+
 	cli := &CLI{
 		uploader: uploader,
 		scanner:  bufio.NewScanner(os.Stdin),
@@ -110,7 +119,7 @@ func (c *CLI) printHelp() {
 	fmt.Println("  文件操作:")
 	fmt.Println("    upload|up <file>      上传文件")
 	fmt.Println("    delete|del|rm <key>   删除文件")
-	fmt.Println("    geturl|url <key>      获取文件URL")
+	fmt.Println("    geturl|url <key>      获取文件URL，私有存储可追加签名有效期，如 url a.jpg 30m")
 	fmt.Println()
 	fmt.Println("  目录操作:")
 	fmt.Println("    ls|list [pattern]     列出文件")
@@ -158,14 +167,14 @@ func (c *CLI) handleUpload(args []string) {
 		}
 	}(file)
 
-	header := &multipart.FileHeader{
-		Filename: filepath.Base(filePath),
-		Size:     stat.Size(),
-	}
-
-	fmt.Printf("⏳ 正在上传 %s (%s)...\n", header.Filename, formatFileSize(header.Size))
+	filename := filepath.Base(filePath)
+	fmt.Printf("⏳ 正在上传 %s (%s)...\n", filename, formatFileSize(stat.Size()))
 
-	result, err := c.uploader.Upload(context.Background(), file, header)
+	started := time.Now()
+	bars := progress.New(os.Stdout)
+	bar := bars.Add(filename, stat.Size())
+	result, err := c.uploader.UploadReader(context.Background(), file, filename, stat.Size(), upload.WithProgress(bar.Update))
+	bars.Stop()
 	if err != nil {
 		fmt.Printf("❌ 上传失败: %v\n", err)
 		return
@@ -176,6 +185,9 @@ func (c *CLI) handleUpload(args []string) {
 	fmt.Printf("   🔗 URL:  %s\n", result.URL)
 	fmt.Printf("   📏 大小: %s\n", formatFileSize(result.Size))
 	fmt.Printf("   📝 类型: %s\n", result.MimeType)
+	if result.Size > 0 && !result.Deduplicated {
+		fmt.Printf("   ⏱️  耗时: %s\n", time.Since(started).Round(time.Millisecond))
+	}
 }
 
 func (c *CLI) handleDelete(args []string) {
@@ -198,12 +210,21 @@ func (c *CLI) handleDelete(args []string) {
 
 func (c *CLI) handleGetURL(args []string) {
 	if len(args) < 1 {
-		fmt.Println("❌ 用法: geturl <文件键名>")
+		fmt.Println("❌ 用法: geturl <文件键名> [有效期]")
 		return
 	}
 
 	key := args[0]
-	url, err := c.uploader.GetURL(context.Background(), key)
+	var opts []upload.URLOption
+	if len(args) > 1 {
+		expires, err := time.ParseDuration(args[1])
+		if err != nil || expires <= 0 {
+			fmt.Printf("❌ 无效的有效期: %s\n", args[1])
+			return
+		}
+		opts = append(opts, upload.WithExpires(expires))
+	}
+	url, err := c.uploader.GetURL(context.Background(), key, opts...)
 	if err != nil {
 		fmt.Printf("❌ 获取URL失败: %v\n", err)
 		return
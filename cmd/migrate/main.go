@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"time"
+	"upload-util/pkg/upload"
+)
+
+var (
+	Version   = "dev"
+	GitCommit = "unknown"
+	BuildTime = "unknown"
+)
+
+func main() {
+	var (
+		configPath   = flag.String("config", "config.yaml", "配置文件路径")
+		from         = flag.String("from", "", "源 profile，为空时使用顶层 upload 配置")
+		to           = flag.String("to", "", "目标 profile，为空时使用顶层 upload 配置")
+		prefix       = flag.String("prefix", "", "只迁移该前缀下的文件")
+		concurrent   = flag.Int("c", 4, "并发迁移数量")
+		checkpoint   = flag.String("checkpoint", "", "断点记录文件，重新运行时跳过已完成的文件")
+		dryRun       = flag.Bool("dry-run", false, "试运行，只列出将要迁移的文件")
+		deleteSource = flag.Bool("delete-source", false, "校验通过后删除源文件")
+		verbose      = flag.Bool("v", false, "详细输出")
+		version      = flag.Bool("version", false, "显示版本信息")
+	)
+	flag.Parse()
+
+	if *version {
+		fmt.Printf("Upload Util Migrate\n")
+		fmt.Printf("Version: %s\n", Version)
+		fmt.Printf("Git Commit: %s\n", GitCommit)
+		fmt.Printf("Build Time: %s\n", BuildTime)
+		return
+	}
+	if *from == *to {
+		fmt.Println("用法:")
+		fmt.Println("  迁移:     upload-migrate -from=obs -to=minio -c=8 -checkpoint=./migrate.jsonl")
+		fmt.Println("  指定前缀: upload-migrate -from=obs -to=minio -prefix=images/")
+		fmt.Println("  试运行:   upload-migrate -from=obs -to=minio -dry-run")
+		fmt.Println("  移动:     upload-migrate -from=obs -to=minio -delete-source")
+		os.Exit(1)
+	}
+
+	cfg, err := upload.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("❌ 加载配置失败: %v", err)
+	}
+	src, err := newUploader(cfg, *from)
+	if err != nil {
+		log.Fatalf("❌ 创建源上传器失败: %v", err)
+	}
+	dst, err := newUploader(cfg, *to)
+	if err != nil {
+		log.Fatalf("❌ 创建目标上传器失败: %v", err)
+	}
+
+	// 中断时停止分发新的文件，已完成的文件保留在断点记录中
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("📦 源: %s\n", profileLabel(*from))
+	fmt.Printf("🎯 目标: %s\n", profileLabel(*to))
+	if *prefix != "" {
+		fmt.Printf("🔍 前缀: %s\n", *prefix)
+	}
+	if *dryRun {
+		fmt.Println("\n🔍 试运行模式 - 将要迁移的文件:")
+	} else {
+		fmt.Printf("🚀 开始迁移 (并发: %d)...\n\n", *concurrent)
+	}
+
+	var processed atomic.Int64
+	start := time.Now()
+	result, err := upload.Migrate(ctx, src, dst, upload.MigrateOptions{
+		Prefix:       *prefix,
+		Concurrency:  *concurrent,
+		Checkpoint:   *checkpoint,
+		DryRun:       *dryRun,
+		DeleteSource: *deleteSource,
+		Progress: func(object upload.MigrateObject) {
+			n := processed.Add(1)
+			switch {
+			case *dryRun && !object.Skipped:
+				fmt.Printf("  %s (%s)\n", object.Key, formatFileSize(object.Size))
+			case *verbose && object.Error != nil:
+				fmt.Printf("❌ %s: %v\n", object.Key, object.Error)
+			case *verbose && object.Skipped:
+				fmt.Printf("⏭️  %s (已完成，跳过)\n", object.Key)
+			case *verbose:
+				fmt.Printf("✅ %s (%s)\n", object.Key, formatFileSize(object.Size))
+			case !*dryRun:
+				fmt.Printf("\r⏳ 已处理: %d", n)
+			}
+		},
+	})
+	// 等待 mirror async 策略的副本复制完成
+	dst.Wait()
+	if !*dryRun && !*verbose {
+		fmt.Printf("\r")
+	}
+	if result != nil {
+		printMigrateResult(result, time.Since(start))
+	}
+	if err != nil {
+		log.Fatalf("❌ 迁移中止: %v", err)
+	}
+	if result != nil && len(result.Failed) > 0 {
+		os.Exit(1)
+	}
+}
+
+func newUploader(cfg *upload.Config, profile string) (upload.Uploader, error) {
+	profileCfg, err := cfg.Profile(profile)
+	if err != nil {
+		return nil, err
+	}
+	return upload.NewUploader(profileCfg)
+}
+
+func profileLabel(profile string) string {
+	if profile == "" {
+		return "(默认配置)"
+	}
+	return profile
+}
+
+func printMigrateResult(result *upload.MigrateResult, duration time.Duration) {
+	fmt.Println("\n📊 迁移结果:")
+	fmt.Println(strings.Repeat("=", 60))
+	for _, object := range result.Failed {
+		fmt.Printf("❌ %s\n   错误: %v\n", object.Key, object.Error)
+	}
+	if len(result.Failed) > 0 {
+		fmt.Println(strings.Repeat("=", 60))
+	}
+	fmt.Printf("   文件总数: %d\n", result.Objects)
+	if result.DryRun {
+		fmt.Printf("   待迁移: %d (%s)\n", result.Migrated, formatFileSize(result.Bytes))
+	} else {
+		fmt.Printf("   迁移成功: %d\n", result.Migrated)
+		fmt.Printf("   迁移大小: %s\n", formatFileSize(result.Bytes))
+	}
+	if result.Skipped > 0 {
+		fmt.Printf("   已完成跳过: %d\n", result.Skipped)
+	}
+	fmt.Printf("   失败数量: %d\n", len(result.Failed))
+	fmt.Printf("   总耗时: %.2f 秒\n", duration.Seconds())
+	if !result.DryRun && result.Bytes > 0 {
+		speed := float64(result.Bytes) / duration.Seconds()
+		fmt.Printf("   迁移速度: %s/秒\n", formatFileSize(int64(speed)))
+	}
+}
+
+func formatFileSize(bytes int64) string {
+	const (
+		KB = 1024
+		MB = KB * 1024
+		GB = MB * 1024
+	)
+
+	switch {
+	case bytes >= GB:
+		return fmt.Sprintf("%.2f GB", float64(bytes)/GB)
+	case bytes >= MB:
+		return fmt.Sprintf("%.2f MB", float64(bytes)/MB)
+	case bytes >= KB:
+		return fmt.Sprintf("%.2f KB", float64(bytes)/KB)
+	default:
+		return fmt.Sprintf("%d bytes", bytes)
+	}
+}
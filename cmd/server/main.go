@@ -26,6 +26,7 @@ func main() {
 	// 命令行参数
 	var (
 		configFile = flag.String("config", "", "配置文件路径")
+		profile    = flag.String("profile", "", "只提供指定 profile 的上传接口，作为默认的 /api/v1/upload")
 		port       = flag.String("port", "", "服务端口")
 		host       = flag.String("host", "", "服务地址")
 		version    = flag.Bool("version", false, "显示版本信息")
@@ -44,6 +45,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("加载配置文件失败: %v", err)
 	}
+	cfg, err = cfg.Profile(*profile)
+	if err != nil {
+		log.Fatalf("加载配置文件失败: %v", err)
+	}
 
 	// 验证配置
 	if err := cfg.Validate(); err != nil {
@@ -80,6 +85,13 @@ func main() {
 		log.Printf("上传接口: http://%s/api/v1/upload/file", srv.Addr)
 		log.Printf("获取访问url: http://%s/api/v1/upload/url", srv.Addr)
 		log.Printf("删除接口: http://%s/api/v1/upload/delete", srv.Addr)
+		log.Printf("断点续传: http://%s/api/v1/upload/tus", srv.Addr)
+		for _, name := range cfg.ProfileNames() {
+			if cfg.ProfileName != "" {
+				break
+			}
+			log.Printf("profile %s: http://%s/api/v1/%s/upload/file", name, srv.Addr, name)
+		}
 
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("服务器启动失败: %v", err)
@@ -4,10 +4,12 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
+	"upload-util/internal/progress"
 	"upload-util/pkg/upload"
 )
 
@@ -20,10 +22,22 @@ var (
 func main() {
 	var (
 		configPath = flag.String("config", "config.yaml", "配置文件路径")
-		filePath   = flag.String("file", "", "要上传的文件路径")
-		operation  = flag.String("op", "upload", "操作类型: upload, delete, geturl")
-		key        = flag.String("key", "", "文件键名（用于删除和获取URL）")
+		profile    = flag.String("profile", "", "使用配置文件中 profiles 下的命名配置")
+		filePath   = flag.String("file", "", "要上传的文件路径，- 表示从标准输入读取")
+		name       = flag.String("name", "", "文件名（从标准输入上传时使用）")
+		user       = flag.String("user", "", "上传者标识，用于 key-template 中的 {user}")
+		operation  = flag.String("op", "upload", "操作类型: upload, delete, geturl, ls, copy, move, repair")
+		key        = flag.String("key", "", "文件键名（用于删除、获取URL，复制和移动时为源文件）")
+		dst        = flag.String("dst", "", "目标文件键名（用于复制和移动）")
+		prefix     = flag.String("prefix", "", "键名前缀（用于 ls，以及 delete 删除前缀下的所有文件）")
+		keysFile   = flag.String("keys-file", "", "批量删除的键名列表文件，每行一个，- 表示标准输入（用于 delete）")
+		dryRun     = flag.Bool("dry-run", false, "只列出将要删除的文件，不实际删除（用于 delete -prefix）")
+		delimiter  = flag.String("delimiter", "/", "目录分隔符，为空时递归列举（用于 ls）")
+		cursor     = flag.String("cursor", "", "从上一页返回的游标继续列举（用于 ls）")
+		limit      = flag.Int("limit", 100, "每页数量，最大 1000（用于 ls）")
+		expires    = flag.Duration("expires", 0, "签名链接有效期，如 30m，仅对私有存储生效（用于 geturl）")
 		verbose    = flag.Bool("v", false, "详细输出")
+		noProgress = flag.Bool("no-progress", false, "不显示上传进度条")
 		version    = flag.Bool("version", false, "显示版本信息")
 	)
 	flag.Parse()
@@ -43,7 +57,20 @@ func main() {
 			printUsage()
 			os.Exit(1)
 		}
-	case "delete", "geturl":
+	case "ls", "repair":
+	case "copy", "move":
+		if *key == "" || *dst == "" {
+			fmt.Printf("❌ %s 操作需要指定源文件键名和目标文件键名\n", *operation)
+			printUsage()
+			os.Exit(1)
+		}
+	case "delete":
+		if *key == "" && *keysFile == "" && *prefix == "" {
+			fmt.Println("❌ delete 操作需要指定 -key、-keys-file 或 -prefix")
+			printUsage()
+			os.Exit(1)
+		}
+	case "geturl":
 		if *key == "" {
 			fmt.Printf("❌ %s 操作需要指定文件键名\n", *operation)
 			printUsage()
@@ -60,6 +87,10 @@ func main() {
 	if err != nil {
 		log.Fatalf("❌ 加载配置失败: %v", err)
 	}
+	cfg, err = cfg.Profile(*profile)
+	if err != nil {
+		log.Fatalf("❌ 加载配置失败: %v", err)
+	}
 
 	// 创建上传器
 	uploader, err := upload.NewUploader(cfg)
@@ -71,21 +102,42 @@ func main() {
 
 	switch *operation {
 	case "upload":
-		result, err := uploadFile(ctx, uploader, *filePath, *verbose)
+		result, err := uploadFile(ctx, uploader, *filePath, *name, *verbose, !*noProgress, upload.WithUser(*user))
 		if err != nil {
 			log.Fatalf("❌ 上传失败: %v", err)
 		}
 		printUploadResult(result, *verbose)
+		// 等待 mirror async 策略的副本复制完成
+		uploader.Wait()
 
 	case "delete":
-		err := uploader.Delete(ctx, *key)
-		if err != nil {
-			log.Fatalf("❌ 删除失败: %v", err)
+		switch {
+		case *keysFile != "":
+			keys, err := readKeys(*keysFile)
+			if err != nil {
+				log.Fatalf("❌ 读取键名列表失败: %v", err)
+			}
+			result, err := uploader.DeleteMany(ctx, keys)
+			printDeleteResult(result, *verbose)
+			if err != nil {
+				log.Fatalf("❌ 删除失败: %v", err)
+			}
+		case *prefix != "":
+			result, err := uploader.DeletePrefix(ctx, *prefix, *dryRun)
+			printDeleteResult(result, *verbose)
+			if err != nil {
+				log.Fatalf("❌ 删除失败: %v", err)
+			}
+		default:
+			err := uploader.Delete(ctx, *key)
+			if err != nil {
+				log.Fatalf("❌ 删除失败: %v", err)
+			}
+			fmt.Printf("✅ 删除成功: %s\n", *key)
 		}
-		fmt.Printf("✅ 删除成功: %s\n", *key)
 
 	case "geturl":
-		url, err := uploader.GetURL(ctx, *key)
+		url, err := uploader.GetURL(ctx, *key, upload.WithExpires(*expires))
 		if err != nil {
 			log.Fatalf("❌ 获取URL失败: %v", err)
 		}
@@ -95,6 +147,38 @@ func main() {
 		} else {
 			fmt.Printf("%s\n", url)
 		}
+
+	case "copy":
+		if err := uploader.Copy(ctx, *key, *dst); err != nil {
+			log.Fatalf("❌ 复制失败: %v", err)
+		}
+		fmt.Printf("✅ 复制成功: %s -> %s\n", *key, *dst)
+
+	case "move":
+		if err := uploader.Move(ctx, *key, *dst); err != nil {
+			log.Fatalf("❌ 移动失败: %v", err)
+		}
+		fmt.Printf("✅ 移动成功: %s -> %s\n", *key, *dst)
+
+	case "ls":
+		result, err := uploader.List(ctx, *prefix, *delimiter, *cursor, *limit)
+		if err != nil {
+			log.Fatalf("❌ 列举失败: %v", err)
+		}
+		printListResult(result, *verbose)
+
+	case "repair":
+		repaired, remaining, err := upload.Repair(ctx, uploader)
+		fmt.Printf("🔧 修复成功: %d\n", repaired)
+		for _, entry := range remaining {
+			fmt.Printf("  ❌ %s %s@%s: %s\n", entry.Op, entry.Key, entry.Backend, entry.Error)
+		}
+		if err != nil {
+			log.Fatalf("❌ 修复失败: %v", err)
+		}
+		if len(remaining) > 0 {
+			os.Exit(1)
+		}
 	}
 }
 
@@ -103,21 +187,53 @@ func printUsage() {
 	fmt.Println("  上传文件:")
 	fmt.Println("    upload-cli -file=/path/to/file.jpg")
 	fmt.Println("    upload-cli -file=./image.png -v")
+	fmt.Println("    tar cz dir | upload-cli -file=- -name=dir.tar.gz")
 	fmt.Println("")
 	fmt.Println("  删除文件:")
 	fmt.Println("    upload-cli -op=delete -key=uploads/abc123.jpg")
+	fmt.Println("    upload-cli -op=delete -keys-file=keys.txt")
+	fmt.Println("    upload-cli -op=delete -prefix=users/42/ -dry-run")
 	fmt.Println("")
 	fmt.Println("  获取URL:")
 	fmt.Println("    upload-cli -op=geturl -key=uploads/abc123.jpg")
 	fmt.Println("    upload-cli -op=geturl -key=uploads/abc123.jpg -v")
 	fmt.Println("")
+	fmt.Println("  复制/移动文件:")
+	fmt.Println("    upload-cli -op=copy -key=tmp/abc123.jpg -dst=avatars/abc123.jpg")
+	fmt.Println("    upload-cli -op=move -key=tmp/abc123.jpg -dst=avatars/abc123.jpg")
+	fmt.Println("")
+	fmt.Println("  列举文件:")
+	fmt.Println("    upload-cli -op=ls -prefix=uploads/")
+	fmt.Println("    upload-cli -op=ls -prefix=uploads/ -delimiter= -limit=1000 -v")
+	fmt.Println("    upload-cli -op=ls -prefix=uploads/ -cursor=<上一页游标>")
+	fmt.Println("")
+	fmt.Println("  修复镜像副本 (upload.type 为 mirror，按 repair-log 补齐副本):")
+	fmt.Println("    upload-cli -op=repair")
+	fmt.Println("")
 	fmt.Println("  其他选项:")
 	fmt.Println("    -config=path/to/config.yaml  指定配置文件")
+	fmt.Println("    -profile=invoices            使用配置文件 profiles 下的命名配置")
 	fmt.Println("    -v                           详细输出")
 	fmt.Println("    -version                     显示版本信息")
 }
 
-func uploadFile(ctx context.Context, uploader upload.Uploader, filePath string, verbose bool) (*upload.UploadResult, error) {
+// uploadFile 上传文件或标准输入，showProgress 时在终端显示进度条
+func uploadFile(ctx context.Context, uploader upload.Uploader, filePath, name string, verbose, showProgress bool, opts ...upload.UploadOption) (*upload.UploadResult, error) {
+	// 从标准输入读取时大小未知，以分片方式流式上传
+	if filePath == "-" {
+		if name == "" {
+			return nil, fmt.Errorf("从标准输入上传时需要通过 -name 指定文件名")
+		}
+		if verbose {
+			fmt.Printf("⏳ 正在上传: %s (标准输入)\n", name)
+		}
+		if showProgress {
+			stop := addProgressBar(name, -1, &opts)
+			defer stop()
+		}
+		return uploader.UploadReader(ctx, os.Stdin, name, -1, opts...)
+	}
+
 	// 检查文件是否存在
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("文件不存在: %s", filePath)
@@ -136,18 +252,20 @@ func uploadFile(ctx context.Context, uploader upload.Uploader, filePath string,
 		return nil, fmt.Errorf("获取文件信息失败: %w", err)
 	}
 
-	// 创建文件头
-	header := &multipart.FileHeader{
-		Filename: filepath.Base(filePath),
-		Size:     stat.Size(),
+	if name == "" {
+		name = filepath.Base(filePath)
 	}
 
 	if verbose {
-		fmt.Printf("⏳ 正在上传: %s (%s)\n", header.Filename, formatFileSize(header.Size))
+		fmt.Printf("⏳ 正在上传: %s (%s)\n", name, formatFileSize(stat.Size()))
 	}
 
 	// 上传文件
-	result, err := uploader.Upload(ctx, file, header)
+	if showProgress {
+		stop := addProgressBar(name, stat.Size(), &opts)
+		defer stop()
+	}
+	result, err := uploader.UploadReader(ctx, file, name, stat.Size(), opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -155,19 +273,96 @@ func uploadFile(ctx context.Context, uploader upload.Uploader, filePath string,
 	return result, nil
 }
 
+// addProgressBar 显示单个文件的进度条并添加进度回调，返回清除进度条的函数
+func addProgressBar(name string, size int64, opts *[]upload.UploadOption) func() {
+	bars := progress.New(os.Stdout)
+	bar := bars.Add(name, size)
+	*opts = append(*opts, upload.WithProgress(bar.Update))
+	return bars.Stop
+}
+
 func printUploadResult(result *upload.UploadResult, verbose bool) {
-	fmt.Printf("✅ 上传成功!\n")
+	if result.Deduplicated {
+		fmt.Printf("✅ 文件已存在，跳过上传\n")
+	} else {
+		fmt.Printf("✅ 上传成功!\n")
+	}
 	fmt.Printf("🔗 URL: %s\n", result.URL)
 
 	if verbose {
 		fmt.Printf("🔑 Key: %s\n", result.Key)
 		fmt.Printf("📏 Size: %s\n", formatFileSize(result.Size))
 		fmt.Printf("📄 Type: %s\n", result.MimeType)
+		if result.Backend != "" {
+			fmt.Printf("🗄️ Backend: %s\n", result.Backend)
+		}
 	} else {
 		fmt.Printf("🔑 Key: %s\n", result.Key)
 	}
 }
 
+// readKeys 读取键名列表，忽略空行
+func readKeys(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if key := strings.TrimSpace(line); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func printDeleteResult(result *upload.DeleteResult, verbose bool) {
+	if result == nil {
+		return
+	}
+	if result.DryRun {
+		for _, key := range result.Deleted {
+			fmt.Printf("🗑️  %s\n", key)
+		}
+		fmt.Printf("🔍 预览: 将删除 %d 个文件\n", len(result.Deleted))
+		return
+	}
+	if verbose {
+		for _, key := range result.Deleted {
+			fmt.Printf("✅ %s\n", key)
+		}
+	}
+	for _, deleteErr := range result.Errors {
+		fmt.Printf("❌ %s: %s\n", deleteErr.Key, deleteErr.Message)
+	}
+	fmt.Printf("📊 删除成功 %d 个，失败 %d 个\n", len(result.Deleted), len(result.Errors))
+}
+
+func printListResult(result *upload.ListResult, verbose bool) {
+	for _, prefix := range result.CommonPrefixes {
+		fmt.Printf("📁 %s\n", prefix)
+	}
+	for _, object := range result.Objects {
+		if verbose {
+			fmt.Printf("📄 %-50s %10s  %s\n", object.Key, formatFileSize(object.Size), object.LastModified.Local().Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("📄 %s\n", object.Key)
+		}
+	}
+	if verbose {
+		fmt.Printf("📊 共 %d 个文件，%d 个目录\n", len(result.Objects), len(result.CommonPrefixes))
+	}
+	if result.Truncated {
+		fmt.Printf("⏭️  还有更多结果，使用 -cursor=%s 继续列举\n", result.NextCursor)
+	}
+}
+
 func formatFileSize(bytes int64) string {
 	const (
 		KB = 1024
@@ -13,6 +13,13 @@ type UploadConfig struct {
 	ServerConfig   ServerAddressConfig `yaml:"server"`
 	Upload         UploadProvider      `yaml:"upload"`
 	UploadSettings UploadSettings      `yaml:"upload-settings"`
+	Tus            TusConfig           `yaml:"tus,omitempty"`
+	// Profiles 命名的上传配置，每个 profile 使用独立的存储后端和上传规则
+	Profiles map[string]*ProfileConfig `yaml:"profiles,omitempty"`
+	// ProfileName 由 Profile 返回的配置所属的 profile 名称，顶层配置为空
+	ProfileName string `yaml:"-"`
+	// root profile 所属的顶层配置
+	root *UploadConfig
 }
 
 type ServerAddressConfig struct {
@@ -25,11 +32,14 @@ type UploadProvider struct {
 	Local *LocalConfig `yaml:"local,omitempty"`
 	OSS   *OSSConfig   `yaml:"oss,omitempty"`
 	MinIO *MinioConfig `yaml:"minio,omitempty"`
+	// Backends 自定义存储后端的配置，键为注册的后端名称，如 type: mystore 时读取 mystore 下的配置
+	Backends map[string]yaml.Node `yaml:",inline"`
 }
 
 type LocalConfig struct {
-	Path      string `yaml:"path"`
-	URLPrefix string `yaml:"url-prefix,omitempty"`
+	Path       string `yaml:"path"`
+	URLPrefix  string `yaml:"url-prefix,omitempty"`
+	SignSecret string `yaml:"sign-secret,omitempty"`
 }
 
 type OSSConfig struct {
@@ -50,17 +60,20 @@ type AliyunOSSConfig struct {
 	PathPrefix      string `yaml:"path-prefix,omitempty"`
 	UseSSL          bool   `yaml:"use-ssl"`
 	UseInternal     bool   `yaml:"use-internal"`
+	Private         bool   `yaml:"private,omitempty"`
 	SignURLExpire   int64  `yaml:"sign-url-expire,omitempty"`
 }
 
 type TencentCOSConfig struct {
-	Region     string `yaml:"region"`
-	SecretID   string `yaml:"secret-id"`
-	SecretKey  string `yaml:"secret-key"`
-	Bucket     string `yaml:"bucket"`
-	Domain     string `yaml:"domain,omitempty"`
-	PathPrefix string `yaml:"path-prefix,omitempty"`
-	UseSSL     bool   `yaml:"use-ssl"`
+	Region        string `yaml:"region"`
+	SecretID      string `yaml:"secret-id"`
+	SecretKey     string `yaml:"secret-key"`
+	Bucket        string `yaml:"bucket"`
+	Domain        string `yaml:"domain,omitempty"`
+	PathPrefix    string `yaml:"path-prefix,omitempty"`
+	UseSSL        bool   `yaml:"use-ssl"`
+	Private       bool   `yaml:"private,omitempty"`
+	SignURLExpire int64  `yaml:"sign-url-expire,omitempty"`
 }
 
 type HuaweiOBSConfig struct {
@@ -72,6 +85,8 @@ type HuaweiOBSConfig struct {
 	Domain          string `yaml:"domain,omitempty"`
 	PathPrefix      string `yaml:"path-prefix,omitempty"`
 	UseSSL          bool   `yaml:"use-ssl"`
+	Private         bool   `yaml:"private,omitempty"`
+	SignURLExpire   int64  `yaml:"sign-url-expire,omitempty"`
 }
 
 // AWSS3Config AWS S3 配置
@@ -84,6 +99,8 @@ type AWSS3Config struct {
 	Domain          string `yaml:"domain,omitempty"`
 	PathPrefix      string `yaml:"path-prefix,omitempty"`
 	UseSSL          bool   `yaml:"use-ssl"`
+	Private         bool   `yaml:"private,omitempty"`
+	SignURLExpire   int64  `yaml:"sign-url-expire,omitempty"`
 }
 
 // QCloudCOSConfig 其他兼容 S3 的云服务配置
@@ -96,24 +113,47 @@ type QCloudCOSConfig struct {
 	Domain          string `yaml:"domain,omitempty"`
 	PathPrefix      string `yaml:"path-prefix,omitempty"`
 	UseSSL          bool   `yaml:"use-ssl"`
+	Private         bool   `yaml:"private,omitempty"`
+	SignURLExpire   int64  `yaml:"sign-url-expire,omitempty"`
 }
 
 type MinioConfig struct {
-	Endpoint   string `yaml:"endpoint"`
-	AccessKey  string `yaml:"access-key"`
-	SecretKey  string `yaml:"secret-key"`
-	Bucket     string `yaml:"bucket"`
-	Domain     string `yaml:"domain,omitempty"`
-	PathPrefix string `yaml:"path-prefix,omitempty"`
-	UseSSL     bool   `yaml:"use-ssl"`
-	Region     string `yaml:"region,omitempty"`
+	Endpoint      string `yaml:"endpoint"`
+	AccessKey     string `yaml:"access-key"`
+	SecretKey     string `yaml:"secret-key"`
+	Bucket        string `yaml:"bucket"`
+	Domain        string `yaml:"domain,omitempty"`
+	PathPrefix    string `yaml:"path-prefix,omitempty"`
+	UseSSL        bool   `yaml:"use-ssl"`
+	Region        string `yaml:"region,omitempty"`
+	Private       bool   `yaml:"private,omitempty"`
+	SignURLExpire int64  `yaml:"sign-url-expire,omitempty"`
+}
+
+// TusConfig 断点续传 (tus 协议) 配置
+type TusConfig struct {
+	Path   string `yaml:"path,omitempty"`
+	Expire int64  `yaml:"expire,omitempty"`
 }
 
 type UploadSettings struct {
 	MaxFileSize       int64    `yaml:"max-file-size"`
 	AllowedExtensions []string `yaml:"allowed-extensions"`
-	FilenameStrategy  string   `yaml:"filename-strategy"`
-	KeepOriginalName  bool     `yaml:"keep-original-name"`
+	// AllowedMimeTypes 允许的内容类型，按文件内容识别，支持 image/* 通配，为空时不限制
+	AllowedMimeTypes   []string `yaml:"allowed-mime-types,omitempty"`
+	FilenameStrategy   string   `yaml:"filename-strategy"`
+	KeepOriginalName   bool     `yaml:"keep-original-name"`
+	MultipartThreshold int64    `yaml:"multipart-threshold"`
+	PartSize           int64    `yaml:"part-size"`
+	PresignExpire      int64    `yaml:"presign-expire,omitempty"`
+	// KeyTemplate 对象键模板，如 {yyyy}/{mm}/{dd}/{uuid}{ext}，设置后替代 filename-strategy 生成的文件名
+	KeyTemplate string `yaml:"key-template,omitempty"`
+	// Retry 临时性错误的重试配置，未配置时按默认值重试
+	Retry RetryConfig `yaml:"retry,omitempty"`
+	// RateLimit 上传带宽和请求频率限制
+	RateLimit RateLimitConfig `yaml:"rate-limit,omitempty"`
+	// Variants 上传图片时生成的缩略图
+	Variants []ImageVariant `yaml:"variants,omitempty"`
 }
 
 var defaultUploadConfig = UploadConfig{
@@ -130,10 +170,12 @@ var defaultUploadConfig = UploadConfig{
 	},
 
 	UploadSettings: UploadSettings{
-		MaxFileSize:       100,
-		AllowedExtensions: []string{".jpg", ".jpeg", ".png", ".pdf"},
-		FilenameStrategy:  "uuid",
-		KeepOriginalName:  false,
+		MaxFileSize:        100,
+		AllowedExtensions:  []string{".jpg", ".jpeg", ".png", ".pdf"},
+		FilenameStrategy:   "uuid",
+		KeepOriginalName:   false,
+		MultipartThreshold: 100,
+		PartSize:           8,
 	},
 }
 
@@ -162,6 +204,18 @@ func LoadConfig(configPath string) (*UploadConfig, error) {
 		fmt.Printf("Warning failed to parse config file, using default config, error: %v\n", err)
 		return &defaultUploadConfig, nil
 	}
+	if _, err := ParseKeyTemplate(config.UploadSettings.KeyTemplate); err != nil {
+		return nil, err
+	}
+	for _, name := range config.ProfileNames() {
+		profile, err := config.Profile(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := ParseKeyTemplate(profile.UploadSettings.KeyTemplate); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
 	return &config, nil
 }
 
@@ -169,6 +223,21 @@ func (c *UploadConfig) Validate() error {
 	if c.ServerConfig.Port > 65535 || c.ServerConfig.Port < 1024 {
 		return fmt.Errorf("invalid server port: %d", c.ServerConfig.Port)
 	}
+	if _, err := ParseKeyTemplate(c.UploadSettings.KeyTemplate); err != nil {
+		return err
+	}
+	if err := c.UploadSettings.Retry.validate(); err != nil {
+		return err
+	}
+	if err := c.UploadSettings.RateLimit.validate(); err != nil {
+		return err
+	}
+	if err := validateVariants(c.UploadSettings.Variants); err != nil {
+		return err
+	}
+	if err := c.validateProfiles(); err != nil {
+		return err
+	}
 	switch c.Upload.Type {
 	case "local":
 		if c.Upload.Local == nil {
@@ -0,0 +1,11 @@
+package config
+
+// FailoverConfig 按顺序在多个 profile 之间故障转移的配置，对应 upload.type: failover
+type FailoverConfig struct {
+	// Backends 按优先级排列的 profile，前面的不可用时使用后面的
+	Backends []string `yaml:"backends"`
+	// FailureThreshold 连续失败多少次后熔断，默认 3
+	FailureThreshold int `yaml:"failure-threshold,omitempty"`
+	// Cooldown 熔断后经过多少秒允许一次试探请求，默认 30
+	Cooldown int64 `yaml:"cooldown,omitempty"`
+}
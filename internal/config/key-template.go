@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxKeyTemplateLength {hash:N} 和 {random:N} 允许的最大长度
+const maxKeyTemplateLength = 64
+
+// keyTemplatePlaceholders key-template 支持的占位符，值表示是否可以用 {name:N} 指定长度
+var keyTemplatePlaceholders = map[string]bool{
+	"yyyy":   false,
+	"mm":     false,
+	"dd":     false,
+	"uuid":   false,
+	"name":   false,
+	"ext":    false,
+	"user":   false,
+	"hash":   true,
+	"random": true,
+}
+
+// KeyTemplatePart 对象键模板的一个片段，Placeholder 为空时原样输出 Text
+type KeyTemplatePart struct {
+	Text        string
+	Placeholder string
+	// Length {hash:N}、{random:N} 中的长度，未指定时为 0
+	Length int
+}
+
+// ParseKeyTemplate 解析对象键模板，模板为空时返回 nil。
+// 未知占位符、括号不匹配、非法长度以及绝对路径或 .. 路径段都会返回错误
+func ParseKeyTemplate(template string) ([]KeyTemplatePart, error) {
+	if template == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("invalid key-template %q: must not start with /", template)
+	}
+	for _, segment := range strings.Split(template, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("invalid key-template %q: empty or relative path segment", template)
+		}
+	}
+
+	var parts []KeyTemplatePart
+	rest := template
+	for rest != "" {
+		start := strings.IndexAny(rest, "{}")
+		if start < 0 {
+			parts = append(parts, KeyTemplatePart{Text: rest})
+			break
+		}
+		if rest[start] == '}' {
+			return nil, fmt.Errorf("invalid key-template %q: unexpected }", template)
+		}
+		if start > 0 {
+			parts = append(parts, KeyTemplatePart{Text: rest[:start]})
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid key-template %q: unclosed {", template)
+		}
+		part, err := parseKeyTemplatePlaceholder(rest[start+1 : start+end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid key-template %q: %w", template, err)
+		}
+		parts = append(parts, part)
+		rest = rest[start+end+1:]
+	}
+	return parts, nil
+}
+
+func parseKeyTemplatePlaceholder(placeholder string) (KeyTemplatePart, error) {
+	name, arg, hasArg := strings.Cut(placeholder, ":")
+	withLength, ok := keyTemplatePlaceholders[name]
+	if !ok {
+		return KeyTemplatePart{}, fmt.Errorf("unknown placeholder {%s}", placeholder)
+	}
+	part := KeyTemplatePart{Placeholder: name}
+	if !hasArg {
+		return part, nil
+	}
+	if !withLength {
+		return KeyTemplatePart{}, fmt.Errorf("placeholder {%s} does not accept a length", name)
+	}
+	length, err := strconv.Atoi(arg)
+	if err != nil || length < 1 || length > maxKeyTemplateLength {
+		return KeyTemplatePart{}, fmt.Errorf("invalid length in {%s}, must be 1-%d", placeholder, maxKeyTemplateLength)
+	}
+	part.Length = length
+	return part, nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import "testing"
+
+func TestParseKeyTemplate(t *testing.T) {
+	parts, err := ParseKeyTemplate("{yyyy}/{mm}/{dd}/{user}/{hash:8}-{random:6}{ext}")
+	if err != nil {
+		t.Fatalf("ParseKeyTemplate failed: %v", err)
+	}
+	if len(parts) != 12 {
+		t.Fatalf("expected 12 parts, got %d: %+v", len(parts), parts)
+	}
+	if parts[8] != (KeyTemplatePart{Placeholder: "hash", Length: 8}) || parts[9] != (KeyTemplatePart{Text: "-"}) {
+		t.Errorf("unexpected parts: %+v", parts)
+	}
+
+	if parts, err := ParseKeyTemplate(""); err != nil || parts != nil {
+		t.Errorf("expected nil for empty template, got %v %v", parts, err)
+	}
+
+	for _, template := range []string{
+		"{unknown}",
+		"{uuid",
+		"uuid}",
+		"{uuid:8}",
+		"{hash:0}",
+		"{random:abc}",
+		"/{uuid}",
+		"a/../{uuid}",
+		"a//{uuid}",
+	} {
+		if _, err := ParseKeyTemplate(template); err == nil {
+			t.Errorf("expected error for %q", template)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+// 镜像写入策略
+const (
+	// WritePolicyAll 主存储和所有副本都写入成功才算成功 (默认)
+	WritePolicyAll = "all"
+	// WritePolicyAsync 主存储写入成功即返回，副本在后台从主存储复制
+	WritePolicyAsync = "async"
+	// WritePolicyQuorum 写入成功的数量 (包含主存储) 达到 quorum 即算成功
+	WritePolicyQuorum = "quorum"
+)
+
+// MirrorConfig 将每次上传同时写入多个 profile 的配置，对应 upload.type: mirror
+type MirrorConfig struct {
+	// Primary 主存储，读取、列举和获取链接都使用主存储
+	Primary string `yaml:"primary"`
+	// Replicas 副本存储
+	Replicas []string `yaml:"replicas"`
+	// WritePolicy 写入策略: all、async、quorum
+	WritePolicy string `yaml:"write-policy,omitempty"`
+	// Quorum quorum 策略下至少写入成功的数量，默认为过半
+	Quorum int `yaml:"quorum,omitempty"`
+	// RepairLog 副本写入或删除失败时追加记录的文件 (JSON Lines)，为空时只输出日志
+	RepairLog string `yaml:"repair-log,omitempty"`
+}
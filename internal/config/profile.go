@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrProfileNotFound 指定的 profile 不存在
+var ErrProfileNotFound = errors.New("profile not found")
+
+// profileNamePattern profile 名称会作为 URL 路径段，只允许字母、数字、- 和 _
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedProfileNames 与顶层路由冲突的名称
+var reservedProfileNames = map[string]bool{
+	"upload": true,
+	"system": true,
+}
+
+// ProfileConfig 命名的上传配置，拥有独立的存储后端和上传规则
+type ProfileConfig struct {
+	Upload UploadProvider `yaml:"upload"`
+	// UploadSettings 未设置的字段沿用顶层 upload-settings
+	UploadSettings UploadSettings `yaml:"upload-settings,omitempty"`
+	// KeepOriginalName upload-settings.keep-original-name，为 nil 时沿用顶层配置。
+	// UploadSettings.KeepOriginalName 无法区分未设置和 false，读取配置文件时由 UnmarshalYAML 设置
+	KeepOriginalName *bool `yaml:"-"`
+}
+
+// UnmarshalYAML 解析 profile，并记录 upload-settings 中是否显式设置了 keep-original-name
+func (p *ProfileConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain ProfileConfig
+	if err := node.Decode((*plain)(p)); err != nil {
+		return err
+	}
+	var explicit struct {
+		UploadSettings struct {
+			KeepOriginalName *bool `yaml:"keep-original-name"`
+		} `yaml:"upload-settings"`
+	}
+	if err := node.Decode(&explicit); err != nil {
+		return err
+	}
+	p.KeepOriginalName = explicit.UploadSettings.KeepOriginalName
+	return nil
+}
+
+// ProfileNames 返回按名称排序的 profile 列表
+func (c *UploadConfig) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile 返回指定 profile 的完整配置，name 为空时返回顶层配置。
+// 返回的配置共享 server 设置和 profiles (组合多个后端的上传器按名称引用其他 profile)，
+// upload-settings 未设置的字段沿用顶层配置，断点续传的临时目录按 profile 名称隔离
+func (c *UploadConfig) Profile(name string) (*UploadConfig, error) {
+	if name == "" {
+		return c, nil
+	}
+	root := c.Root()
+	profile, ok := root.Profiles[name]
+	if !ok || profile == nil {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	cfg := *root
+	cfg.Upload = profile.Upload
+	cfg.UploadSettings = mergeUploadSettings(root.UploadSettings, profile.UploadSettings)
+	if profile.KeepOriginalName != nil {
+		cfg.UploadSettings.KeepOriginalName = *profile.KeepOriginalName
+	}
+	cfg.ProfileName = name
+	cfg.root = root
+	tusPath := root.Tus.Path
+	if tusPath == "" {
+		tusPath = filepath.Join(os.TempDir(), "upload-util-tus")
+	}
+	cfg.Tus.Path = filepath.Join(tusPath, name)
+	return &cfg, nil
+}
+
+// Root 返回 profile 所属的顶层配置，顶层配置返回自身
+func (c *UploadConfig) Root() *UploadConfig {
+	if c.root != nil {
+		return c.root
+	}
+	return c
+}
+
+// validateProfiles 校验 profile 名称及每个 profile 的存储配置，只在顶层配置上执行
+func (c *UploadConfig) validateProfiles() error {
+	if c.ProfileName != "" {
+		return nil
+	}
+	for _, name := range c.ProfileNames() {
+		if !profileNamePattern.MatchString(name) || reservedProfileNames[name] {
+			return fmt.Errorf("invalid profile name: %q", name)
+		}
+		cfg, err := c.Profile(name)
+		if err != nil {
+			return err
+		}
+		if cfg.Upload.Type == "" {
+			return fmt.Errorf("profile %s: upload type is required", name)
+		}
+		if err := cfg.Validate(); err != nil {
+			return fmt.Errorf("profile %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// mergeUploadSettings 用 override 中非零值的字段覆盖 base
+func mergeUploadSettings(base, override UploadSettings) UploadSettings {
+	merged := base
+	if override.MaxFileSize != 0 {
+		merged.MaxFileSize = override.MaxFileSize
+	}
+	if override.AllowedExtensions != nil {
+		merged.AllowedExtensions = override.AllowedExtensions
+	}
+	if override.AllowedMimeTypes != nil {
+		merged.AllowedMimeTypes = override.AllowedMimeTypes
+	}
+	if override.FilenameStrategy != "" {
+		merged.FilenameStrategy = override.FilenameStrategy
+	}
+	// 显式设置为 false 由 ProfileConfig.KeepOriginalName 处理
+	if override.KeepOriginalName {
+		merged.KeepOriginalName = true
+	}
+	if override.MultipartThreshold != 0 {
+		merged.MultipartThreshold = override.MultipartThreshold
+	}
+	if override.PartSize != 0 {
+		merged.PartSize = override.PartSize
+	}
+	if override.PresignExpire != 0 {
+		merged.PresignExpire = override.PresignExpire
+	}
+	if override.KeyTemplate != "" {
+		merged.KeyTemplate = override.KeyTemplate
+	}
+	if override.Retry != (RetryConfig{}) {
+		merged.Retry = override.Retry
+	}
+	if override.RateLimit != (RateLimitConfig{}) {
+		merged.RateLimit = override.RateLimit
+	}
+	if override.Variants != nil {
+		merged.Variants = override.Variants
+	}
+	return merged
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const profileConfig = `
+server:
+  port: 8080
+upload:
+  type: local
+  local:
+    path: ./uploads
+upload-settings:
+  max-file-size: 100
+  allowed-extensions: [.jpg, .png, .pdf]
+  filename-strategy: uuid
+  keep-original-name: true
+  part-size: 8
+profiles:
+  avatars:
+    upload:
+      type: local
+      local:
+        path: ./avatars
+    upload-settings:
+      max-file-size: 2
+      allowed-extensions: [.jpg, .png]
+      keep-original-name: false
+  invoices:
+    upload:
+      type: oss
+      oss:
+        provider: aliyun
+        aliyun:
+          endpoint: oss-cn-hangzhou.aliyuncs.com
+          access-key-id: id
+          access-key-secret: secret
+          bucket: invoices
+          private: true
+`
+
+func TestProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(profileConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if names := cfg.ProfileNames(); len(names) != 2 || names[0] != "avatars" || names[1] != "invoices" {
+		t.Errorf("unexpected profile names: %v", names)
+	}
+
+	avatars, err := cfg.Profile("avatars")
+	if err != nil {
+		t.Fatalf("Profile failed: %v", err)
+	}
+	if avatars.ProfileName != "avatars" || avatars.Upload.Local.Path != "./avatars" {
+		t.Errorf("unexpected avatars config: %+v", avatars)
+	}
+	// 未设置的字段沿用顶层配置
+	settings := avatars.UploadSettings
+	if settings.MaxFileSize != 2 || len(settings.AllowedExtensions) != 2 || settings.FilenameStrategy != "uuid" || settings.PartSize != 8 {
+		t.Errorf("unexpected avatars settings: %+v", settings)
+	}
+	// 显式设置为 false 时覆盖顶层配置
+	if settings.KeepOriginalName {
+		t.Error("avatars should not keep original names")
+	}
+	if avatars.ServerConfig.Port != 8080 || avatars.Tus.Path == "" {
+		t.Errorf("server and tus config should be shared: %+v", avatars)
+	}
+
+	invoices, err := cfg.Profile("invoices")
+	if err != nil {
+		t.Fatalf("Profile failed: %v", err)
+	}
+	if invoices.Upload.OSS.Aliyun.Bucket != "invoices" || invoices.UploadSettings.MaxFileSize != 100 || !invoices.UploadSettings.KeepOriginalName {
+		t.Errorf("unexpected invoices config: %+v", invoices)
+	}
+	if invoices.Tus.Path == avatars.Tus.Path {
+		t.Error("profiles should use separate tus paths")
+	}
+
+	if top, _ := cfg.Profile(""); top != cfg {
+		t.Error("empty profile name should return the top-level config")
+	}
+	if _, err := cfg.Profile("exports"); !errors.Is(err, ErrProfileNotFound) {
+		t.Errorf("expected ErrProfileNotFound, got %v", err)
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	local := UploadProvider{Type: "local", Local: &LocalConfig{Path: "./uploads"}}
+	tests := []struct {
+		name    string
+		profile string
+		config  *ProfileConfig
+	}{
+		{"reserved name", "upload", &ProfileConfig{Upload: local}},
+		{"invalid name", "a/b", &ProfileConfig{Upload: local}},
+		{"missing type", "avatars", &ProfileConfig{}},
+		{"missing local path", "avatars", &ProfileConfig{Upload: UploadProvider{Type: "local", Local: &LocalConfig{}}}},
+		{"invalid key template", "avatars", &ProfileConfig{Upload: local, UploadSettings: UploadSettings{KeyTemplate: "{bad}"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &UploadConfig{
+				ServerConfig: ServerAddressConfig{Port: 8080},
+				Upload:       local,
+				Profiles:     map[string]*ProfileConfig{tt.profile: tt.config},
+			}
+			if err := cfg.Validate(); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+import "fmt"
+
+// RateLimitConfig 上传带宽和请求频率限制，对应 upload-settings.rate-limit，未设置的项不限制
+type RateLimitConfig struct {
+	// Bandwidth 同一上传器所有并发上传共享的带宽上限 (字节/秒)
+	Bandwidth int64 `yaml:"bandwidth,omitempty"`
+	// PerUploadBandwidth 单个上传的带宽上限 (字节/秒)
+	PerUploadBandwidth int64 `yaml:"per-upload-bandwidth,omitempty"`
+	// OpsPerSecond 每秒请求存储的次数上限 (上传、删除、列举等)，可以是小数
+	OpsPerSecond float64 `yaml:"ops-per-second,omitempty"`
+	// Burst 允许短时间内超出 ops-per-second 的请求数，默认为 ops-per-second 向上取整
+	Burst int `yaml:"burst,omitempty"`
+}
+
+func (c *RateLimitConfig) validate() error {
+	if c.Bandwidth < 0 || c.PerUploadBandwidth < 0 || c.OpsPerSecond < 0 || c.Burst < 0 {
+		return fmt.Errorf("rate-limit settings must not be negative")
+	}
+	return nil
+}
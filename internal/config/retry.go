@@ -0,0 +1,32 @@
+package config
+
+import "fmt"
+
+// RetryConfig 存储请求遇到临时性错误 (连接重置、超时、5xx、限流) 时的重试配置，对应 upload-settings.retry
+type RetryConfig struct {
+	// MaxAttempts 包括首次请求在内的最大尝试次数，默认 3，设为 1 关闭重试
+	MaxAttempts int `yaml:"max-attempts,omitempty"`
+	// InitialBackoff 首次重试前的等待时间 (毫秒)，默认 200
+	InitialBackoff int64 `yaml:"initial-backoff,omitempty"`
+	// MaxBackoff 等待时间的上限 (毫秒)，默认 5000
+	MaxBackoff int64 `yaml:"max-backoff,omitempty"`
+	// Multiplier 每次重试等待时间的倍数，默认 2
+	Multiplier float64 `yaml:"multiplier,omitempty"`
+	// Jitter 随机减少等待时间的比例 (0-1)，避免多个客户端同时重试，未设置时为 0.5，设为 0 关闭随机抖动
+	Jitter *float64 `yaml:"jitter,omitempty"`
+	// BufferSize 不可 Seek 的上传内容在内存中缓冲用于重试的大小 (MB)，超过后不再重试，默认 8
+	BufferSize int64 `yaml:"buffer-size,omitempty"`
+}
+
+func (c *RetryConfig) validate() error {
+	if c.MaxAttempts < 0 || c.InitialBackoff < 0 || c.MaxBackoff < 0 || c.BufferSize < 0 {
+		return fmt.Errorf("retry settings must not be negative")
+	}
+	if c.Multiplier != 0 && c.Multiplier < 1 {
+		return fmt.Errorf("retry multiplier must be at least 1")
+	}
+	if c.Jitter != nil && (*c.Jitter < 0 || *c.Jitter > 1) {
+		return fmt.Errorf("retry jitter must be between 0 and 1")
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package config
+
+// RoutingConfig 按文件属性将上传分发到不同 profile 的配置，对应 upload.type: routing
+type RoutingConfig struct {
+	// Rules 按顺序匹配的规则，第一条满足的规则决定使用的 profile
+	Rules []RoutingRule `yaml:"rules"`
+	// Default 没有规则匹配时使用的 profile，为空时返回错误
+	Default string `yaml:"default,omitempty"`
+}
+
+// RoutingRule 路由规则，未设置的条件不参与匹配，所有条件都满足时命中
+type RoutingRule struct {
+	// Backend 命中时使用的 profile 名称
+	Backend string `yaml:"backend"`
+	// MinSize 文件大小下限 (MB，包含)，大小未知时不匹配设置了大小范围的规则
+	MinSize int64 `yaml:"min-size,omitempty"`
+	// MaxSize 文件大小上限 (MB，不包含)
+	MaxSize int64 `yaml:"max-size,omitempty"`
+	// Extensions 文件扩展名，如 .jpg
+	Extensions []string `yaml:"extensions,omitempty"`
+	// MimeTypes 内容类型，支持 image/* 通配
+	MimeTypes []string `yaml:"mime-types,omitempty"`
+	// NamePrefix 上传时匹配传入的文件名，不参与按对象键的操作
+	NamePrefix string `yaml:"name-prefix,omitempty"`
+	// KeyPrefix 匹配对象键，上传时只匹配指定的对象键，生成的对象键以该前缀开头需要由 profile 的 key-template 保证
+	KeyPrefix string `yaml:"key-prefix,omitempty"`
+}
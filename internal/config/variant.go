@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// variantNamePattern 缩略图名称用在对象键中，只允许字母、数字、下划线和连字符
+var variantNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ImageVariant 上传 JPEG、PNG、GIF 图片时额外生成的缩略图，对应 upload-settings.variants 中的一项
+type ImageVariant struct {
+	// Name 缩略图名称，对象键为原图的键在扩展名前加上 _名称，如 a1b2.jpg 的 thumb 为 a1b2_thumb.jpg
+	Name string `yaml:"name"`
+	// Width 宽度 (像素)，fit 模式下为 0 表示按高度等比缩放
+	Width int `yaml:"width,omitempty"`
+	// Height 高度 (像素)，fit 模式下为 0 表示按宽度等比缩放
+	Height int `yaml:"height,omitempty"`
+	// Mode fit 等比缩放到宽高之内 (默认)，不放大；crop 等比缩放后居中裁剪为指定宽高
+	Mode string `yaml:"mode,omitempty"`
+	// Quality JPEG 压缩质量 (1-100)，默认 85
+	Quality int `yaml:"quality,omitempty"`
+}
+
+func validateVariants(variants []ImageVariant) error {
+	names := make(map[string]bool, len(variants))
+	for _, v := range variants {
+		if !variantNamePattern.MatchString(v.Name) {
+			return fmt.Errorf("invalid variant name: %q", v.Name)
+		}
+		if names[v.Name] {
+			return fmt.Errorf("duplicate variant name: %s", v.Name)
+		}
+		names[v.Name] = true
+		if v.Width < 0 || v.Height < 0 {
+			return fmt.Errorf("variant %s: width and height must not be negative", v.Name)
+		}
+		switch v.Mode {
+		case "", "fit":
+			if v.Width == 0 && v.Height == 0 {
+				return fmt.Errorf("variant %s: width or height is required", v.Name)
+			}
+		case "crop":
+			if v.Width == 0 || v.Height == 0 {
+				return fmt.Errorf("variant %s: crop requires both width and height", v.Name)
+			}
+		default:
+			return fmt.Errorf("variant %s: unsupported mode %q", v.Name, v.Mode)
+		}
+		if v.Quality < 0 || v.Quality > 100 {
+			return fmt.Errorf("variant %s: quality must be between 1 and 100", v.Name)
+		}
+	}
+	return nil
+}
@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 	"upload-util/internal/config"
 	"upload-util/internal/service"
 
@@ -10,8 +14,10 @@ import (
 )
 
 type UploadHandler struct {
+	config   *config.UploadConfig
 	factory  *service.UploadFactory
 	uploader service.Uploader
+	tus      *service.TusStore
 }
 
 type Response struct {
@@ -26,12 +32,46 @@ type UploadResponse struct {
 	Size     int64  `json:"size"`
 	MimeType string `json:"mime_type"`
 	Filename string `json:"filename"`
+	// SniffedType 根据文件内容识别出的类型
+	SniffedType string `json:"sniffed_type,omitempty"`
+	// Deduplicated 相同内容的文件已存在，未重复存储
+	Deduplicated bool `json:"deduplicated,omitempty"`
+	// Backend 按规则路由或故障转移时实际使用的 profile
+	Backend string `json:"backend,omitempty"`
+	// ETag 存储返回的 ETag
+	ETag string `json:"etag,omitempty"`
+	// Checksums 上传内容的 MD5、SHA-256 和 CRC64
+	Checksums *service.Checksums `json:"checksums,omitempty"`
+	// Attempts 存储临时故障重试后成功时的尝试次数
+	Attempts int `json:"attempts,omitempty"`
+	// Variants 上传图片时生成的缩略图
+	Variants []service.Variant `json:"variants,omitempty"`
 }
 
 type DeleteRequest struct {
 	Key string `json:"key" binding:"required"`
 }
 
+type DeleteManyRequest struct {
+	Keys []string `json:"keys" binding:"required,min=1"`
+}
+
+type DeletePrefixRequest struct {
+	Prefix string `json:"prefix" binding:"required"`
+	DryRun bool   `json:"dry_run"`
+}
+
+type CopyRequest struct {
+	Src string `json:"src" binding:"required"`
+	Dst string `json:"dst" binding:"required"`
+}
+
+type PresignRequest struct {
+	Filename string `json:"filename" binding:"required"`
+	Size     int64  `json:"size"`
+	Method   string `json:"method"`
+}
+
 type GetURLResponse struct {
 	URL string `json:"url"`
 	Key string `json:"key"`
@@ -43,9 +83,15 @@ func NewUploadHandler(cfg *config.UploadConfig) (*UploadHandler, error) {
 	if err != nil {
 		return nil, err
 	}
+	tus, err := service.NewTusStore(&cfg.Tus)
+	if err != nil {
+		return nil, err
+	}
 	return &UploadHandler{
+		config:   cfg,
 		factory:  factory,
 		uploader: uploader,
+		tus:      tus,
 	}, nil
 }
 
@@ -66,10 +112,11 @@ func (h *UploadHandler) Upload(c *gin.Context) {
 			return
 		}
 	}(file)
-	result, err := h.uploader.Upload(c.Request.Context(), file, header)
+	result, err := h.uploader.UploadReader(c.Request.Context(), file, header.Filename, header.Size, uploadOptions(c)...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Code:    http.StatusInternalServerError,
+		code := uploadErrorCode(err)
+		c.JSON(code, Response{
+			Code:    code,
 			Message: "上传文件失败" + err.Error(),
 		})
 		return
@@ -77,17 +124,49 @@ func (h *UploadHandler) Upload(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code:    http.StatusOK,
 		Message: "上传成功",
-		Data: UploadResponse{
-			URL:      result.URL,
-			Key:      result.Key,
-			Size:     result.Size,
-			MimeType: result.MimeType,
-			Filename: header.Filename,
-		},
+		Data:    newUploadResponse(result, header.Filename),
 	})
 	return
 }
 
+func newUploadResponse(result *service.UploadResult, filename string) UploadResponse {
+	return UploadResponse{
+		URL:          result.URL,
+		Key:          result.Key,
+		Size:         result.Size,
+		MimeType:     result.MimeType,
+		Filename:     filename,
+		SniffedType:  result.SniffedType,
+		Deduplicated: result.Deduplicated,
+		Backend:      result.Backend,
+		ETag:         result.ETag,
+		Checksums:    result.Checksums,
+		Attempts:     result.Attempts,
+		Variants:     result.Variants,
+	}
+}
+
+// uploadErrorCode 根据上传错误返回状态码，文件过大返回 413，扩展名或内容类型不允许返回 415，
+// 故障转移的所有存储都不可用时返回 503
+func uploadErrorCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrNoHealthyBackend):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, service.ErrFileTooLarge), errors.Is(err, service.ErrTooManyParts):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrContentTypeMismatch), errors.Is(err, service.ErrMimeTypeNotAllowed),
+		errors.Is(err, service.ErrExtensionNotAllowed):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// uploadOptions 读取单次上传的可选参数，请求头 X-User-ID 用于 key-template 中的 {user}
+func uploadOptions(c *gin.Context) []service.UploadOption {
+	return []service.UploadOption{service.WithUser(c.GetHeader("X-User-ID"))}
+}
+
 func (h *UploadHandler) UploadMultiple(c *gin.Context) {
 	form, err := c.MultipartForm()
 	if err != nil {
@@ -113,20 +192,13 @@ func (h *UploadHandler) UploadMultiple(c *gin.Context) {
 			errList = append(errList, "打开文件"+header.Filename+"失败"+err.Error())
 			continue
 		}
-		file.Close()
-		result, err := h.uploader.Upload(c.Request.Context(), file, header)
+		result, err := h.uploader.UploadReader(c.Request.Context(), file, header.Filename, header.Size, uploadOptions(c)...)
 		file.Close()
 		if err != nil {
 			errList = append(errList, "上传文件"+header.Filename+"失败: "+err.Error())
 			continue
 		}
-		results = append(results, UploadResponse{
-			URL:      result.URL,
-			Key:      result.Key,
-			Size:     result.Size,
-			MimeType: result.MimeType,
-			Filename: header.Filename,
-		})
+		results = append(results, newUploadResponse(result, header.Filename))
 	}
 	response := gin.H{
 		"success_count": len(results),
@@ -167,6 +239,57 @@ func (h *UploadHandler) Delete(c *gin.Context) {
 	})
 }
 
+// DeleteMany 批量删除，返回已删除的对象和逐个对象的错误
+func (h *UploadHandler) DeleteMany(c *gin.Context) {
+	var req DeleteManyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	result, err := h.uploader.DeleteMany(c.Request.Context(), req.Keys)
+	h.deleteResult(c, result, err)
+}
+
+// DeletePrefix 删除前缀下的所有对象，dry_run 为 true 时只返回将要删除的对象
+func (h *UploadHandler) DeletePrefix(c *gin.Context) {
+	var req DeletePrefixRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	result, err := h.uploader.DeletePrefix(c.Request.Context(), req.Prefix, req.DryRun)
+	h.deleteResult(c, result, err)
+}
+
+func (h *UploadHandler) deleteResult(c *gin.Context, result *service.DeleteResult, err error) {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    http.StatusInternalServerError,
+			Message: "删除文件失败: " + err.Error(),
+			Data:    result,
+		})
+		return
+	}
+	message := "删除成功"
+	switch {
+	case result.DryRun:
+		message = "预览成功，未删除任何文件"
+	case len(result.Errors) > 0:
+		message = "部分文件删除失败"
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    http.StatusOK,
+		Message: message,
+		Data:    result,
+	})
+}
+
 func (h *UploadHandler) GetURL(c *gin.Context) {
 	key := c.Query("key")
 	if key == "" {
@@ -176,8 +299,21 @@ func (h *UploadHandler) GetURL(c *gin.Context) {
 		})
 		return
 	}
+	// expires 为签名链接的有效期 (秒)，仅对私有存储生效
+	var opts []service.URLOption
+	if value := c.Query("expires"); value != "" {
+		expires, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || expires <= 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    http.StatusBadRequest,
+				Message: "参数 expires 必须为正整数 (秒)",
+			})
+			return
+		}
+		opts = append(opts, service.WithExpires(time.Duration(expires)*time.Second))
+	}
 
-	url, err := h.uploader.GetURL(c.Request.Context(), key)
+	url, err := h.uploader.GetURL(c.Request.Context(), key, opts...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    http.StatusInternalServerError,
@@ -195,13 +331,92 @@ func (h *UploadHandler) GetURL(c *gin.Context) {
 	})
 }
 
-func (h *UploadHandler) HealthCheck(c *gin.Context) {
+func (h *UploadHandler) Presign(c *gin.Context) {
+	var req PresignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	result, err := h.uploader.Presign(c.Request.Context(), &service.PresignRequest{
+		Filename: req.Filename,
+		Size:     req.Size,
+		Method:   req.Method,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    http.StatusBadRequest,
+			Message: "生成直传签名失败: " + err.Error(),
+		})
+		return
+	}
+	// 本地存储返回的是相对地址，补全为当前服务的地址
+	if strings.HasPrefix(result.URL, "/") {
+		result.URL = requestOrigin(c) + result.URL
+	}
 	c.JSON(http.StatusOK, Response{
 		Code:    http.StatusOK,
-		Message: "OK",
-		Data: gin.H{
-			"status":  "ok",
-			"service": "upload-util",
-		},
+		Message: "获取成功",
+		Data:    result,
 	})
 }
+
+// UploadDirect 本地存储的直传入口，PUT 直接发送文件内容，POST 提交包含 token 和 file 的表单。
+// 写入经过完整的上传器，限速、缩略图等与普通上传一致
+func (h *UploadHandler) UploadDirect(c *gin.Context) {
+	if _, ok := service.UnwrapUploader(h.uploader).(*service.LocalUploader); !ok {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    http.StatusNotFound,
+			Message: "当前存储不支持本地直传",
+		})
+		return
+	}
+
+	token := c.Query("token")
+	body := c.Request.Body
+	if c.Request.Method == http.MethodPost {
+		token = c.PostForm("token")
+		file, _, err := c.Request.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    http.StatusBadRequest,
+				Message: "获取文件失败" + err.Error(),
+			})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	result, err := service.UploadWithToken(c.Request.Context(), h.uploader, token, body)
+	if err != nil {
+		code := uploadErrorCode(err)
+		if errors.Is(err, service.ErrInvalidUploadToken) {
+			code = http.StatusForbidden
+		}
+		c.JSON(code, Response{
+			Code:    code,
+			Message: "上传文件失败" + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    http.StatusOK,
+		Message: "上传成功",
+		Data:    newUploadResponse(result, ""),
+	})
+}
+
+// requestOrigin 返回当前请求的协议和主机，用于拼接绝对地址
+func requestOrigin(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
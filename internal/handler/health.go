@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+	"upload-util/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	healthOK          = "ok"
+	healthDegraded    = "degraded"
+	healthUnavailable = "unavailable"
+)
+
+// ProfileHealth 一个上传接口中各存储后端的熔断状态
+type ProfileHealth struct {
+	Status   string                  `json:"status"`
+	Backends []service.BackendHealth `json:"backends,omitempty"`
+}
+
+// NewHealthCheck 返回健康检查接口，handlers 以 profile 名称为键，默认上传接口为 default。
+// 有后端熔断时状态为 degraded，所有上传接口的后端都已熔断时为 unavailable 并返回 503
+func NewHealthCheck(handlers map[string]*UploadHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profiles := make(map[string]ProfileHealth, len(handlers))
+		unavailable := 0
+		status := healthOK
+		for name, h := range handlers {
+			health := profileHealth(service.UploaderHealth(h.uploader))
+			profiles[name] = health
+			if health.Status != healthOK {
+				status = healthDegraded
+			}
+			if health.Status == healthUnavailable {
+				unavailable++
+			}
+		}
+		code := http.StatusOK
+		if len(handlers) > 0 && unavailable == len(handlers) {
+			status = healthUnavailable
+			code = http.StatusServiceUnavailable
+		}
+		data := gin.H{
+			"status":  status,
+			"service": "upload-util",
+		}
+		if len(profiles) > 0 {
+			data["profiles"] = profiles
+		}
+		c.JSON(code, Response{
+			Code:    code,
+			Message: http.StatusText(code),
+			Data:    data,
+		})
+	}
+}
+
+// profileHealth 没有后端熔断时为 ok，全部熔断时为 unavailable，否则为 degraded
+func profileHealth(backends []service.BackendHealth) ProfileHealth {
+	open := 0
+	for _, backend := range backends {
+		if backend.State != "closed" {
+			open++
+		}
+	}
+	status := healthOK
+	switch {
+	case open == 0:
+	case open == len(backends):
+		status = healthUnavailable
+	default:
+		status = healthDegraded
+	}
+	return ProfileHealth{Status: status, Backends: backends}
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"upload-util/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Download 通过服务端读取对象内容，私有存储桶中的文件无需公开地址即可访问。
+// 支持单个 Range 请求，多段范围按完整内容返回
+func (h *UploadHandler) Download(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    http.StatusBadRequest,
+			Message: "参数 key 不能为空",
+		})
+		return
+	}
+
+	var opts []service.OpenOption
+	if offset, length, ok := parseRange(c.GetHeader("Range")); ok {
+		opts = append(opts, service.WithRange(offset, length))
+	}
+	object, err := h.uploader.Open(c.Request.Context(), key, opts...)
+	if err != nil {
+		code := http.StatusInternalServerError
+		message := "读取文件失败: " + err.Error()
+		switch {
+		case errors.Is(err, service.ErrObjectNotFound):
+			code, message = http.StatusNotFound, "文件不存在"
+		case errors.Is(err, service.ErrInvalidRange):
+			code, message = http.StatusRequestedRangeNotSatisfiable, "请求范围无效"
+		}
+		c.JSON(code, Response{
+			Code:    code,
+			Message: message,
+		})
+		return
+	}
+	defer object.Close()
+
+	headers := map[string]string{
+		"Accept-Ranges": "bytes",
+	}
+	if object.ETag != "" {
+		headers["ETag"] = `"` + object.ETag + `"`
+	}
+	code := http.StatusOK
+	if object.Ranged() {
+		code = http.StatusPartialContent
+		headers["Content-Range"] = fmt.Sprintf("bytes %d-%d/%d", object.Offset, object.Offset+object.Length-1, object.Size)
+	}
+	contentType := object.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(code, object.Length, contentType, object, headers)
+}
+
+// Stat 通过响应头返回文件大小、类型、修改时间、ETag，自定义元数据以 X-Meta- 为前缀
+func (h *UploadHandler) Stat(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	info, err := h.uploader.Stat(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, service.ErrObjectNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	if info.ContentType != "" {
+		c.Header("Content-Type", info.ContentType)
+	}
+	if info.ETag != "" {
+		c.Header("ETag", `"`+info.ETag+`"`)
+	}
+	if !info.LastModified.IsZero() {
+		c.Header("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	for name, value := range info.Metadata {
+		c.Header("X-Meta-"+name, value)
+	}
+	c.Status(http.StatusOK)
+}
+
+// List 按前缀分页列举对象，参数 prefix、delimiter、cursor、limit
+func (h *UploadHandler) List(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    http.StatusBadRequest,
+				Message: "参数 limit 无效",
+			})
+			return
+		}
+	}
+	result, err := h.uploader.List(c.Request.Context(), c.Query("prefix"), c.Query("delimiter"), c.Query("cursor"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    http.StatusInternalServerError,
+			Message: "列举文件失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    http.StatusOK,
+		Message: "获取成功",
+		Data:    result,
+	})
+}
+
+// Copy 在存储后端内复制对象
+func (h *UploadHandler) Copy(c *gin.Context) {
+	h.copyObject(c, "复制", h.uploader.Copy)
+}
+
+// Move 移动或重命名对象
+func (h *UploadHandler) Move(c *gin.Context) {
+	h.copyObject(c, "移动", h.uploader.Move)
+}
+
+func (h *UploadHandler) copyObject(c *gin.Context, action string, fn func(ctx context.Context, src, dst string) error) {
+	var req CopyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    http.StatusBadRequest,
+			Message: "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if err := fn(c.Request.Context(), req.Src, req.Dst); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, service.ErrObjectNotFound) {
+			code = http.StatusNotFound
+		}
+		c.JSON(code, Response{
+			Code:    code,
+			Message: action + "文件失败: " + err.Error(),
+		})
+		return
+	}
+	url, err := h.uploader.GetURL(c.Request.Context(), req.Dst)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    http.StatusInternalServerError,
+			Message: "获取URL失败: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    http.StatusOK,
+		Message: action + "成功",
+		Data: GetURLResponse{
+			URL: url,
+			Key: req.Dst,
+		},
+	})
+}
+
+// parseRange 解析单个 "bytes=start-end"、"bytes=start-" 或 "bytes=-suffix" 范围
+func parseRange(header string) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	start, end, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false
+	}
+	if start == "" {
+		suffix, err := strconv.ParseInt(end, 10, 64)
+		if err != nil || suffix <= 0 {
+			return 0, 0, false
+		}
+		return -suffix, 0, true
+	}
+	offset, err := strconv.ParseInt(start, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, false
+	}
+	if end == "" {
+		return offset, 0, true
+	}
+	last, err := strconv.ParseInt(end, 10, 64)
+	if err != nil || last < offset {
+		return 0, 0, false
+	}
+	return offset, last - offset + 1, true
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"upload-util/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tus 1.0 断点续传协议，参考 https://tus.io/protocols/resumable-upload
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+	tusOctetType  = "application/offset+octet-stream"
+)
+
+// TusOptions 返回服务端支持的协议版本和扩展
+func (h *UploadHandler) TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	if maxSize := h.maxFileSize(); maxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(maxSize, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreate 创建上传，返回后续 PATCH 使用的地址
+func (h *UploadHandler) TusCreate(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		h.tusError(c, http.StatusBadRequest, "Upload-Length 无效")
+		return
+	}
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		h.tusError(c, http.StatusBadRequest, "Upload-Metadata 无效: "+err.Error())
+		return
+	}
+
+	upload := &service.TusUpload{Size: size, Metadata: metadata}
+	// 提前校验大小和扩展名，避免客户端传完才失败
+	if maxSize := h.maxFileSize(); maxSize > 0 && size > maxSize {
+		h.tusError(c, http.StatusRequestEntityTooLarge, "文件大小超出限制")
+		return
+	}
+	header := &multipart.FileHeader{Filename: upload.Filename(), Size: size}
+	if err := service.ValidateFile(header, &h.config.UploadSettings); err != nil {
+		h.tusError(c, http.StatusBadRequest, "文件校验失败: "+err.Error())
+		return
+	}
+
+	upload, err = h.tus.Create(size, metadata)
+	if err != nil {
+		h.tusError(c, http.StatusInternalServerError, "创建上传失败: "+err.Error())
+		return
+	}
+	c.Header("Location", strings.TrimRight(c.Request.URL.Path, "/")+"/"+upload.ID)
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// TusHead 返回当前偏移量，客户端据此从断点继续上传
+func (h *UploadHandler) TusHead(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+	upload, err := h.tus.Get(c.Param("id"))
+	if err != nil {
+		h.tusStoreError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	setTusResultHeaders(c, upload)
+	c.Status(http.StatusOK)
+}
+
+// TusPatch 从指定偏移量追加数据，数据接收完毕后转存到配置的存储
+func (h *UploadHandler) TusPatch(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+	if c.ContentType() != tusOctetType {
+		h.tusError(c, http.StatusUnsupportedMediaType, "Content-Type 必须为 "+tusOctetType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		h.tusError(c, http.StatusBadRequest, "Upload-Offset 无效")
+		return
+	}
+
+	id := c.Param("id")
+	if err := h.tus.Lock(id); err != nil {
+		h.tusStoreError(c, err)
+		return
+	}
+	defer h.tus.Unlock(id)
+
+	upload, err := h.tus.Get(id)
+	if err != nil {
+		h.tusStoreError(c, err)
+		return
+	}
+	if upload.Result == nil {
+		upload, err = h.tus.Append(upload, offset, c.Request.Body)
+		if err != nil {
+			h.tusStoreError(c, err)
+			return
+		}
+		// 数据接收完毕，转存到存储后端；失败时客户端可用空 PATCH 重试
+		if upload.Completed() {
+			if err := h.finishTusUpload(c, upload); err != nil {
+				h.tusError(c, uploadErrorCode(err), "上传文件失败: "+err.Error())
+				return
+			}
+		}
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	setTusResultHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// TusDelete 终止上传并删除已接收的数据
+func (h *UploadHandler) TusDelete(c *gin.Context) {
+	if !h.checkTusResumable(c) {
+		return
+	}
+	id := c.Param("id")
+	if err := h.tus.Lock(id); err != nil {
+		h.tusStoreError(c, err)
+		return
+	}
+	defer h.tus.Unlock(id)
+
+	if err := h.tus.Terminate(id); err != nil {
+		h.tusStoreError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UploadHandler) finishTusUpload(c *gin.Context, upload *service.TusUpload) error {
+	file, err := h.tus.Open(upload)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	result, err := h.uploader.UploadReader(c.Request.Context(), file, upload.Filename(), upload.Size, uploadOptions(c)...)
+	if err != nil {
+		return err
+	}
+	return h.tus.Finish(upload, result)
+}
+
+func (h *UploadHandler) maxFileSize() int64 {
+	return h.config.UploadSettings.MaxFileSize * 1024 * 1024
+}
+
+func (h *UploadHandler) checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		h.tusError(c, http.StatusPreconditionFailed, "不支持的 tus 协议版本")
+		return false
+	}
+	return true
+}
+
+func (h *UploadHandler) tusStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTusUploadNotFound):
+		h.tusError(c, http.StatusNotFound, "上传不存在或已过期")
+	case errors.Is(err, service.ErrTusOffsetMismatch):
+		h.tusError(c, http.StatusConflict, "Upload-Offset 与服务端偏移量不一致")
+	case errors.Is(err, service.ErrTusUploadLocked):
+		h.tusError(c, http.StatusLocked, "上传正在被其他请求写入")
+	default:
+		h.tusError(c, http.StatusInternalServerError, "处理上传失败: "+err.Error())
+	}
+}
+
+func (h *UploadHandler) tusError(c *gin.Context, code int, message string) {
+	if c.Request.Method == http.MethodHead {
+		c.Status(code)
+		return
+	}
+	c.JSON(code, Response{
+		Code:    code,
+		Message: message,
+	})
+}
+
+// setTusResultHeaders 上传转存完成后，通过响应头返回对象键和访问地址
+func setTusResultHeaders(c *gin.Context, upload *service.TusUpload) {
+	if upload.Result == nil {
+		return
+	}
+	c.Header("Upload-Key", upload.Result.Key)
+	c.Header("Upload-URL", upload.Result.URL)
+}
+
+// parseTusMetadata 解析 Upload-Metadata，格式为逗号分隔的 "key base64(value)"
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, errors.New("invalid metadata pair: " + pair)
+		}
+	}
+	return metadata, nil
+}
@@ -0,0 +1,234 @@
+// Package progress 在命令行中显示上传进度条
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"upload-util/internal/service"
+)
+
+const (
+	refreshInterval = 200 * time.Millisecond
+	barWidth        = 24
+	nameWidth       = 24
+)
+
+// Bars 在终端底部同时显示多个上传的进度条，每个上传一行，最后一行为汇总状态。
+// 输出不是终端时不显示进度条，Printf 的内容照常输出
+type Bars struct {
+	mu       sync.Mutex
+	w        io.Writer
+	tty      bool
+	bars     []*Bar
+	status   string
+	lines    int
+	start    time.Time
+	finished int64
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Bar 单个上传的进度条
+type Bar struct {
+	owner    *Bars
+	name     string
+	progress service.Progress
+}
+
+// New 创建进度条并开始定时刷新，使用完后需要调用 Stop
+func New(f *os.File) *Bars {
+	b := &Bars{
+		w:     f,
+		tty:   isTerminal(f),
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go b.refresh()
+	return b
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+func (b *Bars) refresh() {
+	defer close(b.done)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.mu.Lock()
+			b.render()
+			b.mu.Unlock()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Add 添加一个进度条，total 为 -1 表示大小未知
+func (b *Bars) Add(name string, total int64) *Bar {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bar := &Bar{owner: b, name: name, progress: service.Progress{Total: total}}
+	b.bars = append(b.bars, bar)
+	return bar
+}
+
+// Update 更新进度，可以直接作为 upload.WithProgress 的回调
+func (bar *Bar) Update(p service.Progress) {
+	bar.owner.mu.Lock()
+	defer bar.owner.mu.Unlock()
+	bar.progress = p
+}
+
+// Remove 上传结束后移除进度条，已发送的字节数计入汇总
+func (b *Bars) Remove(bar *Bar) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, existing := range b.bars {
+		if existing == bar {
+			b.bars = append(b.bars[:i], b.bars[i+1:]...)
+			b.finished += bar.progress.Transferred
+			break
+		}
+	}
+}
+
+// SetStatus 设置进度条下方的汇总状态
+func (b *Bars) SetStatus(format string, args ...any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.status = fmt.Sprintf(format, args...)
+}
+
+// Printf 在进度条上方输出一行内容
+func (b *Bars) Printf(format string, args ...any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clear()
+	fmt.Fprintf(b.w, format, args...)
+	b.render()
+}
+
+// Stop 停止刷新并清除进度条
+func (b *Bars) Stop() {
+	close(b.stop)
+	<-b.done
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clear()
+}
+
+// clear 将光标移回进度条的第一行并清除到屏幕末尾
+func (b *Bars) clear() {
+	if b.tty && b.lines > 0 {
+		fmt.Fprintf(b.w, "\033[%dA\r\033[J", b.lines)
+	}
+	b.lines = 0
+}
+
+func (b *Bars) render() {
+	if !b.tty {
+		return
+	}
+	var buf strings.Builder
+	if b.lines > 0 {
+		fmt.Fprintf(&buf, "\033[%dA\r\033[J", b.lines)
+	}
+	lines := 0
+	transferred := b.finished
+	for _, bar := range b.bars {
+		buf.WriteString(bar.line())
+		buf.WriteByte('\n')
+		transferred += bar.progress.Transferred
+		lines++
+	}
+	if b.status != "" {
+		elapsed := time.Since(b.start).Seconds()
+		fmt.Fprintf(&buf, "%s  已发送 %s (%s/s)\n", b.status, formatBytes(transferred), formatBytes(int64(float64(transferred)/elapsed)))
+		lines++
+	}
+	b.lines = lines
+	io.WriteString(b.w, buf.String())
+}
+
+// line 进度条的一行，如 photo.jpg [=========>      ]  45.2%  12.3 MB/27.2 MB  3.1 MB/s  ETA 00:05
+func (bar *Bar) line() string {
+	p := bar.progress
+	name := padName(bar.name)
+	if p.Total < 0 {
+		return fmt.Sprintf("%s %10s  %10s/s", name, formatBytes(p.Transferred), formatBytes(int64(p.Rate)))
+	}
+	ratio := 1.0
+	if p.Total > 0 {
+		ratio = min(float64(p.Transferred)/float64(p.Total), 1)
+	}
+	filled := int(ratio * barWidth)
+	meter := strings.Repeat("=", filled)
+	if filled < barWidth {
+		meter += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return fmt.Sprintf("%s [%s] %5.1f%%  %s/%s  %s/s  ETA %s", name, meter, ratio*100,
+		formatBytes(p.Transferred), formatBytes(p.Total), formatBytes(int64(p.Rate)), formatETA(p.ETA()))
+}
+
+// padName 截断或补齐文件名到固定的显示宽度，中文等宽字符按两列计算
+func padName(name string) string {
+	width := 0
+	for _, r := range name {
+		width += runeWidth(r)
+	}
+	if width <= nameWidth {
+		return name + strings.Repeat(" ", nameWidth-width)
+	}
+	// 超出时截断并以省略号结尾
+	var buf strings.Builder
+	width = 0
+	for _, r := range name {
+		if width+runeWidth(r) > nameWidth-1 {
+			break
+		}
+		buf.WriteRune(r)
+		width += runeWidth(r)
+	}
+	return buf.String() + "…" + strings.Repeat(" ", nameWidth-1-width)
+}
+
+func runeWidth(r rune) int {
+	if r >= 0x1100 {
+		return 2
+	}
+	return 1
+}
+
+func formatETA(eta time.Duration) string {
+	if eta < 0 {
+		return "--:--"
+	}
+	seconds := int(eta.Round(time.Second).Seconds())
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,53 @@
+package progress
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"upload-util/internal/service"
+)
+
+func TestBarLine(t *testing.T) {
+	bar := &Bar{name: "photo.jpg", progress: service.Progress{Transferred: 512 * 1024, Total: 1024 * 1024, Rate: 256 * 1024}}
+	line := bar.line()
+	for _, want := range []string{"photo.jpg", "[============>           ]", " 50.0%", "512.0 KB/1.0 MB", "256.0 KB/s", "ETA 00:02"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in %q", want, line)
+		}
+	}
+
+	bar = &Bar{name: "stdin", progress: service.Progress{Transferred: 2048, Total: -1, Rate: 1024}}
+	if line := bar.line(); !strings.Contains(line, "2.0 KB") || strings.Contains(line, "ETA") {
+		t.Errorf("unexpected line for unknown size: %q", line)
+	}
+}
+
+func TestPadName(t *testing.T) {
+	if got := padName("a.jpg"); len(got) != nameWidth {
+		t.Errorf("expected padded name of width %d, got %q", nameWidth, got)
+	}
+	// 中文按两列计算，截断后显示宽度不变
+	got := padName("这是一个非常非常非常长的文件名.jpg")
+	if !strings.HasSuffix(strings.TrimRight(got, " "), "…") {
+		t.Errorf("expected truncated name, got %q", got)
+	}
+	width := 0
+	for _, r := range got {
+		if r == '…' {
+			width++
+			continue
+		}
+		width += runeWidth(r)
+	}
+	if width != nameWidth {
+		t.Errorf("expected width %d, got %d (%q)", nameWidth, width, got)
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	for eta, want := range map[time.Duration]string{-1: "--:--", 75 * time.Second: "01:15", 2*time.Hour + 5*time.Second: "2:00:05"} {
+		if got := formatETA(eta); got != want {
+			t.Errorf("formatETA(%s) = %q, want %q", eta, got, want)
+		}
+	}
+}
@@ -16,23 +16,38 @@ func SetupRouter(cfg *config.UploadConfig) (*gin.Engine, error) {
 	r.Use(middleware.Recovery())
 	r.Use(middleware.Cors())
 
-	uploadHandler, err := handler.NewUploadHandler(cfg)
-	if err != nil {
-		return nil, err
-	}
 	api := r.Group("/api/v1")
 	{
-		upload := api.Group("/upload")
-		{
-			upload.POST("/file", uploadHandler.Upload)
-			upload.POST("files", uploadHandler.UploadMultiple)
-			upload.GET("/url", uploadHandler.GetURL)
-			upload.DELETE("/file", uploadHandler.Delete)
+		// 健康检查汇总各上传接口的后端熔断状态
+		handlers := make(map[string]*handler.UploadHandler)
+
+		// 只配置了 profiles 时不提供默认的上传接口
+		if cfg.Upload.Type != "" || len(profileNames(cfg)) == 0 {
+			uploadHandler, err := handler.NewUploadHandler(cfg)
+			if err != nil {
+				return nil, err
+			}
+			registerUploadRoutes(api.Group("/upload"), uploadHandler)
+			handlers["default"] = uploadHandler
+		}
+
+		// 命名 profile: /api/v1/{profile}/upload/...，服务端指定了 -profile 时不再注册
+		for _, name := range profileNames(cfg) {
+			profileCfg, err := cfg.Profile(name)
+			if err != nil {
+				return nil, err
+			}
+			profileHandler, err := handler.NewUploadHandler(profileCfg)
+			if err != nil {
+				return nil, err
+			}
+			registerUploadRoutes(api.Group("/"+name+"/upload"), profileHandler)
+			handlers[name] = profileHandler
 		}
 
 		system := api.Group("/system")
 		{
-			system.GET("/health", uploadHandler.HealthCheck)
+			system.GET("/health", handler.NewHealthCheck(handlers))
 		}
 	}
 	r.GET("/", func(c *gin.Context) {
@@ -40,3 +55,36 @@ func SetupRouter(cfg *config.UploadConfig) (*gin.Engine, error) {
 	})
 	return r, nil
 }
+
+func profileNames(cfg *config.UploadConfig) []string {
+	if cfg.ProfileName != "" {
+		return nil
+	}
+	return cfg.ProfileNames()
+}
+
+func registerUploadRoutes(upload *gin.RouterGroup, uploadHandler *handler.UploadHandler) {
+	upload.POST("/file", uploadHandler.Upload)
+	upload.POST("files", uploadHandler.UploadMultiple)
+	upload.GET("/url", uploadHandler.GetURL)
+	upload.GET("/file", uploadHandler.Download)
+	upload.HEAD("/file", uploadHandler.Stat)
+	upload.GET("/list", uploadHandler.List)
+	upload.POST("/copy", uploadHandler.Copy)
+	upload.POST("/move", uploadHandler.Move)
+	upload.DELETE("/file", uploadHandler.Delete)
+	upload.DELETE("/files", uploadHandler.DeleteMany)
+	upload.DELETE("/prefix", uploadHandler.DeletePrefix)
+
+	// 客户端直传
+	upload.POST("/presign", uploadHandler.Presign)
+	upload.PUT("/direct", uploadHandler.UploadDirect)
+	upload.POST("/direct", uploadHandler.UploadDirect)
+
+	// tus 断点续传
+	upload.OPTIONS("/tus", uploadHandler.TusOptions)
+	upload.POST("/tus", uploadHandler.TusCreate)
+	upload.HEAD("/tus/:id", uploadHandler.TusHead)
+	upload.PATCH("/tus/:id", uploadHandler.TusPatch)
+	upload.DELETE("/tus/:id", uploadHandler.TusDelete)
+}
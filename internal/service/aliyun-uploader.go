@@ -1,9 +1,13 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"net/http"
 	"strings"
 	"upload-util/internal/config"
 
@@ -39,25 +43,72 @@ func NewAliyunOSSUploader(config *config.AliyunOSSConfig, settings *config.Uploa
 }
 
 func (u *AliyunUploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	return u.UploadReader(ctx, file, header.Filename, header.Size)
+}
+
+// UploadReader 从任意 io.Reader 上传，size 为 -1 时以分片方式流式上传
+func (u *AliyunUploader) UploadReader(ctx context.Context, file io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	options := newUploadOptions(opts)
+	header := &multipart.FileHeader{Filename: name, Size: size}
+
 	// 验证文件
-	if err := validateFile(header, u.settings); err != nil {
+	if err := options.validate(header, u.settings); err != nil {
 		return nil, fmt.Errorf("file validation failed: %w", err)
 	}
+	file = options.limitSize(file, size, u.settings)
+	// 内容寻址策略先计算哈希，由哈希生成对象键
+	content, err := hashContent(file, size, u.settings, options)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	file, size = content.Reader, content.size
+	// 识别内容的真实类型，拒绝与扩展名不符的文件
+	file, sniffed, err := options.sniff(file, name, u.settings)
+	if err != nil {
+		return nil, err
+	}
 
 	// 生成文件名和对象键
-	filename := generateFileName(header, u.settings)
-	objectKey := buildObjectKey(filename, u.config.PathPrefix)
-
-	// 设置上传选项
-	options := []oss.Option{
-		oss.ContentType(getMimeType(filename)),
-		oss.ContentLength(header.Size),
+	objectKey := options.Key
+	if objectKey == "" {
+		objectKey = buildObjectKey(generateFileName(header, u.settings, content.sum, options.User), u.config.PathPrefix)
+	}
+	contentType := options.contentType(name)
+	if result, err := findDuplicate(ctx, u, content, objectKey, contentType, sniffed); result != nil || err != nil {
+		return result, err
 	}
 
-	// 上传文件
-	err := u.bucket.PutObject(objectKey, file, options...)
+	// 计算校验和，可 Seek 的输入携带 Content-MD5
+	source, err := newChecksumSource(ctx, file, size, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to upload file to aliyun oss: %w", err)
+		return nil, err
+	}
+
+	// 上传文件，超过阈值时使用分片上传
+	var etag string
+	if shouldUseMultipart(size, u.settings) {
+		etag, err = u.multipartUpload(ctx, objectKey, source, contentType, options.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to multipart upload file to aliyun oss: %w", err)
+		}
+	} else {
+		var respHeader http.Header
+		putOptions := append([]oss.Option{
+			oss.ContentType(contentType),
+			oss.ContentLength(size),
+			oss.GetResponseHeader(&respHeader),
+		}, ossMetaOptions(options.Metadata)...)
+		if contentMD5 := source.ContentMD5(); contentMD5 != "" {
+			putOptions = append(putOptions, oss.ContentMD5(contentMD5))
+		}
+		if err := u.bucket.PutObject(objectKey, source.Reader, putOptions...); err != nil {
+			return nil, fmt.Errorf("failed to upload file to aliyun oss: %w", err)
+		}
+		etag = respHeader.Get("ETag")
+		if err := verifyUpload(ctx, u, objectKey, source, etag, respHeader.Get("X-Oss-Hash-Crc64ecma")); err != nil {
+			return nil, err
+		}
 	}
 
 	// 生成访问 URL
@@ -67,14 +118,68 @@ func (u *AliyunUploader) Upload(ctx context.Context, file multipart.File, header
 	}
 
 	return &UploadResult{
-		URL:      url,
-		Key:      objectKey,
-		Size:     header.Size,
-		MimeType: getMimeType(filename),
+		URL:         url,
+		Key:         objectKey,
+		Size:        source.Size(),
+		MimeType:    contentType,
+		SniffedType: sniffed,
+		ETag:        strings.Trim(etag, `"`),
+		Checksums:   source.Checksums(),
 	}, nil
 
 }
 
+// multipartUpload 分片上传，每个分片携带 Content-MD5，返回合并后对象的 ETag
+func (u *AliyunUploader) multipartUpload(ctx context.Context, objectKey string, file io.Reader, contentType string, metadata map[string]string) (string, error) {
+	// 当前版本的 SDK 不支持 context，每个请求前检查 ctx，分片内容通过 contextReader 读取，
+	// ctx 被取消时正在发送的分片立即中止
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	imur, err := u.bucket.InitiateMultipartUpload(objectKey, append([]oss.Option{oss.ContentType(contentType)}, ossMetaOptions(metadata)...)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	var parts []oss.UploadPart
+	_, err = uploadLimitedParts(ctx, file, partSize(u.settings), func(partNumber int, part *bytes.Reader) error {
+		contentMD5, err := partMD5(part)
+		if err != nil {
+			return err
+		}
+		uploaded, err := u.bucket.UploadPart(imur, &contextReader{ctx: ctx, r: part}, part.Size(), partNumber, oss.ContentMD5(contentMD5))
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+		}
+		parts = append(parts, uploaded)
+		return nil
+	})
+	var completed oss.CompleteMultipartUploadResult
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		if completed, err = u.bucket.CompleteMultipartUpload(imur, parts); err != nil {
+			err = fmt.Errorf("failed to complete multipart upload: %w", err)
+		}
+	}
+	if err != nil {
+		// 中止未完成的分片上传，避免残留分片占用存储空间
+		_ = u.bucket.AbortMultipartUpload(imur)
+		return "", err
+	}
+	return completed.ETag, nil
+}
+
+// ossMetaOptions 将自定义元数据转换为 x-oss-meta-* 请求头
+func ossMetaOptions(metadata map[string]string) []oss.Option {
+	options := make([]oss.Option, 0, len(metadata))
+	for name, value := range metadata {
+		options = append(options, oss.Meta(name, value))
+	}
+	return options
+}
+
 func (u *AliyunUploader) Delete(ctx context.Context, key string) error {
 	err := u.bucket.DeleteObject(key)
 	if err != nil {
@@ -83,18 +188,154 @@ func (u *AliyunUploader) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
-func (u *AliyunUploader) GetURL(ctx context.Context, key string) (string, error) {
-	if u.config.Domain != "" {
+// Open 读取对象内容，可通过 WithRange 只读取一部分
+func (u *AliyunUploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	options := newOpenOptions(opts)
+	var ossOptions []oss.Option
+	if rangeHeader := options.rangeHeader(); rangeHeader != "" {
+		ossOptions = append(ossOptions, oss.NormalizedRange(strings.TrimPrefix(rangeHeader, "bytes=")))
+	}
+	result, err := u.bucket.DoGetObject(&oss.GetObjectRequest{ObjectKey: key}, ossOptions)
+	if err != nil {
+		if serviceErr, ok := err.(oss.ServiceError); ok {
+			err = objectError(serviceErr.StatusCode, err)
+		}
+		return nil, fmt.Errorf("failed to get object from aliyun oss: %w", err)
+	}
+	return newObjectReaderFromHeader(result.Response, key, result.Response.Headers), nil
+}
+
+// Stat 获取对象元数据，对象不存在时返回 ErrObjectNotFound
+func (u *AliyunUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	header, err := u.bucket.GetObjectDetailedMeta(key)
+	if err != nil {
+		if serviceErr, ok := err.(oss.ServiceError); ok {
+			err = objectError(serviceErr.StatusCode, err)
+		}
+		return nil, fmt.Errorf("failed to stat object from aliyun oss: %w", err)
+	}
+	return newObjectInfoFromHeader(key, header, oss.HTTPHeaderOssMetaPrefix), nil
+}
+
+// List 按前缀列举对象，cursor 为上一页返回的 NextCursor
+func (u *AliyunUploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	options := []oss.Option{oss.Prefix(prefix), oss.MaxKeys(listLimit(limit))}
+	if delimiter != "" {
+		options = append(options, oss.Delimiter(delimiter))
+	}
+	if cursor != "" {
+		options = append(options, oss.Marker(cursor))
+	}
+	output, err := u.bucket.ListObjects(options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects from aliyun oss: %w", err)
+	}
+	result := &ListResult{
+		Objects:        make([]ObjectInfo, 0, len(output.Objects)),
+		CommonPrefixes: output.CommonPrefixes,
+		Truncated:      output.IsTruncated,
+	}
+	for _, object := range output.Objects {
+		result.Objects = append(result.Objects, ObjectInfo{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+			ETag:         strings.Trim(object.ETag, `"`),
+		})
+	}
+	result.NextCursor = nextCursor(output.IsTruncated, output.NextMarker, result)
+	return result, nil
+}
+
+// Copy 服务端复制对象，数据不经过本服务
+func (u *AliyunUploader) Copy(ctx context.Context, src, dst string) error {
+	if err := checkCopyKeys(src, dst); err != nil {
+		return err
+	}
+	if _, err := u.bucket.CopyObject(src, dst); err != nil {
+		if serviceErr, ok := err.(oss.ServiceError); ok {
+			err = objectError(serviceErr.StatusCode, err)
+		}
+		return fmt.Errorf("failed to copy object in aliyun oss: %w", err)
+	}
+	return nil
+}
+
+// Move 服务端复制后删除源对象
+func (u *AliyunUploader) Move(ctx context.Context, src, dst string) error {
+	return moveObject(ctx, u, src, dst)
+}
+
+// DeleteMany 批量删除对象，每批最多 1000 个
+func (u *AliyunUploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	return deleteBatches(ctx, keys, func(batch []string, result *DeleteResult) error {
+		output, err := u.bucket.DeleteObjects(batch, oss.DeleteObjectsQuiet(false))
+		if err != nil {
+			return err
+		}
+		markUndeleted(batch, output.DeletedObjects, result)
+		return nil
+	})
+}
+
+// DeletePrefix 删除前缀下的所有对象，dryRun 时只列出将要删除的对象
+func (u *AliyunUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	return deletePrefix(ctx, u, prefix, dryRun)
+}
+
+// GetURL 返回对象的访问地址。私有模式或未配置自定义域名时返回带有效期的签名链接，
+// 有效期可通过 WithExpires 指定
+func (u *AliyunUploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	if u.config.Domain != "" && !u.config.Private {
 		return fmt.Sprintf("%s/%s", strings.TrimRight(u.config.Domain, "/"), key), nil
 	}
 	//生成签名url
-	expireTime := u.config.SignURLExpire
-	if expireTime <= 0 {
-		expireTime = 3600
-	}
-	signUrl, err := u.bucket.SignURL(key, oss.HTTPGet, 3600)
+	expires := newURLOptions(opts).expires(u.config.SignURLExpire)
+	signUrl, err := u.bucket.SignURL(key, oss.HTTPGet, int64(expires.Seconds()))
 	if err != nil {
 		return "", fmt.Errorf("failed to get sign url: %w", err)
 	}
 	return signUrl, nil
 }
+
+func (u *AliyunUploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	params, err := preparePresign(req, u.settings, u.config.PathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.method == http.MethodPut {
+		signURL, err := u.bucket.SignURL(params.key, oss.HTTPPut, int64(params.expire.Seconds()), oss.ContentType(params.contentType))
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign put url: %w", err)
+		}
+		return params.result(signURL), nil
+	}
+
+	policy, err := encodePostPolicy(params.expires, params.postConditions(u.config.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	result := params.result(u.bucketURL())
+	result.Fields = map[string]string{
+		"key":                   params.key,
+		"Content-Type":          params.contentType,
+		"OSSAccessKeyId":        u.config.AccessKeyID,
+		"policy":                policy,
+		"Signature":             base64.StdEncoding.EncodeToString(hmacSHA1([]byte(u.config.AccessKeySecret), []byte(policy))),
+		"success_action_status": "200",
+	}
+	return result, nil
+}
+
+func (u *AliyunUploader) bucketURL() string {
+	protocol := "https"
+	if !u.config.UseSSL {
+		protocol = "http"
+	}
+	return fmt.Sprintf("%s://%s.%s", protocol, u.config.Bucket, u.config.Endpoint)
+}
+
+func (u *AliyunUploader) pathPrefix() string {
+	return u.config.PathPrefix
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrChecksumMismatch 存储返回的 ETag、CRC64 或迁移后的内容与本地计算的校验和不一致
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// crc64Table 与阿里云 OSS、腾讯云 COS 的 x-*-hash-crc64ecma 使用相同的多项式
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// md5ETag 非分片上传且未使用 KMS 加密时 ETag 即为内容的 MD5
+var md5ETag = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// ETagMD5 去掉 ETag 两端的引号，ETag 是内容的 MD5 时返回小写的十六进制 MD5，
+// 分片上传等情况下 ETag 不是 MD5，返回 false
+func ETagMD5(etag string) (string, bool) {
+	etag = strings.Trim(etag, `"`)
+	if !md5ETag.MatchString(etag) {
+		return "", false
+	}
+	return strings.ToLower(etag), true
+}
+
+// Checksums 上传内容的校验和
+type Checksums struct {
+	MD5    string `json:"md5"`
+	SHA256 string `json:"sha256"`
+	// CRC64 CRC-64/ECMA 的十进制表示，与 OSS、COS 返回的格式一致
+	CRC64 string `json:"crc64"`
+}
+
+// checksumReader 边读取边计算 MD5、SHA-256 和 CRC64，并统计实际读取的字节数
+type checksumReader struct {
+	r      io.Reader
+	md5    hash.Hash
+	sha256 hash.Hash
+	crc64  hash.Hash64
+	n      int64
+}
+
+func newChecksumReader(r io.Reader) *checksumReader {
+	return &checksumReader{r: r, md5: md5.New(), sha256: sha256.New(), crc64: crc64.New(crc64Table)}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.md5.Write(p[:n])
+		c.sha256.Write(p[:n])
+		c.crc64.Write(p[:n])
+		c.n += int64(n)
+	}
+	return n, err
+}
+
+func (c *checksumReader) checksums() *Checksums {
+	return &Checksums{
+		MD5:    hex.EncodeToString(c.md5.Sum(nil)),
+		SHA256: hex.EncodeToString(c.sha256.Sum(nil)),
+		CRC64:  strconv.FormatUint(c.crc64.Sum64(), 10),
+	}
+}
+
+// checksumSource 计算校验和的上传数据源。可 Seek 的输入先读取一遍计算校验和再回到起始位置，
+// 上传时可以携带 Content-MD5 由存储拒绝损坏的内容；否则边上传边计算，上传完成后才能得到校验和
+type checksumSource struct {
+	io.Reader
+	sums        *checksumReader
+	precomputed bool
+}
+
+// newChecksumSource 创建上传数据源，按 options 限制上传时的读取速度并报告进度，size 为 -1 表示大小未知
+func newChecksumSource(ctx context.Context, r io.Reader, size int64, options *UploadOptions) (*checksumSource, error) {
+	sums := newChecksumReader(r)
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			if _, err := io.Copy(io.Discard, sums); err != nil {
+				return nil, fmt.Errorf("failed to checksum content: %w", err)
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("failed to rewind content: %w", err)
+			}
+			return &checksumSource{Reader: options.uploadReader(ctx, seeker, sums.n), sums: sums, precomputed: true}, nil
+		}
+	}
+	return &checksumSource{Reader: options.uploadReader(ctx, sums, size), sums: sums}, nil
+}
+
+// Size 实际上传的字节数，边上传边计算时在上传完成后才准确
+func (s *checksumSource) Size() int64 {
+	return s.sums.n
+}
+
+// ContentMD5 返回 Base64 编码的 MD5，上传前无法得到时返回空
+func (s *checksumSource) ContentMD5() string {
+	if !s.precomputed {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(s.sums.md5.Sum(nil))
+}
+
+func (s *checksumSource) Checksums() *Checksums {
+	return s.sums.checksums()
+}
+
+// verify 将存储返回的 ETag 和 CRC64 与本地计算的校验和比对，为空或 ETag 不是 MD5 时跳过对应的比对
+func (s *checksumSource) verify(etag, crc string) error {
+	sums := s.Checksums()
+	if etag, ok := ETagMD5(etag); ok && etag != sums.MD5 {
+		return fmt.Errorf("%w: etag %s, md5 %s", ErrChecksumMismatch, etag, sums.MD5)
+	}
+	if crc != "" && crc != sums.CRC64 {
+		return fmt.Errorf("%w: crc64 %s, expected %s", ErrChecksumMismatch, crc, sums.CRC64)
+	}
+	return nil
+}
+
+// verifyUpload 比对失败时删除已写入的对象，避免留下损坏的内容
+func verifyUpload(ctx context.Context, u Uploader, key string, source *checksumSource, etag, crc string) error {
+	if err := source.verify(etag, crc); err != nil {
+		cleanupCtx, cancel := abortContext(ctx)
+		defer cancel()
+		_ = u.Delete(cleanupCtx, key)
+		return err
+	}
+	return nil
+}
+
+// partMD5 返回分片内容 Base64 编码的 MD5，用于分片上传的 Content-MD5
+func partMD5(part *bytes.Reader) (string, error) {
+	h := md5.New()
+	if _, err := part.WriteTo(h); err != nil {
+		return "", err
+	}
+	if _, err := part.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"upload-util/internal/config"
+)
+
+func TestChecksumSource(t *testing.T) {
+	// CRC-64/XZ 的标准校验值
+	want := Checksums{
+		MD5:    "25f9e794323b453885f5181f1b624d0b",
+		SHA256: "15e2b0d3c33891ebb0f1ef609ec419420c20e320ce94c65fbc8c3312448eb225",
+		CRC64:  "11051210869376104954",
+	}
+
+	// 可 Seek 的输入预先计算，上传前即可得到 Content-MD5
+	source, err := newChecksumSource(context.Background(), strings.NewReader("123456789"), 9, &UploadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.ContentMD5() != "JfnnlDI7RTiF9RgfG2JNCw==" {
+		t.Errorf("unexpected Content-MD5: %s", source.ContentMD5())
+	}
+	if data, _ := io.ReadAll(source); string(data) != "123456789" {
+		t.Errorf("expected rewound content, got %q", data)
+	}
+	if got := source.Checksums(); *got != want || source.Size() != 9 {
+		t.Errorf("unexpected checksums: %+v, size %d", got, source.Size())
+	}
+
+	// 不可 Seek 的输入边读取边计算
+	source, err = newChecksumSource(context.Background(), io.NopCloser(strings.NewReader("123456789")), -1, &UploadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.ContentMD5() != "" {
+		t.Error("expected no Content-MD5 before streaming")
+	}
+	_, _ = io.Copy(io.Discard, source)
+	if got := source.Checksums(); *got != want || source.Size() != 9 {
+		t.Errorf("unexpected checksums: %+v, size %d", got, source.Size())
+	}
+
+	if err := source.verify(`"25F9E794323B453885F5181F1B624D0B"`, want.CRC64); err != nil {
+		t.Errorf("verify failed: %v", err)
+	}
+	// 分片上传的 ETag 不是 MD5，不参与比对
+	if err := source.verify(`"d41d8cd98f00b204e9800998ecf8427e-2"`, ""); err != nil {
+		t.Errorf("verify failed: %v", err)
+	}
+	if err := source.verify("d41d8cd98f00b204e9800998ecf8427e", ""); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch for etag, got %v", err)
+	}
+	if err := source.verify("", "1"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch for crc64, got %v", err)
+	}
+}
+
+func TestLocalUploadChecksums(t *testing.T) {
+	u, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{MaxFileSize: 10, PartSize: 5, MultipartThreshold: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := u.UploadReader(context.Background(), io.NopCloser(strings.NewReader("123456789")), "a.txt", -1)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if result.Size != 9 || result.ETag == "" || result.Checksums == nil || result.Checksums.CRC64 != "11051210869376104954" {
+		t.Errorf("unexpected result: %+v, %+v", result, result.Checksums)
+	}
+}
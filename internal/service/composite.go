@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 组合多个 profile 的上传器 (routing、mirror、failover) 共用的辅助函数
+
+// BackendHealth 存储后端的熔断状态
+type BackendHealth struct {
+	// Name 后端对应的 profile，嵌套时以 / 连接
+	Name string `json:"name"`
+	// State closed 正常，open 已熔断，half-open 正在试探恢复
+	State       string    `json:"state"`
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastFailure time.Time `json:"last_failure,omitzero"`
+}
+
+// UploaderHealth 返回上传器中各后端的熔断状态，没有熔断器的上传器返回 nil
+func UploaderHealth(u Uploader) []BackendHealth {
+	if reporter, ok := u.(interface{ Health() []BackendHealth }); ok {
+		return reporter.Health()
+	}
+	return nil
+}
+
+// WaitUploader 等待上传器的后台任务 (如 mirror async 策略的副本复制) 完成，
+// 没有后台任务的上传器直接返回
+func WaitUploader(u Uploader) {
+	if w, ok := u.(interface{ Wait() }); ok {
+		w.Wait()
+	}
+}
+
+// childPathPrefix 返回存储配置的 path-prefix，组合上传器统一生成对象键时使用，
+// 本地存储和组合上传器没有 path-prefix
+func childPathPrefix(u Uploader) string {
+	if p, ok := UnwrapUploader(u).(interface{ pathPrefix() string }); ok {
+		return p.pathPrefix()
+	}
+	return ""
+}
+
+// UnwrapUploader 返回重试、限速、缩略图等装饰器包装的上传器
+func UnwrapUploader(u Uploader) Uploader {
+	for {
+		w, ok := u.(interface{ Unwrap() Uploader })
+		if !ok {
+			return u
+		}
+		u = w.Unwrap()
+	}
+}
+
+// joinBackend 记录处理上传的后端，嵌套的组合上传器以 / 连接，如 images/primary
+func joinBackend(name, inner string) string {
+	if inner == "" {
+		return name
+	}
+	return name + "/" + inner
+}
+
+// childHealth 收集子上传器的熔断状态，名称加上 profile 前缀
+func childHealth(names []string, child func(name string) Uploader) []BackendHealth {
+	var health []BackendHealth
+	for _, name := range names {
+		for _, h := range UploaderHealth(child(name)) {
+			h.Name = joinBackend(name, h.Name)
+			health = append(health, h)
+		}
+	}
+	return health
+}
+
+// listAcross 依次列举多个 profile，游标格式为 "序号:profile 的游标"，每页只包含一个 profile 的结果
+func listAcross(ctx context.Context, names []string, child func(name string) Uploader, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	index := 0
+	if cursor != "" {
+		i, rest, ok := strings.Cut(cursor, ":")
+		n, err := strconv.Atoi(i)
+		if !ok || err != nil || n < 0 || n >= len(names) {
+			return nil, fmt.Errorf("invalid cursor: %s", cursor)
+		}
+		index, cursor = n, rest
+	}
+	result, err := child(names[index]).List(ctx, prefix, delimiter, cursor, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", names[index], err)
+	}
+	switch {
+	case result.Truncated:
+		result.NextCursor = strconv.Itoa(index) + ":" + result.NextCursor
+	case index+1 < len(names):
+		result.NextCursor = strconv.Itoa(index+1) + ":"
+		result.Truncated = true
+	}
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"upload-util/internal/config"
+)
+
+// hashedContent 内容寻址策略下已计算哈希的上传内容，
+// 不可 Seek 的输入会暂存到临时文件，使用完毕后需要调用 Close
+type hashedContent struct {
+	io.Reader
+	size    int64
+	sum     string
+	cleanup func()
+}
+
+func (h *hashedContent) Close() {
+	if h.cleanup != nil {
+		h.cleanup()
+	}
+}
+
+// newContentHash 返回生成对象键所需的哈希算法，不需要内容哈希时返回 nil。
+// 配置了 key-template 时仅在包含 {hash} 时计算，md5 策略使用 md5，否则使用 sha256
+func newContentHash(settings *config.UploadSettings) hash.Hash {
+	if parts, err := config.ParseKeyTemplate(settings.KeyTemplate); err == nil && parts != nil {
+		if !usesPlaceholder(parts, "hash") {
+			return nil
+		}
+		if settings.FilenameStrategy == "md5" {
+			return md5.New()
+		}
+		return sha256.New()
+	}
+	switch settings.FilenameStrategy {
+	case "sha256":
+		return sha256.New()
+	case "md5":
+		return md5.New()
+	default:
+		return nil
+	}
+}
+
+// hashContent 读取全部内容计算哈希，用于生成内容寻址的文件名或模板中的 {hash}。
+// 可 Seek 的输入计算完成后回到原位置，否则边计算边写入临时文件；
+// 不需要内容哈希或通过 WithKey 指定了对象键时原样返回输入
+func hashContent(r io.Reader, size int64, settings *config.UploadSettings, options *UploadOptions) (*hashedContent, error) {
+	h := newContentHash(settings)
+	if h == nil || options.Key != "" {
+		return &hashedContent{Reader: r, size: size}, nil
+	}
+
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			n, err := io.Copy(h, seeker)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash content: %w", err)
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("failed to rewind content: %w", err)
+			}
+			return &hashedContent{Reader: seeker, size: n, sum: hex.EncodeToString(h.Sum(nil))}, nil
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "upload-util-hash-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to hash content: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to rewind temp file: %w", err)
+	}
+	return &hashedContent{Reader: tmp, size: n, sum: hex.EncodeToString(h.Sum(nil)), cleanup: cleanup}, nil
+}
+
+// findDuplicate 内容寻址策略下对象已存在时返回去重的上传结果，不再重复写入
+func findDuplicate(ctx context.Context, uploader Uploader, content *hashedContent, key, contentType, sniffedType string) (*UploadResult, error) {
+	if content.sum == "" {
+		return nil, nil
+	}
+	info, err := uploader.Stat(ctx, key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check existing object: %w", err)
+	}
+	url, err := uploader.GetURL(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get url: %w", err)
+	}
+	return &UploadResult{
+		URL:          url,
+		Key:          key,
+		Size:         info.Size,
+		MimeType:     contentType,
+		SniffedType:  sniffedType,
+		Deduplicated: true,
+	}, nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"upload-util/internal/config"
+)
+
+func TestLocalDeduplicate(t *testing.T) {
+	settings := &config.UploadSettings{
+		MaxFileSize:       1,
+		AllowedExtensions: []string{".txt"},
+		FilenameStrategy:  "sha256",
+	}
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, settings)
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+	ctx := context.Background()
+	const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	// 可 Seek 的输入直接计算哈希
+	first, err := uploader.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if first.Key != helloSHA256+".txt" || first.Deduplicated || first.Size != 5 {
+		t.Errorf("unexpected result: %+v", first)
+	}
+
+	// 大小未知的流暂存后计算哈希，内容相同时跳过写入
+	second, err := uploader.UploadReader(ctx, io.NopCloser(strings.NewReader("hello")), "b.txt", -1)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if second.Key != first.Key || !second.Deduplicated || second.Size != 5 {
+		t.Errorf("expected deduplicated result, got %+v", second)
+	}
+
+	third, err := uploader.UploadReader(ctx, strings.NewReader("world"), "c.txt", 5)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if third.Key == first.Key || third.Deduplicated {
+		t.Errorf("unexpected result: %+v", third)
+	}
+
+	reader, err := uploader.Open(ctx, third.Key)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer reader.Close()
+	data, _ := io.ReadAll(reader)
+	if string(data) != "world" {
+		t.Errorf("expected world, got %q", data)
+	}
+}
+
+func TestHashContentMD5(t *testing.T) {
+	content, err := hashContent(io.NopCloser(strings.NewReader("hello")), -1, &config.UploadSettings{FilenameStrategy: "md5"}, &UploadOptions{})
+	if err != nil {
+		t.Fatalf("hashContent failed: %v", err)
+	}
+	defer content.Close()
+	if content.sum != "5d41402abc4b2a76b9719d911017c592" || content.size != 5 {
+		t.Errorf("unexpected hash %s size %d", content.sum, content.size)
+	}
+	data, _ := io.ReadAll(content)
+	if string(data) != "hello" {
+		t.Errorf("expected hello, got %q", data)
+	}
+}
+
+func TestLocalUploadCanceled(t *testing.T) {
+	dir := t.TempDir()
+	settings := &config.UploadSettings{MaxFileSize: 1, FilenameStrategy: "sha256"}
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: dir}, settings)
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+
+	// 取消的上传不在目标路径留下文件，之后相同内容的上传不会被当作重复
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := uploader.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no files left, got %v", entries)
+	}
+	result, err := uploader.UploadReader(context.Background(), strings.NewReader("hello"), "a.txt", 5)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if result.Deduplicated || result.Size != 5 {
+		t.Errorf("expected a fresh upload, got %+v", result)
+	}
+}
+
+// pausedReader 读取 head 后等待 release 关闭再读取剩余内容
+type pausedReader struct {
+	head, rest io.Reader
+	started    chan struct{}
+	release    chan struct{}
+	paused     bool
+}
+
+func (r *pausedReader) Read(p []byte) (int, error) {
+	n, err := r.head.Read(p)
+	if err != io.EOF {
+		return n, err
+	}
+	if !r.paused {
+		r.paused = true
+		close(r.started)
+		<-r.release
+	}
+	return r.rest.Read(p)
+}
+
+func TestLocalMultipartConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: dir}, &config.UploadSettings{MaxFileSize: 1})
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+	filePath := dir + "/a.txt"
+	first := &pausedReader{
+		head:    strings.NewReader("aaaa"),
+		rest:    strings.NewReader("aaaa"),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+
+	// 同一对象键的两次写入各自使用临时文件，后完成的写入完整覆盖目标文件
+	done := make(chan error, 1)
+	go func() {
+		_, err := uploader.multipartUpload(context.Background(), filePath, first)
+		done <- err
+	}()
+	<-first.started
+	if _, err := uploader.multipartUpload(context.Background(), filePath, strings.NewReader("bb")); err != nil {
+		t.Fatalf("second write failed: %v", err)
+	}
+	close(first.release)
+	if err := <-done; err != nil {
+		t.Fatalf("first write failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filePath); string(data) != "aaaaaaaa" {
+		t.Errorf("expected the complete first write, got %q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected no temp files left, got %v", entries)
+	}
+}
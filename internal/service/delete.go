@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// deleteBatchSize 各厂商批量删除接口单次最多 1000 个对象
+const deleteBatchSize = 1000
+
+// DeleteResult 批量删除结果，DryRun 时 Deleted 为将要删除的对象键
+type DeleteResult struct {
+	Deleted []string      `json:"deleted"`
+	Errors  []DeleteError `json:"errors,omitempty"`
+	DryRun  bool          `json:"dry_run,omitempty"`
+}
+
+// DeleteError 单个对象删除失败的原因
+type DeleteError struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+func (e DeleteError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+func newDeleteResult() *DeleteResult {
+	return &DeleteResult{Deleted: []string{}}
+}
+
+func (r *DeleteResult) addError(key string, message string) {
+	r.Errors = append(r.Errors, DeleteError{Key: key, Message: message})
+}
+
+// merge 合并另一批次的删除结果
+func (r *DeleteResult) merge(other *DeleteResult) {
+	r.Deleted = append(r.Deleted, other.Deleted...)
+	r.Errors = append(r.Errors, other.Errors...)
+}
+
+// deleteBatches 按 deleteBatchSize 分批调用 fn，整批请求失败时将该批所有对象记为失败并继续，
+// 只有 ctx 取消时才中止
+func deleteBatches(ctx context.Context, keys []string, fn func(batch []string, result *DeleteResult) error) (*DeleteResult, error) {
+	result := newDeleteResult()
+	for start := 0; start < len(keys); start += deleteBatchSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		batch := keys[start:min(start+deleteBatchSize, len(keys))]
+		if err := fn(batch, result); err != nil {
+			for _, key := range batch {
+				result.addError(key, err.Error())
+			}
+		}
+	}
+	return result, nil
+}
+
+// markUndeleted 部分接口只返回删除成功的对象，未出现在结果中的对象记为失败
+func markUndeleted(batch, deleted []string, result *DeleteResult) {
+	done := make(map[string]bool, len(deleted))
+	for _, key := range deleted {
+		done[key] = true
+	}
+	for _, key := range batch {
+		if done[key] {
+			result.Deleted = append(result.Deleted, key)
+		} else {
+			result.addError(key, "not deleted")
+		}
+	}
+}
+
+// deletePrefix 分页列举前缀下的对象并逐页批量删除，dryRun 时只返回将要删除的对象
+func deletePrefix(ctx context.Context, uploader Uploader, prefix string, dryRun bool) (*DeleteResult, error) {
+	if prefix == "" {
+		return nil, fmt.Errorf("prefix is required")
+	}
+	result := newDeleteResult()
+	result.DryRun = dryRun
+	cursor := ""
+	for {
+		page, err := uploader.List(ctx, prefix, "", cursor, maxListLimit)
+		if err != nil {
+			return result, err
+		}
+		keys := make([]string, 0, len(page.Objects))
+		for _, object := range page.Objects {
+			keys = append(keys, object.Key)
+		}
+		if dryRun {
+			result.Deleted = append(result.Deleted, keys...)
+		} else if len(keys) > 0 {
+			deleted, err := uploader.DeleteMany(ctx, keys)
+			if deleted != nil {
+				result.merge(deleted)
+			}
+			if err != nil {
+				return result, err
+			}
+		}
+		if !page.Truncated {
+			return result, nil
+		}
+		cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"upload-util/internal/config"
+)
+
+func TestDeleteBatches(t *testing.T) {
+	keys := make([]string, 2500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	var sizes []int
+	result, err := deleteBatches(context.Background(), keys, func(batch []string, result *DeleteResult) error {
+		sizes = append(sizes, len(batch))
+		if len(sizes) == 2 {
+			return errors.New("request failed")
+		}
+		markUndeleted(batch, batch[1:], result)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("deleteBatches failed: %v", err)
+	}
+	if len(sizes) != 3 || sizes[0] != 1000 || sizes[1] != 1000 || sizes[2] != 500 {
+		t.Errorf("unexpected batch sizes: %v", sizes)
+	}
+	// 第一批和第三批各有一个未删除，第二批整批失败
+	if len(result.Deleted) != 999+499 || len(result.Errors) != 1+1000+1 {
+		t.Errorf("unexpected result: %d deleted, %d errors", len(result.Deleted), len(result.Errors))
+	}
+	if result.Errors[0].Key != "key-0" || result.Errors[1].Message != "request failed" {
+		t.Errorf("unexpected errors: %v %v", result.Errors[0], result.Errors[1])
+	}
+}
+
+func TestLocalDeletePrefix(t *testing.T) {
+	dir := t.TempDir()
+	for _, key := range []string{"users/1/a.txt", "users/1/b/c.txt", "users/2/a.txt"} {
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: dir}, &config.UploadSettings{})
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+	ctx := context.Background()
+
+	result, err := uploader.DeletePrefix(ctx, "users/1/", true)
+	if err != nil {
+		t.Fatalf("DeletePrefix dry run failed: %v", err)
+	}
+	if !result.DryRun || len(result.Deleted) != 2 {
+		t.Errorf("unexpected dry run result: %+v", result)
+	}
+	if _, err := uploader.Stat(ctx, "users/1/a.txt"); err != nil {
+		t.Errorf("dry run should not delete files: %v", err)
+	}
+
+	result, err = uploader.DeletePrefix(ctx, "users/1/", false)
+	if err != nil {
+		t.Fatalf("DeletePrefix failed: %v", err)
+	}
+	if len(result.Deleted) != 2 || len(result.Errors) != 0 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if _, err := uploader.Stat(ctx, "users/2/a.txt"); err != nil {
+		t.Errorf("files outside prefix should be kept: %v", err)
+	}
+
+	result, err = uploader.DeleteMany(ctx, []string{"users/2/a.txt", "missing.txt", "../escape.txt"})
+	if err != nil {
+		t.Fatalf("DeleteMany failed: %v", err)
+	}
+	if len(result.Deleted) != 2 || len(result.Errors) != 1 || result.Errors[0].Key != "../escape.txt" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	if _, err := uploader.DeletePrefix(ctx, "", false); err == nil {
+		t.Error("expected error for empty prefix")
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"upload-util/internal/config"
 )
@@ -12,12 +13,35 @@ type UploadResult struct {
 	Key      string `json:"key"`
 	Size     int64  `json:"size"`
 	MimeType string `json:"mime_type"`
+	// SniffedType 根据文件内容识别出的类型
+	SniffedType string `json:"sniffed_type,omitempty"`
+	// Deduplicated 内容寻址策略下相同内容的对象已存在，本次未重复写入
+	Deduplicated bool `json:"deduplicated,omitempty"`
+	// Backend 组合上传器 (如 routing) 实际使用的 profile，嵌套时以 / 连接
+	Backend string `json:"backend,omitempty"`
+	// ETag 存储返回的 ETag
+	ETag string `json:"etag,omitempty"`
+	// Checksums 上传时计算的校验和，去重时为空
+	Checksums *Checksums `json:"checksums,omitempty"`
+	// Attempts 遇到临时性错误重试时的尝试次数，包括首次上传
+	Attempts int `json:"attempts,omitempty"`
+	// Variants 按 variants 配置生成的缩略图
+	Variants []Variant `json:"variants,omitempty"`
 }
 
 type Uploader interface {
 	Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error)
+	UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error)
 	Delete(ctx context.Context, key string) error
-	GetURL(ctx context.Context, key string) (string, error)
+	DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error)
+	DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error)
+	Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error)
+	Copy(ctx context.Context, src, dst string) error
+	Move(ctx context.Context, src, dst string) error
+	GetURL(ctx context.Context, key string, opts ...URLOption) (string, error)
+	Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error)
 }
 type UploadFactory struct {
 	config *config.UploadConfig
@@ -29,38 +53,61 @@ func NewUploadFactory(config *config.UploadConfig) *UploadFactory {
 	}
 }
 
+// CreateUploader 按 upload.type 创建上传器，type 为 oss 时按 oss.provider 创建，
+// 其他类型从 RegisterBackend 注册的后端中查找
 func (f *UploadFactory) CreateUploader() (Uploader, error) {
-	switch f.config.Upload.Type {
-	case "local":
-		return NewLocalUploader(f.config.Upload.Local, &f.config.UploadSettings)
-	case "oss":
-		return f.createOSSUploader() // 修正函数名
-	case "minio":
-		return NewMinIOUploader(f.config.Upload.MinIO, &f.config.UploadSettings)
-	default:
-		return nil, fmt.Errorf("unsupported upload type: %s", f.config.Upload.Type)
+	var chain []string
+	if f.config.ProfileName != "" {
+		chain = []string{f.config.ProfileName}
 	}
+	uploader, err := createUploader(f.config, chain)
+	if err != nil {
+		return nil, err
+	}
+	// 限速在最外层，组合上传器的各 profile 共享同一个带宽令牌桶，同一份配置创建的上传器也共享令牌桶
+	if f.config.UploadSettings.RateLimit != (config.RateLimitConfig{}) {
+		uploader = newThrottleUploader(uploader, &f.config.UploadSettings.RateLimit, sharedThrottleLimiters(f.config))
+	}
+	// 缩略图在限速之外生成，写入缩略图同样受限速约束
+	if len(f.config.UploadSettings.Variants) > 0 {
+		uploader = NewVariantUploader(uploader, f.config.UploadSettings.Variants)
+	}
+	return uploader, nil
 }
 
-func (f *UploadFactory) createOSSUploader() (Uploader, error) {
-	ossConfig := f.config.Upload.OSS
-	if ossConfig == nil {
-		return nil, fmt.Errorf("oss config is nil")
+// createUploader 创建上传器，chain 为正在创建的 profile，用于检测组合上传器的循环引用
+func createUploader(cfg *config.UploadConfig, chain []string) (Uploader, error) {
+	if _, err := config.ParseKeyTemplate(cfg.UploadSettings.KeyTemplate); err != nil {
+		return nil, err
 	}
-
-	switch ossConfig.Provider {
-	case "aliyun":
-		return NewAliyunOSSUploader(ossConfig.Aliyun, &f.config.UploadSettings)
-	case "tencent":
-		return NewTencentCOSUploader(ossConfig.Tencent, &f.config.UploadSettings)
-	case "huawei":
-		return NewHuaweiOBSUploader(ossConfig.Huawei, &f.config.UploadSettings)
-	case "aws":
-		return NewAWSS3Uploader(ossConfig.AWS, &f.config.UploadSettings)
-	case "qcloud":
-		return NewQCloudCOSUploader(ossConfig.QCloud, &f.config.UploadSettings)
-		//return nil, fmt.Errorf("qcloud oss is not supported yet")
-	default:
-		return nil, fmt.Errorf("unsupported oss provider: %s", ossConfig.Provider)
+	name := cfg.Upload.Type
+	if name == "oss" {
+		if cfg.Upload.OSS == nil {
+			return nil, fmt.Errorf("oss config is nil")
+		}
+		name = cfg.Upload.OSS.Provider
+	}
+	constructor, ok := lookupBackend(name)
+	if !ok {
+		if cfg.Upload.Type == "oss" {
+			return nil, fmt.Errorf("unsupported oss provider: %s", name)
+		}
+		return nil, fmt.Errorf("unsupported upload type: %s", name)
+	}
+	backend := &BackendConfig{
+		Name:     name,
+		Settings: &cfg.UploadSettings,
+		section:  cfg.Upload.Backends[name],
+		upload:   cfg,
+		chain:    chain,
+	}
+	uploader, err := constructor(backend)
+	if err != nil {
+		return nil, err
+	}
+	// 只在直接访问存储的后端外重试，组合上传器引用的 profile 各自重试，本地存储没有临时性错误
+	if backend.composite || name == "local" || cfg.UploadSettings.Retry.MaxAttempts == 1 {
+		return uploader, nil
 	}
+	return NewRetryUploader(uploader, &cfg.UploadSettings.Retry), nil
 }
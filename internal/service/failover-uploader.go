@@ -0,0 +1,453 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"upload-util/internal/config"
+)
+
+// ErrNoHealthyBackend 故障转移的所有后端都已熔断或请求失败
+var ErrNoHealthyBackend = errors.New("no healthy backend available")
+
+const (
+	defaultFailureThreshold = 3
+	defaultBreakerCooldown  = 30 // 秒
+
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// FailoverUploader 按顺序在多个 profile 之间故障转移的上传器。
+// 每个后端有独立的熔断器，连续失败达到阈值后跳过该后端，冷却后允许一次试探请求；
+// 上传失败时重新读取输入交给下一个后端，按对象键操作时在可用的后端中查找对象
+type FailoverUploader struct {
+	names    []string
+	children []Uploader
+	breakers []*circuitBreaker
+}
+
+func init() {
+	RegisterBackend("failover", func(cfg *BackendConfig) (Uploader, error) {
+		var failover config.FailoverConfig
+		if err := cfg.Decode(&failover); err != nil {
+			return nil, err
+		}
+		return NewFailoverUploader(&failover, cfg.Profile)
+	})
+}
+
+// NewFailoverUploader 创建故障转移上传器，profile 根据名称创建各后端
+func NewFailoverUploader(cfg *config.FailoverConfig, profile func(name string) (Uploader, error)) (*FailoverUploader, error) {
+	if len(cfg.Backends) < 2 {
+		return nil, fmt.Errorf("failover requires at least two backends")
+	}
+	threshold := cfg.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	cooldown := cfg.Cooldown
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	u := &FailoverUploader{}
+	for _, name := range cfg.Backends {
+		for _, existing := range u.names {
+			if existing == name {
+				return nil, fmt.Errorf("failover backend %s is listed twice", name)
+			}
+		}
+		child, err := profile(name)
+		if err != nil {
+			return nil, err
+		}
+		u.names = append(u.names, name)
+		u.children = append(u.children, child)
+		u.breakers = append(u.breakers, newCircuitBreaker(threshold, time.Duration(cooldown)*time.Second))
+	}
+	return u, nil
+}
+
+// isClientError 由请求本身导致的错误，换一个后端也不会成功，不触发故障转移和熔断
+func isClientError(err error) bool {
+	for _, target := range []error{
+		ErrFileTooLarge, ErrExtensionNotAllowed, ErrContentTypeMismatch, ErrMimeTypeNotAllowed,
+		ErrObjectNotFound, ErrInvalidRange, ErrInvalidUploadToken, context.Canceled,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// circuitBreaker 后端熔断器，连续失败 threshold 次后熔断，cooldown 后允许一次试探请求
+type circuitBreaker struct {
+	mu          sync.Mutex
+	threshold   int
+	cooldown    time.Duration
+	failures    int
+	openedAt    time.Time
+	probing     bool
+	lastError   string
+	lastFailure time.Time
+	now         func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow 判断是否可以向后端发送请求，熔断冷却结束后只放行一个试探请求
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// release 放弃本次请求且不记录结果，冷却后放行的试探请求没有发出时交还给下一个请求
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// record 记录请求结果。后端正常响应 (包括对象不存在) 时恢复，
+// 请求本身的错误不改变状态，其他错误累计失败次数
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	switch {
+	case err == nil, errors.Is(err, ErrObjectNotFound), errors.Is(err, ErrInvalidRange):
+		b.failures = 0
+	case isClientError(err):
+	default:
+		b.failures++
+		b.lastError = err.Error()
+		b.lastFailure = b.now()
+		if b.failures >= b.threshold {
+			b.openedAt = b.lastFailure
+		}
+	}
+}
+
+func (b *circuitBreaker) health(name string) BackendHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := breakerClosed
+	if b.failures >= b.threshold {
+		state = breakerOpen
+		if b.probing || b.now().Sub(b.openedAt) >= b.cooldown {
+			state = breakerHalfOpen
+		}
+	}
+	return BackendHealth{
+		Name:        name,
+		State:       state,
+		Failures:    b.failures,
+		LastError:   b.lastError,
+		LastFailure: b.lastFailure,
+	}
+}
+
+// replayReader 故障转移时从头重新读取上传内容。可 Seek 的输入回到起始位置，
+// 否则将已读取的内容暂存到临时文件，重新读取时先读暂存部分再继续读取原输入
+type replayReader struct {
+	src     io.Reader
+	seeker  io.ReadSeeker
+	start   int64
+	spool   *os.File
+	spooled int64
+}
+
+func newReplayReader(r io.Reader) *replayReader {
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			return &replayReader{seeker: seeker, start: start}
+		}
+	}
+	return &replayReader{src: r}
+}
+
+// reader 返回从头读取的 Reader，上一个 Reader 不能再使用
+func (r *replayReader) reader() (io.Reader, error) {
+	if r.seeker != nil {
+		if _, err := r.seeker.Seek(r.start, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to rewind content: %w", err)
+		}
+		return r.seeker, nil
+	}
+	if r.spool == nil {
+		spool, err := os.CreateTemp("", "upload-util-failover-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		r.spool = spool
+	}
+	return io.MultiReader(io.NewSectionReader(r.spool, 0, r.spooled), &spoolReader{r}), nil
+}
+
+func (r *replayReader) Close() {
+	if r.spool != nil {
+		_ = r.spool.Close()
+		_ = os.Remove(r.spool.Name())
+	}
+}
+
+// spoolReader 读取原输入的同时追加到暂存文件
+type spoolReader struct {
+	r *replayReader
+}
+
+func (s *spoolReader) Read(p []byte) (int, error) {
+	n, err := s.r.src.Read(p)
+	if n > 0 {
+		if _, werr := s.r.spool.WriteAt(p[:n], s.r.spooled); werr != nil {
+			return 0, fmt.Errorf("failed to buffer content: %w", werr)
+		}
+		s.r.spooled += int64(n)
+	}
+	return n, err
+}
+
+func (u *FailoverUploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	return u.UploadReader(ctx, file, header.Filename, header.Size)
+}
+
+// UploadReader 依次尝试未熔断的后端，失败时从头重新读取输入交给下一个后端
+func (u *FailoverUploader) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	source := newReplayReader(r)
+	defer source.Close()
+	var lastErr error
+	for i, child := range u.children {
+		if !u.breakers[i].allow() {
+			continue
+		}
+		reader, err := source.reader()
+		if err != nil {
+			u.breakers[i].release()
+			return nil, err
+		}
+		result, err := child.UploadReader(ctx, reader, name, size, opts...)
+		u.breakers[i].record(err)
+		if err == nil {
+			result.Backend = joinBackend(u.names[i], result.Backend)
+			return result, nil
+		}
+		if isClientError(err) {
+			return nil, err
+		}
+		lastErr = fmt.Errorf("%s: %w", u.names[i], err)
+	}
+	return nil, u.unavailable(lastErr)
+}
+
+func (u *FailoverUploader) unavailable(lastErr error) error {
+	if lastErr == nil {
+		return fmt.Errorf("%w: all backends are open", ErrNoHealthyBackend)
+	}
+	return fmt.Errorf("%w: %w", ErrNoHealthyBackend, lastErr)
+}
+
+// locate 在未熔断的后端中依次查找对象，返回所在后端的序号和对象信息。
+// 对象在可用的后端中都不存在、但有后端请求失败时返回该错误
+func (u *FailoverUploader) locate(ctx context.Context, key string) (int, *ObjectInfo, error) {
+	var lastErr error
+	for i, child := range u.children {
+		if !u.breakers[i].allow() {
+			continue
+		}
+		info, err := child.Stat(ctx, key)
+		u.breakers[i].record(err)
+		if err == nil {
+			return i, info, nil
+		}
+		if errors.Is(err, ErrObjectNotFound) {
+			continue
+		}
+		if isClientError(err) {
+			return -1, nil, err
+		}
+		lastErr = fmt.Errorf("%s: %w", u.names[i], err)
+	}
+	if lastErr != nil {
+		return -1, nil, u.unavailable(lastErr)
+	}
+	return -1, nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+}
+
+func (u *FailoverUploader) Delete(ctx context.Context, key string) error {
+	i, _, err := u.locate(ctx, key)
+	if err != nil {
+		return err
+	}
+	err = u.children[i].Delete(ctx, key)
+	u.breakers[i].record(err)
+	return err
+}
+
+// DeleteMany 按对象所在的后端分组删除，无法定位的对象记为失败
+func (u *FailoverUploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	groups := make([][]string, len(u.children))
+	result := newDeleteResult()
+	for _, key := range keys {
+		i, _, err := u.locate(ctx, key)
+		if err != nil {
+			result.addError(key, err.Error())
+			continue
+		}
+		groups[i] = append(groups[i], key)
+	}
+	for i, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		deleted, err := u.children[i].DeleteMany(ctx, group)
+		u.breakers[i].record(err)
+		if deleted != nil {
+			result.merge(deleted)
+		}
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", u.names[i], err)
+		}
+	}
+	return result, nil
+}
+
+// DeletePrefix 在所有未熔断的后端中删除前缀下的对象。
+// 熔断的后端被跳过，其余后端处理完后返回错误，该后端中前缀下的对象需要恢复后重新删除
+func (u *FailoverUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	result := newDeleteResult()
+	result.DryRun = dryRun
+	var skipped []string
+	for i, child := range u.children {
+		if !u.breakers[i].allow() {
+			skipped = append(skipped, u.names[i])
+			continue
+		}
+		deleted, err := child.DeletePrefix(ctx, prefix, dryRun)
+		u.breakers[i].record(err)
+		if deleted != nil {
+			result.merge(deleted)
+		}
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", u.names[i], err)
+		}
+	}
+	if len(skipped) > 0 {
+		return result, fmt.Errorf("%w: %s skipped", ErrNoHealthyBackend, strings.Join(skipped, ", "))
+	}
+	return result, nil
+}
+
+func (u *FailoverUploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	i, _, err := u.locate(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := u.children[i].Open(ctx, key, opts...)
+	u.breakers[i].record(err)
+	return reader, err
+}
+
+// Stat 返回查找对象时取得的对象信息
+func (u *FailoverUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	_, info, err := u.locate(ctx, key)
+	return info, err
+}
+
+// List 依次列举各后端
+func (u *FailoverUploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	return listAcross(ctx, u.names, u.child, prefix, delimiter, cursor, limit)
+}
+
+func (u *FailoverUploader) child(name string) Uploader {
+	for i, existing := range u.names {
+		if existing == name {
+			return u.children[i]
+		}
+	}
+	return nil
+}
+
+// Copy 在源对象所在的后端内复制
+func (u *FailoverUploader) Copy(ctx context.Context, src, dst string) error {
+	i, _, err := u.locate(ctx, src)
+	if err != nil {
+		return err
+	}
+	err = u.children[i].Copy(ctx, src, dst)
+	u.breakers[i].record(err)
+	return err
+}
+
+// Move 在源对象所在的后端内移动
+func (u *FailoverUploader) Move(ctx context.Context, src, dst string) error {
+	i, _, err := u.locate(ctx, src)
+	if err != nil {
+		return err
+	}
+	err = u.children[i].Move(ctx, src, dst)
+	u.breakers[i].record(err)
+	return err
+}
+
+func (u *FailoverUploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	i, _, err := u.locate(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return u.children[i].GetURL(ctx, key, opts...)
+}
+
+// Presign 使用第一个签发成功的未熔断后端
+func (u *FailoverUploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	var lastErr error
+	for i, child := range u.children {
+		if !u.breakers[i].allow() {
+			continue
+		}
+		result, err := child.Presign(ctx, req)
+		u.breakers[i].record(err)
+		if err == nil {
+			return result, nil
+		}
+		if isClientError(err) {
+			return nil, err
+		}
+		lastErr = fmt.Errorf("%s: %w", u.names[i], err)
+	}
+	return nil, u.unavailable(lastErr)
+}
+
+// Health 返回各后端的熔断状态，嵌套的组合上传器一并返回
+func (u *FailoverUploader) Health() []BackendHealth {
+	var health []BackendHealth
+	for i, name := range u.names {
+		health = append(health, u.breakers[i].health(name))
+		health = append(health, childHealth([]string{name}, u.child)...)
+	}
+	return health
+}
+
+// Wait 等待各后端的后台任务完成
+func (u *FailoverUploader) Wait() {
+	for _, child := range u.children {
+		WaitUploader(child)
+	}
+}
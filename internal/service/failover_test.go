@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"upload-util/internal/config"
+)
+
+// flakyUploader 在 down 时所有请求都返回后端错误
+type flakyUploader struct {
+	Uploader
+	mu    sync.Mutex
+	down  bool
+	calls int
+}
+
+var errBackendDown = errors.New("backend down")
+
+func (f *flakyUploader) failing() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.down
+}
+
+func (f *flakyUploader) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *flakyUploader) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	if f.failing() {
+		// 读取部分内容后失败，下一个后端需要从头读取
+		_, _ = io.CopyN(io.Discard, r, 100)
+		return nil, errBackendDown
+	}
+	return f.Uploader.UploadReader(ctx, r, name, size, opts...)
+}
+
+func (f *flakyUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	if f.failing() {
+		return nil, errBackendDown
+	}
+	return f.Uploader.Stat(ctx, key)
+}
+
+func (f *flakyUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	if f.failing() {
+		return nil, errBackendDown
+	}
+	return f.Uploader.DeletePrefix(ctx, prefix, dryRun)
+}
+
+// newFailoverTestUploader 创建由 primary、secondary 两个本地存储组成的故障转移，primary 可模拟故障
+func newFailoverTestUploader(t *testing.T) (*FailoverUploader, *flakyUploader) {
+	t.Helper()
+	settings := &config.UploadSettings{MaxFileSize: 10, AllowedExtensions: []string{".txt"}, FilenameStrategy: "uuid"}
+	children := make(map[string]Uploader)
+	for _, name := range []string{"primary", "secondary"} {
+		local, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		children[name] = local
+	}
+	primary := &flakyUploader{Uploader: children["primary"]}
+	children["primary"] = primary
+	u, err := NewFailoverUploader(&config.FailoverConfig{
+		Backends:         []string{"primary", "secondary"},
+		FailureThreshold: 2,
+		Cooldown:         60,
+	}, func(name string) (Uploader, error) {
+		child, ok := children[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", config.ErrProfileNotFound, name)
+		}
+		return child, nil
+	})
+	if err != nil {
+		t.Fatalf("NewFailoverUploader failed: %v", err)
+	}
+	return u, primary
+}
+
+func TestFailoverUpload(t *testing.T) {
+	u, primary := newFailoverTestUploader(t)
+	ctx := context.Background()
+	content := strings.Repeat("hello failover ", 1000)
+
+	result, err := u.UploadReader(ctx, strings.NewReader(content), "a.txt", int64(len(content)))
+	if err != nil || result.Backend != "primary" {
+		t.Fatalf("expected primary, got %+v, %v", result, err)
+	}
+
+	// 不可 Seek 的输入在故障转移时从暂存的内容重新读取
+	primary.setDown(true)
+	result, err = u.UploadReader(ctx, io.NopCloser(strings.NewReader(content)), "b.txt", -1)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if result.Backend != "secondary" {
+		t.Errorf("expected secondary, got %s", result.Backend)
+	}
+	if got := readMirrorObject(t, u, result.Key); got != content {
+		t.Errorf("content mismatch, got %d bytes", len(got))
+	}
+
+	// 请求本身的错误不故障转移
+	if _, err := u.UploadReader(ctx, strings.NewReader("x"), "a.exe", 1); !errors.Is(err, ErrExtensionNotAllowed) {
+		t.Errorf("expected ErrExtensionNotAllowed, got %v", err)
+	}
+}
+
+func TestFailoverCircuitBreaker(t *testing.T) {
+	u, primary := newFailoverTestUploader(t)
+	ctx := context.Background()
+	now := time.Now()
+	u.breakers[0].now = func() time.Time { return now }
+	primary.setDown(true)
+
+	// 连续失败达到阈值后熔断，不再请求 primary
+	for i := 0; i < 2; i++ {
+		if _, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5); err != nil {
+			t.Fatalf("UploadReader failed: %v", err)
+		}
+	}
+	if state := u.Health()[0].State; state != breakerOpen {
+		t.Fatalf("expected open, got %s", state)
+	}
+	calls := primary.calls
+	if _, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5); err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if primary.calls != calls {
+		t.Errorf("expected open breaker to skip primary")
+	}
+
+	// 冷却后试探失败重新熔断
+	now = now.Add(time.Minute)
+	if state := u.Health()[0].State; state != breakerHalfOpen {
+		t.Fatalf("expected half-open, got %s", state)
+	}
+	if _, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5); err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if state := u.Health()[0].State; state != breakerOpen {
+		t.Fatalf("expected reopened, got %s", state)
+	}
+
+	// 冷却后试探成功恢复
+	now = now.Add(time.Minute)
+	primary.setDown(false)
+	result, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5)
+	if err != nil || result.Backend != "primary" {
+		t.Fatalf("expected primary, got %+v, %v", result, err)
+	}
+	if health := u.Health()[0]; health.State != breakerClosed || health.Failures != 0 {
+		t.Errorf("expected closed, got %+v", health)
+	}
+}
+
+func TestFailoverLocate(t *testing.T) {
+	u, primary := newFailoverTestUploader(t)
+	ctx := context.Background()
+	primary.setDown(true)
+	result, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+
+	// primary 故障时在 secondary 中找到对象
+	primary.setDown(false)
+	if _, err := u.Stat(ctx, result.Key); err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if err := u.Copy(ctx, result.Key, "copy.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if _, err := u.children[1].Stat(ctx, "copy.txt"); err != nil {
+		t.Errorf("expected copy in secondary: %v", err)
+	}
+	if err := u.Delete(ctx, result.Key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := u.Stat(ctx, result.Key); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected ErrObjectNotFound, got %v", err)
+	}
+
+	// 所有后端熔断时返回 ErrNoHealthyBackend
+	for _, breaker := range u.breakers {
+		breaker.failures = breaker.threshold
+		breaker.openedAt = time.Now()
+	}
+	if _, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5); !errors.Is(err, ErrNoHealthyBackend) {
+		t.Errorf("expected ErrNoHealthyBackend, got %v", err)
+	}
+}
+
+func TestFailoverStatSingleRequest(t *testing.T) {
+	u, primary := newFailoverTestUploader(t)
+	ctx := context.Background()
+	result, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	calls := primary.calls
+	info, err := u.Stat(ctx, result.Key)
+	if err != nil || info.Size != 5 {
+		t.Fatalf("expected 5 bytes, got %+v, %v", info, err)
+	}
+	if primary.calls != calls+1 {
+		t.Errorf("expected one Stat request, got %d", primary.calls-calls)
+	}
+}
+
+func TestFailoverDeletePrefix(t *testing.T) {
+	u, primary := newFailoverTestUploader(t)
+	ctx := context.Background()
+	now := time.Now()
+	u.breakers[0].now = func() time.Time { return now }
+	if _, err := u.children[1].UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5, WithKey("logs/a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	primary.setDown(true)
+	for i := 0; i < 2; i++ {
+		if _, err := u.Stat(ctx, "logs/a.txt"); err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+	}
+	if state := u.Health()[0].State; state != breakerOpen {
+		t.Fatalf("expected open, got %s", state)
+	}
+
+	// 熔断的后端被跳过，其余后端照常删除
+	calls := primary.calls
+	result, err := u.DeletePrefix(ctx, "logs/", false)
+	if !errors.Is(err, ErrNoHealthyBackend) || !strings.Contains(err.Error(), "primary") {
+		t.Errorf("expected skipped primary error, got %v", err)
+	}
+	if primary.calls != calls {
+		t.Errorf("expected open breaker to skip primary")
+	}
+	if result == nil || len(result.Deleted) != 1 {
+		t.Errorf("expected secondary object deleted, got %+v", result)
+	}
+
+	// 冷却后的试探请求在读取输入失败时交还，不影响下一个请求
+	now = now.Add(time.Minute)
+	if !u.breakers[0].allow() {
+		t.Fatal("expected probe to be allowed")
+	}
+	u.breakers[0].release()
+	if health := u.breakers[0].health("primary"); health.State != breakerHalfOpen || health.Failures != 2 {
+		t.Errorf("expected half-open after release, got %+v", health)
+	}
+	if !u.breakers[0].allow() {
+		t.Error("expected released probe to be allowed again")
+	}
+}
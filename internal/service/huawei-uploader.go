@@ -1,9 +1,13 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"net/http"
 	"strings"
 	"upload-util/internal/config"
 
@@ -35,25 +39,72 @@ func NewHuaweiOBSUploader(config *config.HuaweiOBSConfig, settings *config.Uploa
 }
 
 func (u *HuaweiUploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	return u.UploadReader(ctx, file, header.Filename, header.Size)
+}
+
+// UploadReader 从任意 io.Reader 上传，size 为 -1 时以分片方式流式上传
+func (u *HuaweiUploader) UploadReader(ctx context.Context, file io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	options := newUploadOptions(opts)
+	header := &multipart.FileHeader{Filename: name, Size: size}
+
 	// 验证文件
-	if err := validateFile(header, u.settings); err != nil {
+	if err := options.validate(header, u.settings); err != nil {
 		return nil, fmt.Errorf("file validation failed: %w", err)
 	}
+	file = options.limitSize(file, size, u.settings)
+	// 内容寻址策略先计算哈希，由哈希生成对象键
+	content, err := hashContent(file, size, u.settings, options)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	file, size = content.Reader, content.size
+	// 识别内容的真实类型，拒绝与扩展名不符的文件
+	file, sniffed, err := options.sniff(file, name, u.settings)
+	if err != nil {
+		return nil, err
+	}
 
 	// 生成文件名和对象键
-	filename := generateFileName(header, u.settings)
-	objectKey := buildObjectKey(filename, u.config.PathPrefix)
-
-	// 上传文件
-	input := &obs.PutObjectInput{}
-	input.Bucket = u.config.Bucket
-	input.Key = objectKey
-	input.Body = file
-	input.ContentType = getMimeType(filename)
+	objectKey := options.Key
+	if objectKey == "" {
+		objectKey = buildObjectKey(generateFileName(header, u.settings, content.sum, options.User), u.config.PathPrefix)
+	}
+	contentType := options.contentType(name)
+	if result, err := findDuplicate(ctx, u, content, objectKey, contentType, sniffed); result != nil || err != nil {
+		return result, err
+	}
 
-	_, err := u.client.PutObject(input)
+	// 计算校验和，可 Seek 的输入携带 Content-MD5
+	source, err := newChecksumSource(ctx, file, size, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to upload file to huawei obs: %w", err)
+		return nil, err
+	}
+
+	// 上传文件，超过阈值时使用分片上传
+	var etag string
+	if shouldUseMultipart(size, u.settings) {
+		etag, err = u.multipartUpload(ctx, objectKey, source, contentType, options.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to multipart upload file to huawei obs: %w", err)
+		}
+	} else {
+		input := &obs.PutObjectInput{}
+		input.Bucket = u.config.Bucket
+		input.Key = objectKey
+		input.Body = source.Reader
+		input.ContentType = contentType
+		input.ContentMD5 = source.ContentMD5()
+		input.Metadata = options.Metadata
+
+		output, err := u.client.PutObject(input, obs.WithRequestContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload file to huawei obs: %w", err)
+		}
+		etag = output.ETag
+		if err := verifyUpload(ctx, u, objectKey, source, etag, ""); err != nil {
+			return nil, err
+		}
 	}
 
 	// 生成访问 URL
@@ -63,13 +114,78 @@ func (u *HuaweiUploader) Upload(ctx context.Context, file multipart.File, header
 	}
 
 	return &UploadResult{
-		URL:      url,
-		Key:      objectKey,
-		Size:     header.Size,
-		MimeType: getMimeType(filename),
+		URL:         url,
+		Key:         objectKey,
+		Size:        source.Size(),
+		MimeType:    contentType,
+		SniffedType: sniffed,
+		ETag:        strings.Trim(etag, `"`),
+		Checksums:   source.Checksums(),
 	}, nil
 }
 
+// multipartUpload 分片上传，每个分片携带 Content-MD5，返回合并后对象的 ETag
+func (u *HuaweiUploader) multipartUpload(ctx context.Context, objectKey string, file io.Reader, contentType string, metadata map[string]string) (string, error) {
+	initInput := &obs.InitiateMultipartUploadInput{}
+	initInput.Bucket = u.config.Bucket
+	initInput.Key = objectKey
+	initInput.ContentType = contentType
+	initInput.Metadata = metadata
+	init, err := u.client.InitiateMultipartUpload(initInput, obs.WithRequestContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	completeInput := &obs.CompleteMultipartUploadInput{}
+	completeInput.Bucket = u.config.Bucket
+	completeInput.Key = objectKey
+	completeInput.UploadId = init.UploadId
+	_, err = uploadLimitedParts(ctx, file, partSize(u.settings), func(partNumber int, part *bytes.Reader) error {
+		contentMD5, err := partMD5(part)
+		if err != nil {
+			return err
+		}
+		partInput := &obs.UploadPartInput{}
+		partInput.Bucket = u.config.Bucket
+		partInput.Key = objectKey
+		partInput.UploadId = init.UploadId
+		partInput.PartNumber = partNumber
+		partInput.PartSize = part.Size()
+		partInput.ContentMD5 = contentMD5
+		partInput.Body = part
+		output, err := u.client.UploadPart(partInput, obs.WithRequestContext(ctx))
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+		}
+		completeInput.Parts = append(completeInput.Parts, obs.Part{
+			PartNumber: partNumber,
+			ETag:       output.ETag,
+		})
+		return nil
+	})
+	var etag string
+	if err == nil {
+		var output *obs.CompleteMultipartUploadOutput
+		if output, err = u.client.CompleteMultipartUpload(completeInput, obs.WithRequestContext(ctx)); err != nil {
+			err = fmt.Errorf("failed to complete multipart upload: %w", err)
+		} else {
+			etag = output.ETag
+		}
+	}
+	if err != nil {
+		// 中止未完成的分片上传，避免残留分片占用存储空间
+		abortCtx, cancel := abortContext(ctx)
+		defer cancel()
+		abortInput := &obs.AbortMultipartUploadInput{}
+		abortInput.Bucket = u.config.Bucket
+		abortInput.Key = objectKey
+		abortInput.UploadId = init.UploadId
+		_, _ = u.client.AbortMultipartUpload(abortInput, obs.WithRequestContext(abortCtx))
+		return "", err
+	}
+	return etag, nil
+}
+
 func (u *HuaweiUploader) Delete(ctx context.Context, key string) error {
 	input := &obs.DeleteObjectInput{}
 	input.Bucket = u.config.Bucket
@@ -82,7 +198,161 @@ func (u *HuaweiUploader) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
-func (u *HuaweiUploader) GetURL(ctx context.Context, key string) (string, error) {
+// Open 读取对象内容，可通过 WithRange 只读取一部分。
+// SDK 的 GetObjectInput 只支持闭区间范围，按范围读取时改用签名 URL 携带 Range 请求头
+func (u *HuaweiUploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	options := newOpenOptions(opts)
+	var output *obs.GetObjectOutput
+	var err error
+	if rangeHeader := options.rangeHeader(); rangeHeader != "" {
+		var signed *obs.CreateSignedUrlOutput
+		signed, err = u.client.CreateSignedUrl(&obs.CreateSignedUrlInput{
+			Method:  obs.HttpMethodGet,
+			Bucket:  u.config.Bucket,
+			Key:     key,
+			Expires: 60,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign get url: %w", err)
+		}
+		header := signed.ActualSignedRequestHeaders.Clone()
+		header.Set("Range", rangeHeader)
+		output, err = u.client.GetObjectWithSignedUrl(signed.SignedUrl, header)
+	} else {
+		input := &obs.GetObjectInput{}
+		input.Bucket = u.config.Bucket
+		input.Key = key
+		output, err = u.client.GetObject(input, obs.WithRequestContext(ctx))
+	}
+	if err != nil {
+		if obsErr, ok := err.(obs.ObsError); ok {
+			err = objectError(obsErr.StatusCode, err)
+		}
+		return nil, fmt.Errorf("failed to get object from huawei obs: %w", err)
+	}
+	var contentRange string
+	if values := output.ResponseHeaders["content-range"]; len(values) > 0 {
+		contentRange = values[0]
+	}
+	return newObjectReader(output.Body, key, output.ContentType, output.ETag, output.ContentLength, contentRange), nil
+}
+
+// Stat 获取对象元数据，对象不存在时返回 ErrObjectNotFound
+func (u *HuaweiUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	input := &obs.GetObjectMetadataInput{}
+	input.Bucket = u.config.Bucket
+	input.Key = key
+	output, err := u.client.GetObjectMetadata(input, obs.WithRequestContext(ctx))
+	if err != nil {
+		if obsErr, ok := err.(obs.ObsError); ok {
+			err = objectError(obsErr.StatusCode, err)
+		}
+		return nil, fmt.Errorf("failed to stat object from huawei obs: %w", err)
+	}
+	return &ObjectInfo{
+		Key:          key,
+		Size:         output.ContentLength,
+		ContentType:  output.ContentType,
+		LastModified: output.LastModified,
+		ETag:         strings.Trim(output.ETag, `"`),
+		Metadata:     output.Metadata,
+	}, nil
+}
+
+// List 按前缀列举对象，cursor 为上一页返回的 NextCursor
+func (u *HuaweiUploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	input := &obs.ListObjectsInput{}
+	input.Bucket = u.config.Bucket
+	input.Prefix = prefix
+	input.Delimiter = delimiter
+	input.Marker = cursor
+	input.MaxKeys = listLimit(limit)
+	output, err := u.client.ListObjects(input, obs.WithRequestContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects from huawei obs: %w", err)
+	}
+	result := &ListResult{
+		Objects:        make([]ObjectInfo, 0, len(output.Contents)),
+		CommonPrefixes: output.CommonPrefixes,
+		Truncated:      output.IsTruncated,
+	}
+	for _, object := range output.Contents {
+		result.Objects = append(result.Objects, ObjectInfo{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+			ETag:         strings.Trim(object.ETag, `"`),
+		})
+	}
+	result.NextCursor = nextCursor(output.IsTruncated, output.NextMarker, result)
+	return result, nil
+}
+
+// Copy 服务端复制对象，数据不经过本服务
+func (u *HuaweiUploader) Copy(ctx context.Context, src, dst string) error {
+	if err := checkCopyKeys(src, dst); err != nil {
+		return err
+	}
+	input := &obs.CopyObjectInput{}
+	input.Bucket = u.config.Bucket
+	input.Key = dst
+	input.CopySourceBucket = u.config.Bucket
+	input.CopySourceKey = src
+	if _, err := u.client.CopyObject(input, obs.WithRequestContext(ctx)); err != nil {
+		if obsErr, ok := err.(obs.ObsError); ok {
+			err = objectError(obsErr.StatusCode, err)
+		}
+		return fmt.Errorf("failed to copy object in huawei obs: %w", err)
+	}
+	return nil
+}
+
+// Move 服务端复制后删除源对象
+func (u *HuaweiUploader) Move(ctx context.Context, src, dst string) error {
+	return moveObject(ctx, u, src, dst)
+}
+
+// DeleteMany 批量删除对象，每批最多 1000 个
+func (u *HuaweiUploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	return deleteBatches(ctx, keys, func(batch []string, result *DeleteResult) error {
+		input := &obs.DeleteObjectsInput{Bucket: u.config.Bucket}
+		for _, key := range batch {
+			input.Objects = append(input.Objects, obs.ObjectToDelete{Key: key})
+		}
+		output, err := u.client.DeleteObjects(input, obs.WithRequestContext(ctx))
+		if err != nil {
+			return err
+		}
+		for _, deleted := range output.Deleteds {
+			result.Deleted = append(result.Deleted, deleted.Key)
+		}
+		for _, deleteErr := range output.Errors {
+			result.addError(deleteErr.Key, deleteErr.Code+": "+deleteErr.Message)
+		}
+		return nil
+	})
+}
+
+// DeletePrefix 删除前缀下的所有对象，dryRun 时只列出将要删除的对象
+func (u *HuaweiUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	return deletePrefix(ctx, u, prefix, dryRun)
+}
+
+// GetURL 返回对象的访问地址，私有模式下返回带有效期的签名链接
+func (u *HuaweiUploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	if u.config.Private {
+		expires := newURLOptions(opts).expires(u.config.SignURLExpire)
+		output, err := u.client.CreateSignedUrl(&obs.CreateSignedUrlInput{
+			Method:  obs.HttpMethodGet,
+			Bucket:  u.config.Bucket,
+			Key:     key,
+			Expires: int(expires.Seconds()),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to sign get url: %w", err)
+		}
+		return output.SignedUrl, nil
+	}
 	if u.config.Domain != "" {
 		return fmt.Sprintf("%s/%s", strings.TrimRight(u.config.Domain, "/"), key), nil
 	}
@@ -93,3 +363,51 @@ func (u *HuaweiUploader) GetURL(ctx context.Context, key string) (string, error)
 	}
 	return fmt.Sprintf("%s://%s.%s/%s", protocol, u.config.Bucket, u.config.Endpoint, key), nil
 }
+
+func (u *HuaweiUploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	params, err := preparePresign(req, u.settings, u.config.PathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.method == http.MethodPut {
+		input := &obs.CreateSignedUrlInput{}
+		input.Method = obs.HttpMethodPut
+		input.Bucket = u.config.Bucket
+		input.Key = params.key
+		input.Expires = int(params.expire.Seconds())
+		input.Headers = map[string]string{"Content-Type": params.contentType}
+		output, err := u.client.CreateSignedUrl(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign put url: %w", err)
+		}
+		return params.result(output.SignedUrl), nil
+	}
+
+	policy, err := encodePostPolicy(params.expires, params.postConditions(u.config.Bucket))
+	if err != nil {
+		return nil, err
+	}
+	result := params.result(u.bucketURL())
+	result.Fields = map[string]string{
+		"key":                   params.key,
+		"Content-Type":          params.contentType,
+		"AccessKeyId":           u.config.AccessKeyID,
+		"policy":                policy,
+		"signature":             base64.StdEncoding.EncodeToString(hmacSHA1([]byte(u.config.SecretAccessKey), []byte(policy))),
+		"success_action_status": "200",
+	}
+	return result, nil
+}
+
+func (u *HuaweiUploader) bucketURL() string {
+	protocol := "https"
+	if !u.config.UseSSL {
+		protocol = "http"
+	}
+	return fmt.Sprintf("%s://%s.%s", protocol, u.config.Bucket, u.config.Endpoint)
+}
+
+func (u *HuaweiUploader) pathPrefix() string {
+	return u.config.PathPrefix
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"upload-util/internal/config"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultRandomLength = 8
+	anonymousUser       = "anonymous"
+	randomAlphabet      = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// renderKeyTemplate 按对象键模板生成文件名。
+// sum 为内容哈希，为空时 (如直传签名) {hash} 使用随机十六进制串代替；
+// user 为空时 {user} 为 anonymous
+func renderKeyTemplate(parts []config.KeyTemplatePart, filename, sum, user string, now time.Time) string {
+	ext := filepath.Ext(filename)
+	var b strings.Builder
+	for _, part := range parts {
+		switch part.Placeholder {
+		case "":
+			b.WriteString(part.Text)
+		case "yyyy":
+			fmt.Fprintf(&b, "%04d", now.Year())
+		case "mm":
+			fmt.Fprintf(&b, "%02d", int(now.Month()))
+		case "dd":
+			fmt.Fprintf(&b, "%02d", now.Day())
+		case "uuid":
+			b.WriteString(uuid.New().String())
+		case "name":
+			b.WriteString(sanitizeKeySegment(strings.TrimSuffix(filepath.Base(filename), ext), "file"))
+		case "ext":
+			b.WriteString(ext)
+		case "user":
+			b.WriteString(sanitizeKeySegment(user, anonymousUser))
+		case "hash":
+			hash := sum
+			if hash == "" {
+				hash = randomHex(max(part.Length, 32))
+			}
+			if part.Length > 0 && part.Length < len(hash) {
+				hash = hash[:part.Length]
+			}
+			b.WriteString(hash)
+		case "random":
+			length := part.Length
+			if length == 0 {
+				length = defaultRandomLength
+			}
+			b.WriteString(randomString(length))
+		}
+	}
+	return b.String()
+}
+
+// usesPlaceholder 模板中是否包含指定占位符
+func usesPlaceholder(parts []config.KeyTemplatePart, name string) bool {
+	for _, part := range parts {
+		if part.Placeholder == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeKeySegment 去掉路径分隔符，防止 {name}、{user} 改变对象键的目录层级
+func sanitizeKeySegment(value, fallback string) string {
+	value = strings.TrimSpace(strings.NewReplacer("/", "_", "\\", "_").Replace(value))
+	if value == "" || value == "." || value == ".." {
+		return fallback
+	}
+	return value
+}
+
+func randomString(length int) string {
+	buf := make([]byte, length)
+	_, _ = rand.Read(buf)
+	for i := range buf {
+		buf[i] = randomAlphabet[int(buf[i])%len(randomAlphabet)]
+	}
+	return string(buf)
+}
+
+func randomHex(length int) string {
+	buf := make([]byte, (length+1)/2)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)[:length]
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"upload-util/internal/config"
+)
+
+func TestRenderKeyTemplate(t *testing.T) {
+	now := time.Date(2026, 10, 6, 12, 0, 0, 0, time.UTC)
+	render := func(template, filename, sum, user string) string {
+		parts, err := config.ParseKeyTemplate(template)
+		if err != nil {
+			t.Fatalf("ParseKeyTemplate failed: %v", err)
+		}
+		return renderKeyTemplate(parts, filename, sum, user, now)
+	}
+
+	if got := render("{yyyy}/{mm}/{dd}/{name}{ext}", "photo.jpg", "", ""); got != "2026/10/06/photo.jpg" {
+		t.Errorf("unexpected key: %s", got)
+	}
+	if got := render("{user}/{hash:8}{ext}", "a.png", "0123456789abcdef", "alice"); got != "alice/01234567.png" {
+		t.Errorf("unexpected key: %s", got)
+	}
+	// {user} 和 {name} 不能改变目录层级
+	if got := render("{user}/{name}", "../x", "", "../../etc"); got != ".._.._etc/x" {
+		t.Errorf("unexpected key: %s", got)
+	}
+	if got := render("{user}/{name}", "..", "", ""); got != "anonymous/file" {
+		t.Errorf("unexpected key: %s", got)
+	}
+	if got := render("{random:6}-{hash:10}", "a", "", ""); !regexp.MustCompile(`^[a-z0-9]{6}-[0-9a-f]{10}$`).MatchString(got) {
+		t.Errorf("unexpected key: %s", got)
+	}
+}
+
+func TestLocalKeyTemplate(t *testing.T) {
+	settings := &config.UploadSettings{
+		MaxFileSize:       1,
+		AllowedExtensions: []string{".txt"},
+		FilenameStrategy:  "uuid",
+		KeyTemplate:       "{user}/{hash:12}{ext}",
+	}
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, settings)
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+	ctx := context.Background()
+
+	result, err := uploader.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5, WithUser("alice"))
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if result.Key != "alice/2cf24dba5fb0.txt" || result.MimeType != "text/plain; charset=utf-8" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if _, err := uploader.Stat(ctx, result.Key); err != nil {
+		t.Errorf("Stat failed: %v", err)
+	}
+
+	// 模板只包含内容哈希时，相同内容不重复写入
+	again, err := uploader.UploadReader(ctx, strings.NewReader("hello"), "b.txt", 5, WithUser("alice"))
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if again.Key != result.Key || !again.Deduplicated {
+		t.Errorf("expected deduplicated result, got %+v", again)
+	}
+}
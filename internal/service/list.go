@@ -0,0 +1,81 @@
+package service
+
+import (
+	"sort"
+	"strings"
+)
+
+const maxListLimit = 1000
+
+// ListResult 列举结果，CommonPrefixes 为按分隔符折叠后的“目录”，
+// Truncated 为 true 时使用 NextCursor 继续列举
+type ListResult struct {
+	Objects        []ObjectInfo `json:"objects"`
+	CommonPrefixes []string     `json:"common_prefixes,omitempty"`
+	NextCursor     string       `json:"next_cursor,omitempty"`
+	Truncated      bool         `json:"truncated"`
+}
+
+// listLimit 限制单次列举数量，与各厂商 API 的上限一致
+func listLimit(limit int) int {
+	if limit <= 0 || limit > maxListLimit {
+		return maxListLimit
+	}
+	return limit
+}
+
+// nextCursor 部分厂商未指定分隔符时不返回 NextMarker，此时以最后一个对象键作为游标
+func nextCursor(truncated bool, nextMarker string, result *ListResult) string {
+	if !truncated {
+		return ""
+	}
+	if nextMarker != "" {
+		return nextMarker
+	}
+	last := ""
+	if n := len(result.Objects); n > 0 {
+		last = result.Objects[n-1].Key
+	}
+	if n := len(result.CommonPrefixes); n > 0 && result.CommonPrefixes[n-1] > last {
+		last = result.CommonPrefixes[n-1]
+	}
+	return last
+}
+
+// listObjects 对已知的全部对象按前缀、分隔符和游标分页，语义与 S3 ListObjects 的 marker 一致
+func listObjects(objects []ObjectInfo, prefix, delimiter, cursor string, limit int) *ListResult {
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+	limit = listLimit(limit)
+	result := &ListResult{Objects: []ObjectInfo{}}
+	lastPrefix := ""
+	count := 0
+	for _, object := range objects {
+		if !strings.HasPrefix(object.Key, prefix) {
+			continue
+		}
+		entry, isPrefix := object.Key, false
+		if delimiter != "" {
+			if idx := strings.Index(object.Key[len(prefix):], delimiter); idx >= 0 {
+				entry, isPrefix = object.Key[:len(prefix)+idx+len(delimiter)], true
+			}
+		}
+		if entry <= cursor || (isPrefix && entry == lastPrefix) {
+			continue
+		}
+		if count == limit {
+			result.Truncated = true
+			break
+		}
+		if isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, entry)
+			lastPrefix = entry
+		} else {
+			result.Objects = append(result.Objects, object)
+		}
+		count++
+	}
+	result.NextCursor = nextCursor(result.Truncated, "", result)
+	return result
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"upload-util/internal/config"
+)
+
+func TestListObjects(t *testing.T) {
+	keys := []string{"a/1.txt", "a/2.txt", "b/1.txt", "c.txt", "d.txt", "a-b.txt"}
+	objects := func() []ObjectInfo {
+		var objects []ObjectInfo
+		for _, key := range keys {
+			objects = append(objects, ObjectInfo{Key: key})
+		}
+		return objects
+	}
+	objectKeys := func(result *ListResult) []string {
+		var keys []string
+		for _, object := range result.Objects {
+			keys = append(keys, object.Key)
+		}
+		return keys
+	}
+
+	t.Run("delimiter", func(t *testing.T) {
+		result := listObjects(objects(), "", "/", "", 0)
+		if !reflect.DeepEqual(objectKeys(result), []string{"a-b.txt", "c.txt", "d.txt"}) {
+			t.Errorf("unexpected objects: %v", objectKeys(result))
+		}
+		if !reflect.DeepEqual(result.CommonPrefixes, []string{"a/", "b/"}) {
+			t.Errorf("unexpected prefixes: %v", result.CommonPrefixes)
+		}
+		if result.Truncated {
+			t.Error("expected complete result")
+		}
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		result := listObjects(objects(), "a/", "/", "", 0)
+		if !reflect.DeepEqual(objectKeys(result), []string{"a/1.txt", "a/2.txt"}) {
+			t.Errorf("unexpected objects: %v", objectKeys(result))
+		}
+	})
+
+	t.Run("paginate", func(t *testing.T) {
+		var pages [][]string
+		cursor := ""
+		for {
+			result := listObjects(objects(), "", "/", cursor, 2)
+			pages = append(pages, append(objectKeys(result), result.CommonPrefixes...))
+			if !result.Truncated {
+				break
+			}
+			cursor = result.NextCursor
+		}
+		want := [][]string{{"a-b.txt", "a/"}, {"c.txt", "b/"}, {"d.txt"}}
+		if !reflect.DeepEqual(pages, want) {
+			t.Errorf("expected pages %v, got %v", want, pages)
+		}
+	})
+}
+
+func TestLocalList(t *testing.T) {
+	dir := t.TempDir()
+	// 正在写入的临时文件不出现在列举结果中
+	for _, key := range []string{"uploads/a/1.txt", "uploads/2.txt", "other/3.txt", "uploads/3.txt.123456.tmp", "uploads/4.txt.42.part"} {
+		path := filepath.Join(dir, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: dir}, &config.UploadSettings{})
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+
+	result, err := uploader.List(context.Background(), "uploads/", "/", "", 0)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(result.Objects) != 1 || result.Objects[0].Key != "uploads/2.txt" || result.Objects[0].Size != 4 {
+		t.Errorf("unexpected objects: %+v", result.Objects)
+	}
+	if !reflect.DeepEqual(result.CommonPrefixes, []string{"uploads/a/"}) {
+		t.Errorf("unexpected prefixes: %v", result.CommonPrefixes)
+	}
+
+	result, err = uploader.List(context.Background(), "missing/", "", "", 0)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(result.Objects) != 0 {
+		t.Errorf("expected no objects, got %+v", result.Objects)
+	}
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultMigrateConcurrency = 4
+
+// MigrateOptions 在两个上传器之间迁移对象的参数。
+// 对象按原样复制，不受目标存储 upload-settings 中大小、扩展名和内容类型规则的限制；
+// 目标配置了缩略图时按目标的配置为图片重新生成，源存储中的缩略图不会出现在列举结果中，不单独复制
+type MigrateOptions struct {
+	// Prefix 只迁移该前缀下的对象
+	Prefix string
+	// Concurrency 同时迁移的对象数量，默认 4
+	Concurrency int
+	// Checkpoint 记录已完成对象的文件 (JSON Lines)，重新运行时跳过其中大小和 ETag 未变化的对象
+	Checkpoint string
+	// DryRun 只列出需要迁移的对象，不写入目标
+	DryRun bool
+	// DeleteSource 校验通过后删除源对象
+	DeleteSource bool
+	// Progress 每个对象处理完成后调用，会被多个协程并发调用
+	Progress func(MigrateObject)
+}
+
+// MigrateObject 单个对象的迁移结果，成功的对象同时作为 checkpoint 中的记录
+type MigrateObject struct {
+	Key  string    `json:"key"`
+	Size int64     `json:"size"`
+	ETag string    `json:"etag,omitempty"`
+	MD5  string    `json:"md5,omitempty"`
+	Time time.Time `json:"time"`
+	// Skipped checkpoint 中已完成，本次未复制
+	Skipped bool  `json:"-"`
+	Error   error `json:"-"`
+}
+
+// MigrateResult 迁移汇总
+type MigrateResult struct {
+	DryRun   bool
+	Objects  int
+	Migrated int
+	Skipped  int
+	Bytes    int64
+	Failed   []MigrateObject
+}
+
+// Migrate 列举 src 中前缀下的所有对象并复制到 dst，保持对象键、内容类型和自定义元数据不变。
+// 每个对象写入后比对 MD5，成功的对象追加到 checkpoint，中断后重新运行会跳过已完成的对象
+func Migrate(ctx context.Context, src, dst Uploader, opts MigrateOptions) (*MigrateResult, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultMigrateConcurrency
+	}
+	done, err := readMigrateCheckpoint(opts.Checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint *migrateCheckpoint
+	if opts.Checkpoint != "" && !opts.DryRun {
+		checkpoint, err = openMigrateCheckpoint(opts.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		defer checkpoint.Close()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	objects := make(chan ObjectInfo)
+	var listErr error
+	go func() {
+		defer close(objects)
+		listErr = listAll(ctx, src, opts.Prefix, func(info ObjectInfo) bool {
+			select {
+			case objects <- info:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	result := &MigrateResult{DryRun: opts.DryRun}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for info := range objects {
+				object := migrateObject(ctx, src, dst, info, done, checkpoint, opts)
+				mu.Lock()
+				result.Objects++
+				switch {
+				case object.Error != nil:
+					result.Failed = append(result.Failed, object)
+				case object.Skipped:
+					result.Skipped++
+				default:
+					result.Migrated++
+					result.Bytes += object.Size
+				}
+				mu.Unlock()
+				if opts.Progress != nil {
+					opts.Progress(object)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if listErr != nil {
+		return result, fmt.Errorf("failed to list source objects: %w", listErr)
+	}
+	return result, ctx.Err()
+}
+
+// listAll 分页列举前缀下的所有对象，fn 返回 false 时停止
+func listAll(ctx context.Context, u Uploader, prefix string, fn func(ObjectInfo) bool) error {
+	cursor := ""
+	for {
+		page, err := u.List(ctx, prefix, "", cursor, 0)
+		if err != nil {
+			return err
+		}
+		for _, info := range page.Objects {
+			if !fn(info) {
+				return ctx.Err()
+			}
+		}
+		if !page.Truncated {
+			return nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func migrateObject(ctx context.Context, src, dst Uploader, info ObjectInfo, done map[string]MigrateObject, checkpoint *migrateCheckpoint, opts MigrateOptions) MigrateObject {
+	object := MigrateObject{Key: info.Key, Size: info.Size, ETag: info.ETag}
+	if previous, ok := done[info.Key]; ok && previous.Size == info.Size && (previous.ETag == "" || info.ETag == "" || previous.ETag == info.ETag) {
+		object.Skipped = true
+		// 上次复制完成但删除源对象失败时补删
+		if opts.DeleteSource && !opts.DryRun {
+			object.Error = deleteSource(ctx, src, info.Key)
+		}
+		return object
+	}
+	if opts.DryRun {
+		return object
+	}
+
+	sum, err := copyObjectTo(ctx, src, dst, info.Key)
+	if err == nil {
+		err = verifyObject(ctx, dst, info.Key, info.Size, sum)
+	}
+	if err != nil {
+		object.Error = err
+		return object
+	}
+	object.MD5 = sum
+	object.Time = time.Now()
+	if checkpoint != nil {
+		if err := checkpoint.Append(object); err != nil {
+			object.Error = fmt.Errorf("failed to write checkpoint: %w", err)
+			return object
+		}
+	}
+	if opts.DeleteSource {
+		object.Error = deleteSource(ctx, src, info.Key)
+	}
+	return object
+}
+
+// copyObjectTo 将 src 中的对象以相同的键、内容类型和元数据写入 dst，返回内容的 MD5
+func copyObjectTo(ctx context.Context, src, dst Uploader, key string) (string, error) {
+	info, err := src.Stat(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	reader, err := src.Open(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	h := md5.New()
+	opts := []UploadOption{WithKey(key), WithContentType(info.ContentType), WithMetadata(info.Metadata), withVerbatim()}
+	if _, err := dst.UploadReader(ctx, io.TeeReader(reader, h), key, info.Size, opts...); err != nil {
+		return "", fmt.Errorf("failed to write destination: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyObject 比对目标对象的大小和 MD5，ETag 即为 MD5 (非分片上传) 时不再读取内容
+func verifyObject(ctx context.Context, u Uploader, key string, size int64, sum string) error {
+	info, err := u.Stat(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to stat destination: %w", err)
+	}
+	if info.Size != size {
+		return fmt.Errorf("%w: %s: source %d bytes, destination %d bytes", ErrChecksumMismatch, key, size, info.Size)
+	}
+	if etag, ok := ETagMD5(info.ETag); ok && etag == sum {
+		return nil
+	}
+	reader, err := u.Open(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read destination: %w", err)
+	}
+	defer reader.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return fmt.Errorf("failed to read destination: %w", err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("%w: %s: source %s, destination %s", ErrChecksumMismatch, key, sum, got)
+	}
+	return nil
+}
+
+func deleteSource(ctx context.Context, src Uploader, key string) error {
+	if err := src.Delete(ctx, key); err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return fmt.Errorf("failed to delete source: %w", err)
+	}
+	return nil
+}
+
+// migrateCheckpoint 追加写入已完成对象的记录
+type migrateCheckpoint struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func openMigrateCheckpoint(path string) (*migrateCheckpoint, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	return &migrateCheckpoint{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (c *migrateCheckpoint) Append(object MigrateObject) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.encoder.Encode(object)
+}
+
+func (c *migrateCheckpoint) Close() error {
+	return c.file.Close()
+}
+
+// readMigrateCheckpoint 读取已完成的对象，文件不存在时返回空记录
+func readMigrateCheckpoint(path string) (map[string]MigrateObject, error) {
+	done := make(map[string]MigrateObject)
+	if path == "" {
+		return done, nil
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var object MigrateObject
+		// 中断时最后一行可能不完整，忽略无法解析的记录
+		if err := json.Unmarshal(scanner.Bytes(), &object); err != nil {
+			continue
+		}
+		done[object.Key] = object
+	}
+	return done, scanner.Err()
+}
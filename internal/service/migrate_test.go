@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"upload-util/internal/config"
+)
+
+func newMigrateTestUploader(t *testing.T) *LocalUploader {
+	t.Helper()
+	u, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{MaxFileSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newMigrateTestUploader(t), newMigrateTestUploader(t)
+	for _, key := range []string{"docs/a.txt", "docs/sub/b.txt", "other/c.txt"} {
+		if _, err := src.UploadReader(ctx, strings.NewReader("content of "+key), key, -1, WithKey(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+
+	// 试运行不写入目标
+	result, err := Migrate(ctx, src, dst, MigrateOptions{Prefix: "docs/", Checkpoint: checkpoint, DryRun: true})
+	if err != nil || result.Migrated != 2 {
+		t.Fatalf("unexpected dry run result: %+v, %v", result, err)
+	}
+	if _, err := dst.Stat(ctx, "docs/a.txt"); err == nil {
+		t.Error("dry run should not write destination")
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Error("dry run should not write checkpoint")
+	}
+
+	result, err = Migrate(ctx, src, dst, MigrateOptions{Prefix: "docs/", Checkpoint: checkpoint, Concurrency: 2})
+	if err != nil || result.Migrated != 2 || len(result.Failed) != 0 {
+		t.Fatalf("unexpected result: %+v, %v", result, err)
+	}
+	for _, key := range []string{"docs/a.txt", "docs/sub/b.txt"} {
+		if got := readMirrorObject(t, dst, key); got != "content of "+key {
+			t.Errorf("%s: unexpected content %q", key, got)
+		}
+	}
+	if _, err := dst.Stat(ctx, "other/c.txt"); err == nil {
+		t.Error("objects outside prefix should not be migrated")
+	}
+
+	// 重新运行跳过 checkpoint 中的对象，并删除源对象
+	result, err = Migrate(ctx, src, dst, MigrateOptions{Prefix: "docs/", Checkpoint: checkpoint, DeleteSource: true})
+	if err != nil || result.Skipped != 2 || result.Migrated != 0 {
+		t.Fatalf("unexpected resumed result: %+v, %v", result, err)
+	}
+	if _, err := src.Stat(ctx, "docs/a.txt"); err == nil {
+		t.Error("expected source deleted")
+	}
+}
+
+func TestMigrateFailure(t *testing.T) {
+	ctx := context.Background()
+	src := newMigrateTestUploader(t)
+	// 目标存储写入失败
+	dst := &transientUploader{Uploader: newMigrateTestUploader(t), failures: 1, err: errBackendDown}
+	if _, err := src.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5, WithKey("a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+	result, err := Migrate(ctx, src, dst, MigrateOptions{Checkpoint: checkpoint, DeleteSource: true})
+	if err != nil || len(result.Failed) != 1 || result.Failed[0].Key != "a.txt" {
+		t.Fatalf("unexpected result: %+v, %v", result, err)
+	}
+	// 失败的对象不删除源对象，也不记录到 checkpoint
+	if _, err := src.Stat(ctx, "a.txt"); err != nil {
+		t.Errorf("source should be kept: %v", err)
+	}
+	if data, _ := os.ReadFile(checkpoint); len(data) != 0 {
+		t.Errorf("unexpected checkpoint: %s", data)
+	}
+}
+
+func TestMigrateIgnoresUploadRules(t *testing.T) {
+	ctx := context.Background()
+	srcDir := t.TempDir()
+	src, err := NewLocalUploader(&config.LocalConfig{Path: srcDir}, &config.UploadSettings{MaxFileSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{
+		MaxFileSize:       1,
+		AllowedExtensions: []string{".png"},
+		AllowedMimeTypes:  []string{"image/*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 源存储中已有的对象不符合目标的上传规则：扩展名不允许、内容与扩展名不符、超过大小限制
+	objects := map[string]string{
+		"a.txt":   "hello",
+		"b.png":   "not a png",
+		"big.png": strings.Repeat("x", 1024*1024+1),
+	}
+	for key, content := range objects {
+		if err := os.WriteFile(filepath.Join(srcDir, key), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Migrate(ctx, src, dst, MigrateOptions{})
+	if err != nil || result.Migrated != len(objects) || len(result.Failed) != 0 {
+		t.Fatalf("unexpected result: %+v, %v", result, err)
+	}
+	for key, content := range objects {
+		if got := readMirrorObject(t, dst, key); got != content {
+			t.Errorf("%s: unexpected content (%d bytes)", key, len(got))
+		}
+	}
+	// 普通上传仍按目标的规则校验
+	if _, err := dst.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5); !errors.Is(err, ErrExtensionNotAllowed) {
+		t.Errorf("expected ErrExtensionNotAllowed, got %v", err)
+	}
+}
@@ -1,9 +1,12 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"net/http"
 	"strings"
 	"upload-util/internal/config"
 
@@ -39,21 +42,68 @@ func NewMinIOUploader(config *config.MinioConfig, settings *config.UploadSetting
 }
 
 func (u *MinIOUploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	return u.UploadReader(ctx, file, header.Filename, header.Size)
+}
+
+// UploadReader 从任意 io.Reader 上传，size 为 -1 时以分片方式流式上传
+func (u *MinIOUploader) UploadReader(ctx context.Context, file io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	options := newUploadOptions(opts)
+	header := &multipart.FileHeader{Filename: name, Size: size}
+
 	// 验证文件
-	if err := validateFile(header, u.settings); err != nil {
+	if err := options.validate(header, u.settings); err != nil {
 		return nil, fmt.Errorf("file validation failed: %w", err)
 	}
+	file = options.limitSize(file, size, u.settings)
+	// 内容寻址策略先计算哈希，由哈希生成对象键
+	content, err := hashContent(file, size, u.settings, options)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	file, size = content.Reader, content.size
+	// 识别内容的真实类型，拒绝与扩展名不符的文件
+	file, sniffed, err := options.sniff(file, name, u.settings)
+	if err != nil {
+		return nil, err
+	}
 
 	// 生成文件名和对象键
-	filename := generateFileName(header, u.settings)
-	objectKey := buildObjectKey(filename, u.config.PathPrefix)
+	objectKey := options.Key
+	if objectKey == "" {
+		objectKey = buildObjectKey(generateFileName(header, u.settings, content.sum, options.User), u.config.PathPrefix)
+	}
+	contentType := options.contentType(name)
+	if result, err := findDuplicate(ctx, u, content, objectKey, contentType, sniffed); result != nil || err != nil {
+		return result, err
+	}
 
-	// 上传文件
-	info, err := u.client.PutObject(ctx, u.config.Bucket, objectKey, file, header.Size, minio.PutObjectOptions{
-		ContentType: getMimeType(filename),
-	})
+	// 计算校验和
+	source, err := newChecksumSource(ctx, file, size, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to upload file to minio: %w", err)
+		return nil, err
+	}
+
+	// 上传文件，超过阈值时使用分片上传
+	var etag string
+	if shouldUseMultipart(size, u.settings) {
+		etag, err = u.multipartUpload(ctx, objectKey, source, contentType, options.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to multipart upload file to minio: %w", err)
+		}
+	} else {
+		info, err := u.client.PutObject(ctx, u.config.Bucket, objectKey, source.Reader, size, minio.PutObjectOptions{
+			ContentType:    contentType,
+			UserMetadata:   options.Metadata,
+			SendContentMd5: true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload file to minio: %w", err)
+		}
+		etag = info.ETag
+		if err := verifyUpload(ctx, u, objectKey, source, etag, ""); err != nil {
+			return nil, err
+		}
 	}
 
 	// 生成访问 URL
@@ -63,13 +113,57 @@ func (u *MinIOUploader) Upload(ctx context.Context, file multipart.File, header
 	}
 
 	return &UploadResult{
-		URL:      url,
-		Key:      objectKey,
-		Size:     info.Size,
-		MimeType: getMimeType(filename),
+		URL:         url,
+		Key:         objectKey,
+		Size:        source.Size(),
+		MimeType:    contentType,
+		SniffedType: sniffed,
+		ETag:        strings.Trim(etag, `"`),
+		Checksums:   source.Checksums(),
 	}, nil
 }
 
+// multipartUpload 分片上传，每个分片携带 Content-MD5，返回合并后对象的 ETag
+func (u *MinIOUploader) multipartUpload(ctx context.Context, objectKey string, file io.Reader, contentType string, metadata map[string]string) (string, error) {
+	core := minio.Core{Client: u.client}
+	opts := minio.PutObjectOptions{ContentType: contentType, UserMetadata: metadata}
+	uploadID, err := core.NewMultipartUpload(ctx, u.config.Bucket, objectKey, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	var parts []minio.CompletePart
+	_, err = uploadLimitedParts(ctx, file, partSize(u.settings), func(partNumber int, part *bytes.Reader) error {
+		contentMD5, err := partMD5(part)
+		if err != nil {
+			return err
+		}
+		uploaded, err := core.PutObjectPart(ctx, u.config.Bucket, objectKey, uploadID, partNumber, part, part.Size(), minio.PutObjectPartOptions{Md5Base64: contentMD5})
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+		}
+		parts = append(parts, minio.CompletePart{
+			PartNumber: uploaded.PartNumber,
+			ETag:       uploaded.ETag,
+		})
+		return nil
+	})
+	var info minio.UploadInfo
+	if err == nil {
+		if info, err = core.CompleteMultipartUpload(ctx, u.config.Bucket, objectKey, uploadID, parts, opts); err != nil {
+			err = fmt.Errorf("failed to complete multipart upload: %w", err)
+		}
+	}
+	if err != nil {
+		// 中止未完成的分片上传，避免残留分片占用存储空间
+		abortCtx, cancel := abortContext(ctx)
+		defer cancel()
+		_ = core.AbortMultipartUpload(abortCtx, u.config.Bucket, objectKey, uploadID)
+		return "", err
+	}
+	return info.ETag, nil
+}
+
 func (u *MinIOUploader) Delete(ctx context.Context, key string) error {
 	err := u.client.RemoveObject(ctx, u.config.Bucket, key, minio.RemoveObjectOptions{})
 	if err != nil {
@@ -78,7 +172,129 @@ func (u *MinIOUploader) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
-func (u *MinIOUploader) GetURL(ctx context.Context, key string) (string, error) {
+// Open 读取对象内容，可通过 WithRange 只读取一部分
+func (u *MinIOUploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	options := newOpenOptions(opts)
+	getOptions := minio.GetObjectOptions{}
+	if rangeHeader := options.rangeHeader(); rangeHeader != "" {
+		getOptions.Set("Range", rangeHeader)
+	}
+	core := minio.Core{Client: u.client}
+	body, _, header, err := core.GetObject(ctx, u.config.Bucket, key, getOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object from minio: %w", objectError(minio.ToErrorResponse(err).StatusCode, err))
+	}
+	return newObjectReaderFromHeader(body, key, header), nil
+}
+
+// Stat 获取对象元数据，对象不存在时返回 ErrObjectNotFound
+func (u *MinIOUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	stat, err := u.client.StatObject(ctx, u.config.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat object from minio: %w", objectError(minio.ToErrorResponse(err).StatusCode, err))
+	}
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         stat.Size,
+		ContentType:  stat.ContentType,
+		LastModified: stat.LastModified,
+		ETag:         stat.ETag,
+	}
+	if len(stat.UserMetadata) > 0 {
+		info.Metadata = make(map[string]string, len(stat.UserMetadata))
+		for name, value := range stat.UserMetadata {
+			info.Metadata[strings.ToLower(name)] = value
+		}
+	}
+	return info, nil
+}
+
+// List 按前缀列举对象，cursor 为上一页返回的 NextCursor (ListObjectsV2 的 ContinuationToken)
+func (u *MinIOUploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	core := minio.Core{Client: u.client}
+	output, err := core.ListObjectsV2(u.config.Bucket, prefix, "", cursor, delimiter, listLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects from minio: %w", err)
+	}
+	result := &ListResult{
+		Objects:    make([]ObjectInfo, 0, len(output.Contents)),
+		Truncated:  output.IsTruncated,
+		NextCursor: output.NextContinuationToken,
+	}
+	for _, object := range output.Contents {
+		result.Objects = append(result.Objects, ObjectInfo{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+			ETag:         strings.Trim(object.ETag, `"`),
+		})
+	}
+	for _, commonPrefix := range output.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix.Prefix)
+	}
+	return result, nil
+}
+
+// Copy 服务端复制对象，数据不经过本服务
+func (u *MinIOUploader) Copy(ctx context.Context, src, dst string) error {
+	if err := checkCopyKeys(src, dst); err != nil {
+		return err
+	}
+	_, err := u.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: u.config.Bucket, Object: dst},
+		minio.CopySrcOptions{Bucket: u.config.Bucket, Object: src},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy object in minio: %w", objectError(minio.ToErrorResponse(err).StatusCode, err))
+	}
+	return nil
+}
+
+// Move 服务端复制后删除源对象
+func (u *MinIOUploader) Move(ctx context.Context, src, dst string) error {
+	return moveObject(ctx, u, src, dst)
+}
+
+// DeleteMany 批量删除对象，SDK 内部按 1000 个一批提交
+func (u *MinIOUploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	objects := make(chan minio.ObjectInfo, len(keys))
+	for _, key := range keys {
+		objects <- minio.ObjectInfo{Key: key}
+	}
+	close(objects)
+
+	result := newDeleteResult()
+	failed := make(map[string]bool)
+	for removeErr := range u.client.RemoveObjects(ctx, u.config.Bucket, objects, minio.RemoveObjectsOptions{}) {
+		failed[removeErr.ObjectName] = true
+		result.addError(removeErr.ObjectName, removeErr.Err.Error())
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	for _, key := range keys {
+		if !failed[key] {
+			result.Deleted = append(result.Deleted, key)
+		}
+	}
+	return result, nil
+}
+
+// DeletePrefix 删除前缀下的所有对象，dryRun 时只列出将要删除的对象
+func (u *MinIOUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	return deletePrefix(ctx, u, prefix, dryRun)
+}
+
+// GetURL 返回对象的访问地址，私有模式下返回带有效期的签名链接
+func (u *MinIOUploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	if u.config.Private {
+		expires := newURLOptions(opts).expires(u.config.SignURLExpire)
+		signURL, err := u.client.PresignedGetObject(ctx, u.config.Bucket, key, expires, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign get url: %w", err)
+		}
+		return signURL.String(), nil
+	}
 	if u.config.Domain != "" {
 		return fmt.Sprintf("%s/%s", strings.TrimRight(u.config.Domain, "/"), key), nil
 	}
@@ -89,3 +305,46 @@ func (u *MinIOUploader) GetURL(ctx context.Context, key string) (string, error)
 	}
 	return fmt.Sprintf("%s://%s/%s/%s", protocol, u.config.Endpoint, u.config.Bucket, key), nil
 }
+
+func (u *MinIOUploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	params, err := preparePresign(req, u.settings, u.config.PathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.method == http.MethodPut {
+		signURL, err := u.client.PresignedPutObject(ctx, u.config.Bucket, params.key, params.expire)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign put url: %w", err)
+		}
+		return params.result(signURL.String()), nil
+	}
+
+	policy := minio.NewPostPolicy()
+	if err := policy.SetBucket(u.config.Bucket); err != nil {
+		return nil, err
+	}
+	if err := policy.SetKey(params.key); err != nil {
+		return nil, err
+	}
+	if err := policy.SetExpires(params.expires); err != nil {
+		return nil, err
+	}
+	if err := policy.SetContentType(params.contentType); err != nil {
+		return nil, err
+	}
+	if err := policy.SetContentLengthRange(0, params.maxSize); err != nil {
+		return nil, err
+	}
+	postURL, fields, err := u.client.PresignedPostPolicy(ctx, policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign post policy: %w", err)
+	}
+	result := params.result(postURL.String())
+	result.Fields = fields
+	return result, nil
+}
+
+func (u *MinIOUploader) pathPrefix() string {
+	return u.config.PathPrefix
+}
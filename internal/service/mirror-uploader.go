@@ -0,0 +1,556 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"sync"
+	"time"
+	"upload-util/internal/config"
+)
+
+// ErrQuorumNotReached 写入成功的存储数量未达到要求
+var ErrQuorumNotReached = errors.New("mirror write quorum not reached")
+
+// 修复日志中的操作
+const (
+	// RepairPut 副本缺少对象，需要从主存储复制；主存储缺少对象时从副本复制
+	RepairPut = "put"
+	// RepairDelete 副本中的对象需要删除
+	RepairDelete = "delete"
+)
+
+// RepairEntry 修复日志中的一条记录
+type RepairEntry struct {
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+	Backend string    `json:"backend"`
+	Key     string    `json:"key"`
+	Error   string    `json:"error"`
+}
+
+// MirrorUploader 将每次上传同时写入主存储和副本的上传器。
+// 对象键由镜像按主存储的 path-prefix 统一生成后通过 WithKey 传给各存储，保证所有副本的键相同；
+// 读取、列举和获取链接使用主存储，删除、复制和移动同步到所有副本
+type MirrorUploader struct {
+	settings  *config.UploadSettings
+	policy    string
+	quorum    int
+	repairLog string
+
+	// names 和 children 中第一个为主存储
+	names    []string
+	children []Uploader
+
+	logMu   sync.Mutex
+	pending sync.WaitGroup
+}
+
+type mirrorWrite struct {
+	result *UploadResult
+	err    error
+}
+
+func init() {
+	RegisterBackend("mirror", func(cfg *BackendConfig) (Uploader, error) {
+		var mirror config.MirrorConfig
+		if err := cfg.Decode(&mirror); err != nil {
+			return nil, err
+		}
+		return NewMirrorUploader(&mirror, cfg.Settings, cfg.Profile)
+	})
+}
+
+// NewMirrorUploader 创建镜像上传器，settings 用于生成对象键，profile 根据名称创建主存储和副本
+func NewMirrorUploader(cfg *config.MirrorConfig, settings *config.UploadSettings, profile func(name string) (Uploader, error)) (*MirrorUploader, error) {
+	if cfg.Primary == "" || len(cfg.Replicas) == 0 {
+		return nil, fmt.Errorf("mirror requires a primary and at least one replica")
+	}
+	u := &MirrorUploader{
+		settings:  settings,
+		policy:    cfg.WritePolicy,
+		quorum:    cfg.Quorum,
+		repairLog: cfg.RepairLog,
+	}
+	switch u.policy {
+	case "":
+		u.policy = config.WritePolicyAll
+	case config.WritePolicyAll, config.WritePolicyAsync, config.WritePolicyQuorum:
+	default:
+		return nil, fmt.Errorf("unsupported mirror write-policy: %s", cfg.WritePolicy)
+	}
+	total := 1 + len(cfg.Replicas)
+	if u.quorum == 0 {
+		u.quorum = total/2 + 1
+	}
+	if u.quorum < 1 || u.quorum > total {
+		return nil, fmt.Errorf("mirror quorum must be between 1 and %d", total)
+	}
+	for _, name := range append([]string{cfg.Primary}, cfg.Replicas...) {
+		for _, existing := range u.names {
+			if existing == name {
+				return nil, fmt.Errorf("mirror backend %s is listed twice", name)
+			}
+		}
+		child, err := profile(name)
+		if err != nil {
+			return nil, err
+		}
+		u.names = append(u.names, name)
+		u.children = append(u.children, child)
+	}
+	return u, nil
+}
+
+func (u *MirrorUploader) primary() Uploader {
+	return u.children[0]
+}
+
+func (u *MirrorUploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	return u.UploadReader(ctx, file, header.Filename, header.Size)
+}
+
+// UploadReader 按写入策略写入主存储和副本。all 和 quorum 策略将同一个输入流同时写入各存储，
+// 失败时删除已写入的副本；async 策略写入主存储后在后台从主存储复制到副本
+func (u *MirrorUploader) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	options := newUploadOptions(opts)
+	header := &multipart.FileHeader{Filename: name, Size: size}
+	// 先校验再计算哈希，避免将会被拒绝的文件完整暂存
+	if err := options.validate(header, u.settings); err != nil {
+		return nil, fmt.Errorf("file validation failed: %w", err)
+	}
+	r = options.limitSize(r, size, u.settings)
+	content, err := hashContent(r, size, u.settings, options)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	r, size = content.Reader, content.size
+
+	key := options.Key
+	if key == "" {
+		key = buildObjectKey(generateFileName(header, u.settings, content.sum, options.User), childPathPrefix(u.primary()))
+	}
+	if result, err := findDuplicate(ctx, u, content, key, options.contentType(name), ""); result != nil || err != nil {
+		if result != nil {
+			result.Backend = joinBackend(u.names[0], result.Backend)
+		}
+		return result, err
+	}
+	opts = append(opts, WithKey(key))
+
+	if u.policy == config.WritePolicyAsync {
+		result, err := u.primary().UploadReader(ctx, r, name, size, opts...)
+		if err != nil {
+			return nil, err
+		}
+		u.replicateAsync(result)
+		result.Backend = joinBackend(u.names[0], result.Backend)
+		return result, nil
+	}
+
+	// 同时写入各存储时按输入流的读取报告进度，避免各存储并发回调
+	r = trackProgress(r, size, options.Progress)
+	writes := u.writeAll(ctx, r, name, size, append(opts[:len(opts):len(opts)], WithProgress(nil)))
+	var (
+		result    *UploadResult
+		succeeded int
+		firstErr  error
+	)
+	for i, write := range writes {
+		if write.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", u.names[i], write.err)
+			}
+			continue
+		}
+		succeeded++
+		if result == nil {
+			result = write.result
+			result.Backend = joinBackend(u.names[i], result.Backend)
+		}
+	}
+
+	required := len(u.children)
+	if u.policy == config.WritePolicyQuorum {
+		required = u.quorum
+	}
+	if succeeded < required {
+		u.rollback(key, writes)
+		if u.policy == config.WritePolicyQuorum {
+			return nil, fmt.Errorf("%w (%d/%d): %w", ErrQuorumNotReached, succeeded, required, firstErr)
+		}
+		return nil, firstErr
+	}
+	// 读取只使用主存储，quorum 策略下主存储写入失败时立即从写入成功的副本补写，补写失败则整体失败
+	if writes[0].err != nil {
+		if err := u.copyFromReplica(ctx, key); err != nil {
+			u.rollback(key, writes)
+			return nil, fmt.Errorf("%s: %w", u.names[0], err)
+		}
+		writes[0].err = nil
+	}
+	for i, write := range writes {
+		if write.err != nil {
+			u.recordRepair(RepairPut, u.names[i], key, write.err)
+		}
+	}
+	return result, nil
+}
+
+// writeAll 将输入流同时写入所有存储，某个存储失败或提前结束读取不影响其他存储
+func (u *MirrorUploader) writeAll(ctx context.Context, r io.Reader, name string, size int64, opts []UploadOption) []mirrorWrite {
+	writes := make([]mirrorWrite, len(u.children))
+	writers := make([]*io.PipeWriter, len(u.children))
+	var wg sync.WaitGroup
+	for i, child := range u.children {
+		pr, pw := io.Pipe()
+		writers[i] = pw
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := child.UploadReader(ctx, pr, name, size, opts...)
+			writes[i] = mirrorWrite{result: result, err: err}
+			// 存储不再读取时关闭管道，后续写入返回错误，由 teeWriter 跳过
+			if err == nil {
+				err = io.ErrClosedPipe
+			}
+			_ = pr.CloseWithError(err)
+		}()
+	}
+
+	_, err := io.Copy(&teeWriter{writers: writers, failed: make([]bool, len(writers))}, r)
+	for _, pw := range writers {
+		_ = pw.CloseWithError(err)
+	}
+	wg.Wait()
+	return writes
+}
+
+// teeWriter 将数据写入多个管道，写入失败的管道不再写入，全部失败时返回错误
+type teeWriter struct {
+	writers []*io.PipeWriter
+	failed  []bool
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	alive := 0
+	for i, w := range t.writers {
+		if t.failed[i] {
+			continue
+		}
+		if _, err := w.Write(p); err != nil {
+			t.failed[i] = true
+			continue
+		}
+		alive++
+	}
+	if alive == 0 {
+		return 0, io.ErrClosedPipe
+	}
+	return len(p), nil
+}
+
+// rollback 写入未达到要求时删除已写入成功的对象
+func (u *MirrorUploader) rollback(key string, writes []mirrorWrite) {
+	for i, write := range writes {
+		if write.err != nil || write.result.Deduplicated {
+			continue
+		}
+		if err := u.children[i].Delete(context.Background(), key); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			u.recordRepair(RepairDelete, u.names[i], key, err)
+		}
+	}
+}
+
+// replicateAsync 在后台从主存储读取对象写入各副本，失败时记录到修复日志
+func (u *MirrorUploader) replicateAsync(result *UploadResult) {
+	for i := 1; i < len(u.children); i++ {
+		u.pending.Add(1)
+		go func() {
+			defer u.pending.Done()
+			if err := u.copyFromPrimary(context.Background(), i, result.Key); err != nil {
+				u.recordRepair(RepairPut, u.names[i], result.Key, err)
+			}
+		}()
+	}
+}
+
+// copyFromPrimary 从主存储读取对象写入第 i 个存储，对象键保持不变
+func (u *MirrorUploader) copyFromPrimary(ctx context.Context, i int, key string) error {
+	return u.copyObject(ctx, 0, i, key)
+}
+
+// copyFromReplica 从第一个包含该对象的副本读取后写入主存储
+func (u *MirrorUploader) copyFromReplica(ctx context.Context, key string) error {
+	var lastErr error
+	for i := 1; i < len(u.children); i++ {
+		if err := u.copyObject(ctx, i, 0, key); err != nil {
+			lastErr = fmt.Errorf("copy from %s: %w", u.names[i], err)
+			continue
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// copyObject 从第 from 个存储读取对象写入第 to 个存储，对象键保持不变
+func (u *MirrorUploader) copyObject(ctx context.Context, from, to int, key string) error {
+	reader, err := u.children[from].Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = u.children[to].UploadReader(ctx, reader, key, reader.Size, WithKey(key), WithContentType(reader.ContentType))
+	return err
+}
+
+// Wait 等待 async 策略下后台复制完成
+func (u *MirrorUploader) Wait() {
+	u.pending.Wait()
+	for _, child := range u.children {
+		WaitUploader(child)
+	}
+}
+
+// Health 返回主存储和副本中的熔断状态
+func (u *MirrorUploader) Health() []BackendHealth {
+	var health []BackendHealth
+	for i, child := range u.children {
+		for _, h := range UploaderHealth(child) {
+			h.Name = joinBackend(u.names[i], h.Name)
+			health = append(health, h)
+		}
+	}
+	return health
+}
+
+// RepairUploader 按修复日志补齐 mirror 的副本，其他上传器返回错误
+func RepairUploader(ctx context.Context, u Uploader) (int, []RepairEntry, error) {
+	mirror, ok := UnwrapUploader(u).(*MirrorUploader)
+	if !ok {
+		return 0, nil, fmt.Errorf("uploader does not support repair, upload.type must be mirror")
+	}
+	return mirror.Repair(ctx)
+}
+
+// recordRepair 将副本操作失败追加到修复日志，未配置修复日志时输出日志
+func (u *MirrorUploader) recordRepair(op, backend, key string, cause error) {
+	entry := RepairEntry{Time: time.Now(), Op: op, Backend: backend, Key: key, Error: cause.Error()}
+	if u.repairLog == "" {
+		log.Printf("mirror: %s %s on %s failed: %v", op, key, backend, cause)
+		return
+	}
+	u.logMu.Lock()
+	defer u.logMu.Unlock()
+	if err := appendRepairEntries(u.repairLog, []RepairEntry{entry}); err != nil {
+		log.Printf("mirror: failed to write repair log: %v, %s %s on %s failed: %v", err, op, key, backend, cause)
+	}
+}
+
+func appendRepairEntries(path string, entries []RepairEntry) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// Repair 重放修复日志：put 从主存储复制到副本 (主存储缺少对象时从副本复制)，delete 删除副本中的对象。
+// 成功的记录从日志中移除，仍然失败的记录保留并更新错误信息
+func (u *MirrorUploader) Repair(ctx context.Context) (repaired int, remaining []RepairEntry, err error) {
+	if u.repairLog == "" {
+		return 0, nil, fmt.Errorf("mirror repair-log is not configured")
+	}
+	u.logMu.Lock()
+	defer u.logMu.Unlock()
+
+	entries, err := readRepairEntries(u.repairLog)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			remaining = append(remaining, entry)
+			continue
+		}
+		if err := u.repairEntry(ctx, entry); err != nil {
+			entry.Time, entry.Error = time.Now(), err.Error()
+			remaining = append(remaining, entry)
+			continue
+		}
+		repaired++
+	}
+
+	tmp := u.repairLog + ".tmp"
+	_ = os.Remove(tmp)
+	if err := appendRepairEntries(tmp, remaining); err != nil {
+		return repaired, remaining, fmt.Errorf("failed to write repair log: %w", err)
+	}
+	if err := os.Rename(tmp, u.repairLog); err != nil {
+		return repaired, remaining, fmt.Errorf("failed to write repair log: %w", err)
+	}
+	return repaired, remaining, ctx.Err()
+}
+
+func (u *MirrorUploader) repairEntry(ctx context.Context, entry RepairEntry) error {
+	for i, name := range u.names {
+		if name != entry.Backend {
+			continue
+		}
+		switch entry.Op {
+		case RepairPut:
+			if i == 0 {
+				return u.copyFromReplica(ctx, entry.Key)
+			}
+			return u.copyFromPrimary(ctx, i, entry.Key)
+		case RepairDelete:
+			if err := u.children[i].Delete(ctx, entry.Key); err != nil && !errors.Is(err, ErrObjectNotFound) {
+				return err
+			}
+			return nil
+		default:
+			return fmt.Errorf("unknown repair op: %s", entry.Op)
+		}
+	}
+	return fmt.Errorf("unknown mirror backend: %s", entry.Backend)
+}
+
+func readRepairEntries(path string) ([]RepairEntry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var entries []RepairEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry RepairEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid repair log entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// replicas 对所有副本执行 fn，失败时记录到修复日志
+func (u *MirrorUploader) replicas(op, key string, fn func(child Uploader) error) {
+	for i := 1; i < len(u.children); i++ {
+		// 副本中本就不存在的对象无需删除
+		if err := fn(u.children[i]); err != nil && !(op == RepairDelete && errors.Is(err, ErrObjectNotFound)) {
+			u.recordRepair(op, u.names[i], key, err)
+		}
+	}
+}
+
+// Delete 删除主存储中的对象并同步删除副本，副本删除失败记录到修复日志。
+// 主存储中不存在时仍然删除副本，避免残留
+func (u *MirrorUploader) Delete(ctx context.Context, key string) error {
+	err := u.primary().Delete(ctx, key)
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	u.replicas(RepairDelete, key, func(child Uploader) error {
+		return child.Delete(ctx, key)
+	})
+	return err
+}
+
+// DeleteMany 批量删除主存储中的对象，已删除的对象同步从副本删除
+func (u *MirrorUploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	result, err := u.primary().DeleteMany(ctx, keys)
+	if result != nil && len(result.Deleted) > 0 {
+		u.deleteFromReplicas(ctx, result.Deleted)
+	}
+	return result, err
+}
+
+// DeletePrefix 删除主存储中前缀下的对象，已删除的对象同步从副本删除
+func (u *MirrorUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	result, err := u.primary().DeletePrefix(ctx, prefix, dryRun)
+	if !dryRun && result != nil && len(result.Deleted) > 0 {
+		u.deleteFromReplicas(ctx, result.Deleted)
+	}
+	return result, err
+}
+
+func (u *MirrorUploader) deleteFromReplicas(ctx context.Context, keys []string) {
+	for i := 1; i < len(u.children); i++ {
+		result, err := u.children[i].DeleteMany(ctx, keys)
+		if err != nil {
+			for _, key := range keys {
+				u.recordRepair(RepairDelete, u.names[i], key, err)
+			}
+			continue
+		}
+		for _, failed := range result.Errors {
+			u.recordRepair(RepairDelete, u.names[i], failed.Key, errors.New(failed.Message))
+		}
+	}
+}
+
+func (u *MirrorUploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	return u.primary().Open(ctx, key, opts...)
+}
+
+func (u *MirrorUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	return u.primary().Stat(ctx, key)
+}
+
+func (u *MirrorUploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	return u.primary().List(ctx, prefix, delimiter, cursor, limit)
+}
+
+// Copy 在主存储中复制并同步到副本，副本复制失败时记录为需要从主存储补写
+func (u *MirrorUploader) Copy(ctx context.Context, src, dst string) error {
+	if err := u.primary().Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	u.replicas(RepairPut, dst, func(child Uploader) error {
+		return child.Copy(ctx, src, dst)
+	})
+	return nil
+}
+
+// Move 在主存储中移动并同步到副本，副本移动失败时记录补写目标对象并删除源对象
+func (u *MirrorUploader) Move(ctx context.Context, src, dst string) error {
+	if err := u.primary().Move(ctx, src, dst); err != nil {
+		return err
+	}
+	for i := 1; i < len(u.children); i++ {
+		if err := u.children[i].Move(ctx, src, dst); err != nil {
+			u.recordRepair(RepairPut, u.names[i], dst, err)
+			u.recordRepair(RepairDelete, u.names[i], src, err)
+		}
+	}
+	return nil
+}
+
+func (u *MirrorUploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	return u.primary().GetURL(ctx, key, opts...)
+}
+
+// Presign 签发主存储的直传，直传的对象不会自动写入副本
+func (u *MirrorUploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	return u.primary().Presign(ctx, req)
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"upload-util/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// newMirrorTestUploader 创建由 primary、a、b 三个本地存储组成的镜像，strict 只允许 .png
+func newMirrorTestUploader(t *testing.T, mirror config.MirrorConfig) *MirrorUploader {
+	t.Helper()
+	cfg := &config.UploadConfig{
+		UploadSettings: config.UploadSettings{MaxFileSize: 10, FilenameStrategy: "uuid"},
+		Profiles:       make(map[string]*config.ProfileConfig),
+	}
+	for _, name := range []string{"primary", "a", "b", "strict"} {
+		cfg.Profiles[name] = &config.ProfileConfig{
+			Upload: config.UploadProvider{Type: "local", Local: &config.LocalConfig{Path: t.TempDir()}},
+		}
+	}
+	cfg.Profiles["strict"].UploadSettings.AllowedExtensions = []string{".png"}
+	var node yaml.Node
+	if err := node.Encode(mirror); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Upload = config.UploadProvider{Type: "mirror", Backends: map[string]yaml.Node{"mirror": node}}
+	uploader, err := NewUploadFactory(cfg).CreateUploader()
+	if err != nil {
+		t.Fatalf("CreateUploader failed: %v", err)
+	}
+	return uploader.(*MirrorUploader)
+}
+
+func readMirrorObject(t *testing.T, uploader Uploader, key string) string {
+	t.Helper()
+	reader, err := uploader.Open(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Open %s failed: %v", key, err)
+	}
+	defer reader.Close()
+	data, _ := io.ReadAll(reader)
+	return string(data)
+}
+
+func TestMirrorWriteAll(t *testing.T) {
+	u := newMirrorTestUploader(t, config.MirrorConfig{Primary: "primary", Replicas: []string{"a", "b"}})
+	ctx := context.Background()
+	content := strings.Repeat("hello mirror ", 10000)
+
+	// 不可 Seek 的输入同时写入所有存储，对象键相同
+	result, err := u.UploadReader(ctx, io.NopCloser(strings.NewReader(content)), "a.txt", -1)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if result.Backend != "primary" || result.Size != int64(len(content)) {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	for i, child := range u.children {
+		if got := readMirrorObject(t, child, result.Key); got != content {
+			t.Errorf("%s: content mismatch, got %d bytes", u.names[i], len(got))
+		}
+	}
+
+	// 删除同步到所有副本
+	if err := u.Delete(ctx, result.Key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	for i, child := range u.children {
+		if _, err := child.Stat(ctx, result.Key); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("%s: expected object deleted, got %v", u.names[i], err)
+		}
+	}
+}
+
+func TestMirrorWriteAllRollback(t *testing.T) {
+	u := newMirrorTestUploader(t, config.MirrorConfig{Primary: "primary", Replicas: []string{"strict"}})
+	if _, err := u.UploadReader(context.Background(), strings.NewReader("hello"), "a.txt", 5, WithKey("a.txt")); err == nil {
+		t.Fatal("expected error when a replica rejects the file")
+	}
+	if _, err := u.primary().Stat(context.Background(), "a.txt"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected primary write rolled back, got %v", err)
+	}
+}
+
+func TestMirrorQuorum(t *testing.T) {
+	repairLog := filepath.Join(t.TempDir(), "repair.log")
+	u := newMirrorTestUploader(t, config.MirrorConfig{
+		Primary:     "primary",
+		Replicas:    []string{"a", "strict"},
+		WritePolicy: config.WritePolicyQuorum,
+		RepairLog:   repairLog,
+	})
+	result, err := u.UploadReader(context.Background(), strings.NewReader("hello"), "a.txt", 5)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	entries, err := readRepairEntries(repairLog)
+	if err != nil {
+		t.Fatalf("readRepairEntries failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Backend != "strict" || entries[0].Key != result.Key || entries[0].Op != RepairPut {
+		t.Errorf("unexpected repair log: %+v", entries)
+	}
+
+	u.quorum = 3
+	if _, err := u.UploadReader(context.Background(), strings.NewReader("hello"), "b.txt", 5); !errors.Is(err, ErrQuorumNotReached) {
+		t.Errorf("expected ErrQuorumNotReached, got %v", err)
+	}
+}
+
+func TestMirrorQuorumPrimaryFailure(t *testing.T) {
+	repairLog := filepath.Join(t.TempDir(), "repair.log")
+	u := newMirrorTestUploader(t, config.MirrorConfig{
+		Primary:     "primary",
+		Replicas:    []string{"a", "b"},
+		WritePolicy: config.WritePolicyQuorum,
+		RepairLog:   repairLog,
+	})
+	ctx := context.Background()
+	primary := u.children[0]
+	u.children[0] = &transientUploader{Uploader: primary, failures: 1, err: errors.New("connection reset")}
+
+	// 主存储写入失败但达到 quorum 时从副本补写主存储，上传后可以直接读取
+	result, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if got := readMirrorObject(t, u, result.Key); got != "hello" {
+		t.Errorf("expected primary content hello, got %q", got)
+	}
+	if entries, _ := readRepairEntries(repairLog); len(entries) != 0 {
+		t.Errorf("expected no repair entries, got %+v", entries)
+	}
+
+	// 修复日志中的主存储记录从副本补写
+	if err := primary.Delete(ctx, result.Key); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendRepairEntries(repairLog, []RepairEntry{{Op: RepairPut, Backend: "primary", Key: result.Key}}); err != nil {
+		t.Fatal(err)
+	}
+	if repaired, remaining, err := u.Repair(ctx); err != nil || repaired != 1 || len(remaining) != 0 {
+		t.Fatalf("Repair = %d, %v, %v", repaired, remaining, err)
+	}
+	if got := readMirrorObject(t, u, result.Key); got != "hello" {
+		t.Errorf("expected repaired primary content hello, got %q", got)
+	}
+
+	// 主存储写入和从两个副本补写都失败时整体失败并回滚副本
+	u.children[0] = &transientUploader{Uploader: primary, failures: 3, err: errors.New("connection reset")}
+	if _, err := u.UploadReader(ctx, strings.NewReader("hello"), "b.txt", 5, WithKey("b.txt")); err == nil {
+		t.Fatal("expected error when the primary cannot be written")
+	}
+	for i, child := range u.children {
+		if _, err := child.Stat(ctx, "b.txt"); !errors.Is(err, ErrObjectNotFound) {
+			t.Errorf("%s: expected object rolled back, got %v", u.names[i], err)
+		}
+	}
+}
+
+func TestMirrorAsyncAndRepair(t *testing.T) {
+	repairLog := filepath.Join(t.TempDir(), "repair.log")
+	u := newMirrorTestUploader(t, config.MirrorConfig{
+		Primary:     "primary",
+		Replicas:    []string{"a", "b"},
+		WritePolicy: config.WritePolicyAsync,
+		RepairLog:   repairLog,
+	})
+	ctx := context.Background()
+	result, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	u.Wait()
+	if got := readMirrorObject(t, u.children[2], result.Key); got != "hello" {
+		t.Errorf("expected replica content hello, got %q", got)
+	}
+
+	// 副本丢失对象后按修复日志从主存储补写
+	if err := u.children[1].Delete(ctx, result.Key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := appendRepairEntries(repairLog, []RepairEntry{{Op: RepairPut, Backend: "a", Key: result.Key}}); err != nil {
+		t.Fatal(err)
+	}
+	repaired, remaining, err := u.Repair(ctx)
+	if err != nil || repaired != 1 || len(remaining) != 0 {
+		t.Fatalf("Repair = %d, %v, %v", repaired, remaining, err)
+	}
+	if got := readMirrorObject(t, u.children[1], result.Key); got != "hello" {
+		t.Errorf("expected repaired content hello, got %q", got)
+	}
+	if data, _ := os.ReadFile(repairLog); len(data) != 0 {
+		t.Errorf("expected empty repair log, got %q", data)
+	}
+}
+
+// prefixedUploader 模拟配置了 path-prefix 的存储
+type prefixedUploader struct {
+	Uploader
+	prefix string
+}
+
+func (p *prefixedUploader) pathPrefix() string {
+	return p.prefix
+}
+
+// unreadable 读取时报错，用于确认校验失败的文件不会被读取
+type unreadable struct {
+	t *testing.T
+}
+
+func (r unreadable) Read(p []byte) (int, error) {
+	r.t.Error("content should not be read")
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestMirrorPathPrefix(t *testing.T) {
+	u := newMirrorTestUploader(t, config.MirrorConfig{Primary: "primary", Replicas: []string{"a"}})
+	u.settings.FilenameStrategy = "sha256"
+	u.children[0] = &prefixedUploader{Uploader: u.children[0], prefix: "media"}
+	ctx := context.Background()
+
+	// 对象键使用主存储的 path-prefix，所有存储的键相同
+	result, err := u.UploadReader(ctx, io.NopCloser(strings.NewReader("hello")), "a.txt", -1)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if !strings.HasPrefix(result.Key, "media/") {
+		t.Errorf("expected key under media/, got %s", result.Key)
+	}
+	for i, child := range u.children {
+		if got := readMirrorObject(t, child, result.Key); got != "hello" {
+			t.Errorf("%s: unexpected content %q", u.names[i], got)
+		}
+	}
+
+	// 超过大小限制的文件在计算哈希之前拒绝
+	if _, err := u.UploadReader(ctx, unreadable{t}, "big.txt", 11*1024*1024); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("expected ErrFileTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"upload-util/internal/config"
+)
+
+const (
+	defaultMultipartThreshold = 100 // MB
+	defaultPartSize           = 8   // MB
+	minPartSize               = 5   // MB，S3 兼容存储要求除最后一片外每片不小于 5MB
+	abortTimeout              = 30 * time.Second
+	// maxMultipartParts 云存储分片上传的最大分片数
+	maxMultipartParts = 10000
+)
+
+// ErrTooManyParts 分片数超过云存储的上限，需要增大 part-size
+var ErrTooManyParts = errors.New("multipart upload exceeds the part limit")
+
+// multipartThreshold 返回分片上传阈值 (字节)
+func multipartThreshold(settings *config.UploadSettings) int64 {
+	threshold := settings.MultipartThreshold
+	if threshold <= 0 {
+		threshold = defaultMultipartThreshold
+	}
+	return threshold * 1024 * 1024
+}
+
+// partSize 返回分片大小 (字节)
+func partSize(settings *config.UploadSettings) int64 {
+	size := settings.PartSize
+	if size <= 0 {
+		size = defaultPartSize
+	}
+	if size < minPartSize {
+		size = minPartSize
+	}
+	return size * 1024 * 1024
+}
+
+// shouldUseMultipart 判断是否需要分片上传，大小未知 (<0) 时同样走分片上传
+func shouldUseMultipart(size int64, settings *config.UploadSettings) bool {
+	return size < 0 || size > multipartThreshold(settings)
+}
+
+// uploadLimitedParts 与 uploadParts 相同，内容超过 maxMultipartParts 个分片时在上传多出的分片前返回 ErrTooManyParts，
+// 避免大小未知的流在上传了上限数量的分片后才由云存储拒绝
+func uploadLimitedParts(ctx context.Context, r io.Reader, chunkSize int64, fn func(partNumber int, part *bytes.Reader) error) (int64, error) {
+	return uploadParts(ctx, r, chunkSize, func(partNumber int, part *bytes.Reader) error {
+		if partNumber > maxMultipartParts {
+			return fmt.Errorf("%w: more than %d parts of %d MB, increase part-size", ErrTooManyParts, maxMultipartParts, chunkSize/1024/1024)
+		}
+		return fn(partNumber, part)
+	})
+}
+
+// uploadParts 按分片大小依次读取 r，对每个分片调用 fn，返回读取的总字节数。
+// 每个分片开始前检查 ctx，被取消时立即返回，由调用方负责中止分片上传。
+func uploadParts(ctx context.Context, r io.Reader, chunkSize int64, fn func(partNumber int, part *bytes.Reader) error) (int64, error) {
+	buf := make([]byte, chunkSize)
+	var total int64
+	for partNumber := 1; ; partNumber++ {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		n, err := io.ReadFull(r, buf)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return total, err
+		}
+		// 空文件也至少上传一个分片，否则无法完成分片上传
+		if n > 0 || partNumber == 1 {
+			if err := fn(partNumber, bytes.NewReader(buf[:n])); err != nil {
+				return total, err
+			}
+			total += int64(n)
+		}
+		if err != nil {
+			return total, nil
+		}
+	}
+}
+
+// abortContext 返回用于中止分片上传的 context，即使原 ctx 已被取消也能完成清理
+func abortContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"upload-util/internal/config"
+)
+
+type bytesFile struct {
+	*bytes.Reader
+}
+
+func (f bytesFile) Close() error { return nil }
+
+func TestUploadParts(t *testing.T) {
+	t.Run("split into parts", func(t *testing.T) {
+		data := bytes.Repeat([]byte("a"), 25)
+		var sizes []int64
+		total, err := uploadParts(context.Background(), bytes.NewReader(data), 10, func(partNumber int, part *bytes.Reader) error {
+			if partNumber != len(sizes)+1 {
+				t.Errorf("expected part number %d, got %d", len(sizes)+1, partNumber)
+			}
+			sizes = append(sizes, part.Size())
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("uploadParts failed: %v", err)
+		}
+		if total != 25 {
+			t.Errorf("expected total 25, got %d", total)
+		}
+		if len(sizes) != 3 || sizes[0] != 10 || sizes[1] != 10 || sizes[2] != 5 {
+			t.Errorf("unexpected part sizes: %v", sizes)
+		}
+	})
+
+	t.Run("empty reader uploads one part", func(t *testing.T) {
+		parts := 0
+		_, err := uploadParts(context.Background(), bytes.NewReader(nil), 10, func(partNumber int, part *bytes.Reader) error {
+			parts++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("uploadParts failed: %v", err)
+		}
+		if parts != 1 {
+			t.Errorf("expected 1 part, got %d", parts)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := uploadParts(ctx, bytes.NewReader([]byte("data")), 10, func(partNumber int, part *bytes.Reader) error {
+			t.Error("fn should not be called after cancel")
+			return nil
+		})
+		if err == nil {
+			t.Error("expected error for canceled context")
+		}
+	})
+}
+
+func TestUploadLimitedParts(t *testing.T) {
+	// 正好达到上限时正常完成，多出一个分片时在上传前失败
+	for _, tt := range []struct {
+		size  int
+		parts int
+		err   error
+	}{
+		{maxMultipartParts, maxMultipartParts, nil},
+		{maxMultipartParts + 1, maxMultipartParts, ErrTooManyParts},
+	} {
+		parts := 0
+		_, err := uploadLimitedParts(context.Background(), bytes.NewReader(make([]byte, tt.size)), 1, func(partNumber int, part *bytes.Reader) error {
+			parts++
+			return nil
+		})
+		if !errors.Is(err, tt.err) || parts != tt.parts {
+			t.Errorf("size %d: expected %d parts and %v, got %d parts and %v", tt.size, tt.parts, tt.err, parts, err)
+		}
+	}
+}
+
+func TestLocalMultipartUpload(t *testing.T) {
+	dir := t.TempDir()
+	settings := &config.UploadSettings{
+		MaxFileSize:        100,
+		FilenameStrategy:   "uuid",
+		MultipartThreshold: 1,
+		PartSize:           5,
+	}
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: dir}, settings)
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+	data := bytes.Repeat([]byte("0123456789"), 1200*1024)
+	header := &multipart.FileHeader{Filename: "large.bin", Size: int64(len(data))}
+
+	t.Run("upload above threshold", func(t *testing.T) {
+		result, err := uploader.Upload(context.Background(), bytesFile{bytes.NewReader(data)}, header)
+		if err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		if result.Size != int64(len(data)) {
+			t.Errorf("expected size %d, got %d", len(data), result.Size)
+		}
+		written, err := os.ReadFile(filepath.Join(dir, result.Key))
+		if err != nil {
+			t.Fatalf("read uploaded file failed: %v", err)
+		}
+		if !bytes.Equal(written, data) {
+			t.Error("uploaded content mismatch")
+		}
+	})
+
+	t.Run("canceled upload leaves no file", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		before, _ := os.ReadDir(dir)
+		if _, err := uploader.Upload(ctx, bytesFile{bytes.NewReader(data)}, header); err == nil {
+			t.Fatal("expected error for canceled context")
+		}
+		after, _ := os.ReadDir(dir)
+		if len(after) != len(before) {
+			t.Errorf("expected %d entries after canceled upload, got %d", len(before), len(after))
+		}
+	})
+}
+
+func TestLocalUploadReaderUnknownSize(t *testing.T) {
+	dir := t.TempDir()
+	settings := &config.UploadSettings{
+		MaxFileSize:      1,
+		FilenameStrategy: "uuid",
+	}
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: dir}, settings)
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+
+	t.Run("stream without size", func(t *testing.T) {
+		data := bytes.Repeat([]byte("a"), 1000)
+		result, err := uploader.UploadReader(context.Background(), bytes.NewBuffer(data), "stream.txt", -1, WithContentType("text/csv"))
+		if err != nil {
+			t.Fatalf("UploadReader failed: %v", err)
+		}
+		if result.Size != int64(len(data)) {
+			t.Errorf("expected size %d, got %d", len(data), result.Size)
+		}
+		if result.MimeType != "text/csv" {
+			t.Errorf("expected content type text/csv, got %s", result.MimeType)
+		}
+	})
+
+	t.Run("stream exceeds max size", func(t *testing.T) {
+		data := bytes.Repeat([]byte("a"), 1024*1024+1)
+		before, _ := os.ReadDir(dir)
+		if _, err := uploader.UploadReader(context.Background(), bytes.NewBuffer(data), "big.txt", -1); !errors.Is(err, ErrFileTooLarge) {
+			t.Errorf("expected file too large, got %v", err)
+		}
+		after, _ := os.ReadDir(dir)
+		if len(after) != len(before) {
+			t.Errorf("expected %d entries after rejected upload, got %d", len(before), len(after))
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrObjectNotFound = errors.New("object not found")
+	ErrInvalidRange   = errors.New("requested range not satisfiable")
+)
+
+// ObjectInfo 对象元数据，Metadata 为上传时设置的自定义元数据
+type ObjectInfo struct {
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	ContentType  string            `json:"content_type"`
+	LastModified time.Time         `json:"last_modified"`
+	ETag         string            `json:"etag"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// ObjectReader 对象内容及其元数据，读取完毕后由调用方关闭
+type ObjectReader struct {
+	io.ReadCloser
+	Key         string
+	Size        int64 // 对象总大小
+	ContentType string
+	ETag        string
+	// 按范围读取时本次返回的内容在对象中的起始位置和长度
+	Offset int64
+	Length int64
+}
+
+// Ranged 是否只返回了对象的一部分
+func (o *ObjectReader) Ranged() bool {
+	return o.Offset > 0 || o.Length < o.Size
+}
+
+// newObjectReader 根据响应头构造 ObjectReader，contentRange 为空时表示返回完整对象
+func newObjectReader(body io.ReadCloser, key, contentType, etag string, length int64, contentRange string) *ObjectReader {
+	object := &ObjectReader{
+		ReadCloser:  body,
+		Key:         key,
+		Size:        length,
+		ContentType: contentType,
+		ETag:        strings.Trim(etag, `"`),
+		Length:      length,
+	}
+	if offset, total, ok := parseContentRange(contentRange); ok {
+		object.Offset = offset
+		object.Size = total
+	}
+	return object
+}
+
+// newObjectReaderFromHeader 从 HTTP 响应头中读取对象元数据
+func newObjectReaderFromHeader(body io.ReadCloser, key string, header http.Header) *ObjectReader {
+	length, _ := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	return newObjectReader(body, key, header.Get("Content-Type"), header.Get("ETag"), length, header.Get("Content-Range"))
+}
+
+// newObjectInfoFromHeader 从 HEAD 响应头中读取对象元数据，metaPrefix 为自定义元数据的请求头前缀
+func newObjectInfoFromHeader(key string, header http.Header, metaPrefix string) *ObjectInfo {
+	size, _ := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	lastModified, _ := http.ParseTime(header.Get("Last-Modified"))
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         size,
+		ContentType:  header.Get("Content-Type"),
+		LastModified: lastModified,
+		ETag:         strings.Trim(header.Get("ETag"), `"`),
+	}
+	for name, values := range header {
+		if len(values) == 0 || len(name) <= len(metaPrefix) || !strings.EqualFold(name[:len(metaPrefix)], metaPrefix) {
+			continue
+		}
+		if info.Metadata == nil {
+			info.Metadata = make(map[string]string)
+		}
+		info.Metadata[strings.ToLower(name[len(metaPrefix):])] = values[0]
+	}
+	return info
+}
+
+// Exists 判断对象是否存在
+func Exists(ctx context.Context, uploader Uploader, key string) (bool, error) {
+	_, err := uploader.Stat(ctx, key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// checkCopyKeys 校验复制和移动的源对象键与目标对象键
+func checkCopyKeys(src, dst string) error {
+	if src == "" || dst == "" {
+		return fmt.Errorf("source and destination keys are required")
+	}
+	if src == dst {
+		return fmt.Errorf("source and destination keys are the same: %s", src)
+	}
+	return nil
+}
+
+// moveObject 对象存储没有重命名操作，先在服务端复制再删除源对象；
+// 删除时源对象已不存在视为移动完成
+func moveObject(ctx context.Context, uploader Uploader, src, dst string) error {
+	if err := uploader.Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	if err := uploader.Delete(ctx, src); err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return fmt.Errorf("object copied to %s but failed to delete source: %w", dst, err)
+	}
+	return nil
+}
+
+// parseContentRange 解析 "bytes start-end/total" 格式的 Content-Range
+func parseContentRange(value string) (int64, int64, bool) {
+	value, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+	span, total, ok := strings.Cut(value, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	start, _, ok := strings.Cut(span, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	offset, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return offset, size, true
+}
+
+// objectError 将存储后端返回的 HTTP 状态码转换为可判断的错误
+func objectError(statusCode int, err error) error {
+	switch statusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %v", ErrObjectNotFound, err)
+	case http.StatusRequestedRangeNotSatisfiable:
+		return fmt.Errorf("%w: %v", ErrInvalidRange, err)
+	}
+	return err
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"upload-util/internal/config"
+)
+
+func TestLocalOpen(t *testing.T) {
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{
+		MaxFileSize:      1,
+		FilenameStrategy: "uuid",
+	})
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+	ctx := context.Background()
+	result, err := uploader.UploadReader(ctx, strings.NewReader("hello world"), "hello.txt", 11)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		opts   []OpenOption
+		want   string
+		offset int64
+	}{
+		{name: "full", want: "hello world"},
+		{name: "range", opts: []OpenOption{WithRange(6, 3)}, want: "wor", offset: 6},
+		{name: "to end", opts: []OpenOption{WithRange(6, 0)}, want: "world", offset: 6},
+		{name: "suffix", opts: []OpenOption{WithRange(-3, 0)}, want: "rld", offset: 8},
+		{name: "length past end", opts: []OpenOption{WithRange(9, 100)}, want: "ld", offset: 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			object, err := uploader.Open(ctx, result.Key, tt.opts...)
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			defer object.Close()
+			data, err := io.ReadAll(object)
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, data)
+			}
+			if object.Size != 11 || object.Offset != tt.offset || object.Length != int64(len(tt.want)) {
+				t.Errorf("unexpected object info: size=%d offset=%d length=%d", object.Size, object.Offset, object.Length)
+			}
+			if object.ContentType != "text/plain; charset=utf-8" || object.ETag == "" {
+				t.Errorf("unexpected metadata: %q %q", object.ContentType, object.ETag)
+			}
+		})
+	}
+
+	t.Run("range not satisfiable", func(t *testing.T) {
+		if _, err := uploader.Open(ctx, result.Key, WithRange(11, 0)); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("expected invalid range, got %v", err)
+		}
+	})
+
+	t.Run("path traversal", func(t *testing.T) {
+		if _, err := uploader.Open(ctx, "../../etc/passwd"); err == nil {
+			t.Error("expected error for path traversal")
+		}
+	})
+}
+
+func TestParseContentRange(t *testing.T) {
+	object := newObjectReader(io.NopCloser(strings.NewReader("")), "a", "", `"abc"`, 100, "bytes 100-199/1000")
+	if object.Offset != 100 || object.Size != 1000 || object.Length != 100 || object.ETag != "abc" {
+		t.Errorf("unexpected object: %+v", object)
+	}
+	if !object.Ranged() {
+		t.Error("expected ranged object")
+	}
+
+	object = newObjectReader(io.NopCloser(strings.NewReader("")), "a", "", "", 100, "")
+	if object.Size != 100 || object.Ranged() {
+		t.Errorf("unexpected object: %+v", object)
+	}
+}
+
+func TestLocalStat(t *testing.T) {
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{
+		MaxFileSize:      1,
+		FilenameStrategy: "uuid",
+	})
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+	ctx := context.Background()
+	result, err := uploader.UploadReader(ctx, strings.NewReader("hello"), "hello.txt", 5)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+
+	info, err := uploader.Stat(ctx, result.Key)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size != 5 || info.ETag == "" || info.LastModified.IsZero() {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	if _, err := uploader.Stat(ctx, "missing.txt"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if _, err := uploader.Open(ctx, "missing.txt"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	exists, err := Exists(ctx, uploader, result.Key)
+	if err != nil || !exists {
+		t.Errorf("expected object to exist, got %v %v", exists, err)
+	}
+	exists, err = Exists(ctx, uploader, "missing.txt")
+	if err != nil || exists {
+		t.Errorf("expected object to be missing, got %v %v", exists, err)
+	}
+}
+
+func TestNewObjectInfoFromHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Length", "42")
+	header.Set("Content-Type", "image/png")
+	header.Set("ETag", `"abc"`)
+	header.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	header.Set("X-Oss-Meta-Author", "tom")
+
+	info := newObjectInfoFromHeader("a.png", header, "X-Oss-Meta-")
+	if info.Size != 42 || info.ContentType != "image/png" || info.ETag != "abc" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if info.LastModified.Year() != 2006 {
+		t.Errorf("unexpected last modified: %v", info.LastModified)
+	}
+	if info.Metadata["author"] != "tom" {
+		t.Errorf("unexpected metadata: %v", info.Metadata)
+	}
+}
+
+func TestObjectError(t *testing.T) {
+	if err := objectError(http.StatusNotFound, errors.New("NoSuchKey")); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if err := objectError(http.StatusForbidden, errors.New("AccessDenied")); errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("unexpected not found for 403")
+	}
+}
+
+func TestLocalCopyMove(t *testing.T) {
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{
+		MaxFileSize:      1,
+		FilenameStrategy: "uuid",
+	})
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+	ctx := context.Background()
+	result, err := uploader.UploadReader(ctx, strings.NewReader("hello"), "hello.txt", 5)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+
+	if err := uploader.Copy(ctx, result.Key, "tmp/copy.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if err := uploader.Move(ctx, "tmp/copy.txt", "avatars/moved.txt"); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if _, err := uploader.Stat(ctx, "tmp/copy.txt"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected source to be removed after move, got %v", err)
+	}
+	for _, key := range []string{result.Key, "avatars/moved.txt"} {
+		info, err := uploader.Stat(ctx, key)
+		if err != nil || info.Size != 5 {
+			t.Errorf("expected %s with size 5, got %+v %v", key, info, err)
+		}
+	}
+
+	if err := uploader.Copy(ctx, "missing.txt", "other.txt"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected not found, got %v", err)
+	}
+	if err := uploader.Move(ctx, result.Key, result.Key); err == nil {
+		t.Error("expected error for same source and destination")
+	}
+	if err := uploader.Move(ctx, result.Key, "../outside.txt"); err == nil {
+		t.Error("expected error for path traversal")
+	}
+}
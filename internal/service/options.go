@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"time"
+	"upload-util/internal/config"
+
+	"golang.org/x/time/rate"
+)
+
+// UploadOptions 单次上传的可选参数
+type UploadOptions struct {
+	ContentType string
+	// User 上传者标识，用于 key-template 中的 {user}
+	User string
+	// Key 指定完整的对象键，不再按文件名策略生成，也不做内容去重
+	Key string
+	// Metadata 对象的自定义元数据，本地存储不保存
+	Metadata map[string]string
+	// BandwidthLimit 本次上传的带宽上限 (字节/秒)
+	BandwidthLimit int64
+	// Progress 接收上传进度，去重跳过时不会调用
+	Progress ProgressFunc
+	// limiters 限速上传器传入的共享带宽令牌桶
+	limiters []*rate.Limiter
+	// verbatim 原样写入已有的对象 (迁移)，不执行上传规则
+	verbatim bool
+}
+
+// UploadOption 设置单次上传的可选参数
+type UploadOption func(*UploadOptions)
+
+// WithContentType 指定内容类型，默认根据文件扩展名推断
+func WithContentType(contentType string) UploadOption {
+	return func(o *UploadOptions) {
+		o.ContentType = contentType
+	}
+}
+
+// WithUser 指定上传者标识，用于 key-template 中的 {user}
+func WithUser(user string) UploadOption {
+	return func(o *UploadOptions) {
+		o.User = user
+	}
+}
+
+// WithKey 指定完整的对象键 (包含 path-prefix)，不再按文件名策略和 key-template 生成，
+// 用于将同一对象写入多个存储
+func WithKey(key string) UploadOption {
+	return func(o *UploadOptions) {
+		o.Key = key
+	}
+}
+
+// WithMetadata 设置对象的自定义元数据，名称不区分大小写
+func WithMetadata(metadata map[string]string) UploadOption {
+	return func(o *UploadOptions) {
+		o.Metadata = metadata
+	}
+}
+
+// WithBandwidthLimit 限制本次上传的带宽 (字节/秒)，与 rate-limit 配置的共享带宽同时生效
+func WithBandwidthLimit(bytesPerSecond int64) UploadOption {
+	return func(o *UploadOptions) {
+		o.BandwidthLimit = bytesPerSecond
+	}
+}
+
+// WithProgress 在上传过程中报告已发送的字节数、总大小和速度，大约每 100ms 调用一次
+func WithProgress(fn ProgressFunc) UploadOption {
+	return func(o *UploadOptions) {
+		o.Progress = fn
+	}
+}
+
+// withVerbatim 原样写入已有的对象，跳过大小、扩展名校验和内容识别，
+// 用于迁移时复制源存储中已经存在的对象，与 WithKey 一起使用
+func withVerbatim() UploadOption {
+	return func(o *UploadOptions) {
+		o.verbatim = true
+	}
+}
+
+// withLimiter 添加共享的带宽令牌桶
+func withLimiter(limiter *rate.Limiter) UploadOption {
+	return func(o *UploadOptions) {
+		o.limiters = append(o.limiters, limiter)
+	}
+}
+
+func newUploadOptions(opts []UploadOption) *UploadOptions {
+	options := &UploadOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// contentType 返回指定的内容类型，未指定时根据文件名推断
+func (o *UploadOptions) contentType(filename string) string {
+	if o.ContentType != "" {
+		return o.ContentType
+	}
+	return getMimeType(filename)
+}
+
+// validate 按上传规则校验文件大小和扩展名，原样写入时不校验
+func (o *UploadOptions) validate(header *multipart.FileHeader, settings *config.UploadSettings) error {
+	if o.verbatim {
+		return nil
+	}
+	return validateFile(header, settings)
+}
+
+// limitSize 大小未知时限制读取的字节数不超过 MaxFileSize，原样写入时不限制
+func (o *UploadOptions) limitSize(r io.Reader, size int64, settings *config.UploadSettings) io.Reader {
+	if o.verbatim {
+		return r
+	}
+	return limitUploadSize(r, size, settings)
+}
+
+// sniff 识别内容的真实类型并校验，原样写入时不识别，返回的类型为空
+func (o *UploadOptions) sniff(r io.Reader, filename string, settings *config.UploadSettings) (io.Reader, string, error) {
+	if o.verbatim {
+		return r, "", nil
+	}
+	return sniffContent(r, filename, settings)
+}
+
+// throttle 按本次上传的带宽上限和共享令牌桶限制 r 的读取速度
+func (o *UploadOptions) throttle(ctx context.Context, r io.Reader) io.Reader {
+	limiters := o.limiters
+	if limiter := newBandwidthLimiter(o.BandwidthLimit); limiter != nil {
+		limiters = append(limiters[:len(limiters):len(limiters)], limiter)
+	}
+	return throttleReader(ctx, r, limiters)
+}
+
+// uploadReader 返回实际上传时读取的数据源，按带宽上限限速并报告进度，total 为 -1 表示大小未知
+func (o *UploadOptions) uploadReader(ctx context.Context, r io.Reader, total int64) io.Reader {
+	return trackProgress(o.throttle(ctx, r), total, o.Progress)
+}
+
+// OpenOptions 读取对象的可选参数
+type OpenOptions struct {
+	// Offset 起始位置，为负数时表示读取最后 -Offset 个字节
+	Offset int64
+	// Length 读取长度，小于等于 0 时读到末尾
+	Length int64
+	ranged bool
+}
+
+// OpenOption 设置读取对象的可选参数
+type OpenOption func(*OpenOptions)
+
+// WithRange 只读取对象的一部分，length 小于等于 0 时读到末尾，
+// offset 为负数时读取最后 -offset 个字节
+func WithRange(offset, length int64) OpenOption {
+	return func(o *OpenOptions) {
+		o.Offset = offset
+		o.Length = length
+		o.ranged = true
+	}
+}
+
+func newOpenOptions(opts []OpenOption) *OpenOptions {
+	options := &OpenOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// rangeHeader 返回 HTTP Range 请求头，未指定范围时返回空字符串
+func (o *OpenOptions) rangeHeader() string {
+	switch {
+	case !o.ranged:
+		return ""
+	case o.Offset < 0:
+		return fmt.Sprintf("bytes=%d", o.Offset)
+	case o.Length > 0:
+		return fmt.Sprintf("bytes=%d-%d", o.Offset, o.Offset+o.Length-1)
+	default:
+		return fmt.Sprintf("bytes=%d-", o.Offset)
+	}
+}
+
+// resolveRange 根据对象大小计算实际读取的起始位置和长度
+func (o *OpenOptions) resolveRange(size int64) (int64, int64, error) {
+	if !o.ranged {
+		return 0, size, nil
+	}
+	offset, length := o.Offset, o.Length
+	if offset < 0 {
+		offset = max(size+offset, 0)
+		length = size - offset
+	}
+	if offset >= size {
+		return 0, 0, ErrInvalidRange
+	}
+	if length <= 0 || offset+length > size {
+		length = size - offset
+	}
+	return offset, length, nil
+}
+
+const (
+	defaultSignURLExpire = 3600 // 秒
+	// maxSignURLExpire 签名链接的最长有效期，S3 签名 V4 最多支持 7 天
+	maxSignURLExpire = 7 * 24 * time.Hour
+)
+
+// URLOptions 获取访问地址的可选参数
+type URLOptions struct {
+	// Expires 签名链接的有效期，未指定时使用配置中的 sign-url-expire
+	Expires time.Duration
+}
+
+// URLOption 设置获取访问地址的可选参数
+type URLOption func(*URLOptions)
+
+// WithExpires 指定签名链接的有效期，仅对私有存储生效
+func WithExpires(expires time.Duration) URLOption {
+	return func(o *URLOptions) {
+		o.Expires = expires
+	}
+}
+
+func newURLOptions(opts []URLOption) *URLOptions {
+	options := &URLOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// expires 返回签名链接的有效期，优先使用单次调用指定的值，
+// 其次使用配置的秒数，均未设置时为 1 小时，最长 7 天
+func (o *URLOptions) expires(configured int64) time.Duration {
+	expires := o.Expires
+	if expires <= 0 {
+		expires = time.Duration(configured) * time.Second
+	}
+	if expires <= 0 {
+		expires = defaultSignURLExpire * time.Second
+	}
+	return min(expires, maxSignURLExpire)
+}
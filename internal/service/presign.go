@@ -0,0 +1,130 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+	"upload-util/internal/config"
+)
+
+const defaultPresignExpire = 15 * 60 // 秒
+
+// PresignRequest 直传签名请求
+type PresignRequest struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size,omitempty"`
+	Method   string `json:"method,omitempty"` // PUT 或 POST，默认 PUT
+}
+
+// PresignResult 客户端直传所需的信息。
+// PUT 方式直接向 URL 发送文件内容并携带 Headers；
+// POST 方式向 URL 提交 multipart 表单，Fields 放在 file 字段之前。
+type PresignResult struct {
+	Key     string            `json:"key"`
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Expires time.Time         `json:"expires"`
+}
+
+// presignParams 各存储后端生成签名时共用的参数
+type presignParams struct {
+	key         string
+	contentType string
+	method      string
+	size        int64
+	maxSize     int64
+	expire      time.Duration
+	expires     time.Time
+}
+
+// preparePresign 校验直传请求并生成对象键，文件大小和扩展名限制在签名前生效，
+// 大小上限同时写入 POST 策略，由存储后端强制校验
+func preparePresign(req *PresignRequest, settings *config.UploadSettings, pathPrefix string) (*presignParams, error) {
+	if req == nil || req.Filename == "" {
+		return nil, fmt.Errorf("filename is required")
+	}
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = http.MethodPut
+	}
+	if method != http.MethodPut && method != http.MethodPost {
+		return nil, fmt.Errorf("unsupported presign method: %s", req.Method)
+	}
+
+	header := &multipart.FileHeader{Filename: req.Filename, Size: req.Size}
+	if err := validateFile(header, settings); err != nil {
+		return nil, fmt.Errorf("file validation failed: %w", err)
+	}
+
+	expire := settings.PresignExpire
+	if expire <= 0 {
+		expire = defaultPresignExpire
+	}
+	filename := generateFileName(header, settings, "", "")
+	return &presignParams{
+		key:         buildObjectKey(filename, pathPrefix),
+		contentType: getMimeType(req.Filename),
+		method:      method,
+		size:        req.Size,
+		maxSize:     settings.MaxFileSize * 1024 * 1024,
+		expire:      time.Duration(expire) * time.Second,
+		expires:     time.Now().Add(time.Duration(expire) * time.Second),
+	}, nil
+}
+
+// result 生成签名结果的公共部分
+func (p *presignParams) result(url string) *PresignResult {
+	result := &PresignResult{
+		Key:     p.key,
+		URL:     url,
+		Method:  p.method,
+		Expires: p.expires,
+	}
+	if p.method == http.MethodPut {
+		result.Headers = map[string]string{"Content-Type": p.contentType}
+	}
+	return result
+}
+
+// postConditions 返回 POST 策略中限制对象键、类型和大小的条件
+func (p *presignParams) postConditions(bucket string) []any {
+	return []any{
+		map[string]string{"bucket": bucket},
+		map[string]string{"key": p.key},
+		map[string]string{"Content-Type": p.contentType},
+		[]any{"content-length-range", 0, p.maxSize},
+	}
+}
+
+// encodePostPolicy 生成 base64 编码的 POST 策略
+func encodePostPolicy(expires time.Time, conditions []any) (string, error) {
+	policy, err := json.Marshal(map[string]any{
+		"expiration": expires.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode post policy: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(policy), nil
+}
+
+func hmacSHA1(key, data []byte) []byte {
+	h := hmac.New(sha1.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"upload-util/internal/config"
+)
+
+func TestLocalPresign(t *testing.T) {
+	settings := &config.UploadSettings{
+		MaxFileSize:       1,
+		AllowedExtensions: []string{".txt"},
+		FilenameStrategy:  "uuid",
+	}
+	uploader, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, settings)
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+	ctx := context.Background()
+
+	t.Run("put with token", func(t *testing.T) {
+		result, err := uploader.Presign(ctx, &PresignRequest{Filename: "hello.txt"})
+		if err != nil {
+			t.Fatalf("Presign failed: %v", err)
+		}
+		if result.Method != http.MethodPut {
+			t.Errorf("expected method PUT, got %s", result.Method)
+		}
+		signURL, err := url.Parse(result.URL)
+		if err != nil {
+			t.Fatalf("parse url failed: %v", err)
+		}
+		uploaded, err := uploader.UploadWithToken(ctx, signURL.Query().Get("token"), strings.NewReader("hello"))
+		if err != nil {
+			t.Fatalf("UploadWithToken failed: %v", err)
+		}
+		if uploaded.Key != result.Key || uploaded.Size != 5 {
+			t.Errorf("unexpected result: %+v", uploaded)
+		}
+	})
+
+	t.Run("tampered token", func(t *testing.T) {
+		result, err := uploader.Presign(ctx, &PresignRequest{Filename: "hello.txt", Method: "post"})
+		if err != nil {
+			t.Fatalf("Presign failed: %v", err)
+		}
+		token := result.Fields["token"] + "x"
+		if _, err := uploader.UploadWithToken(ctx, token, strings.NewReader("hello")); !errors.Is(err, ErrInvalidUploadToken) {
+			t.Errorf("expected invalid token, got %v", err)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		result, err := uploader.Presign(ctx, &PresignRequest{Filename: "hello.txt", Method: "post"})
+		if err != nil {
+			t.Fatalf("Presign failed: %v", err)
+		}
+		data := strings.NewReader(strings.Repeat("a", 1024*1024+1))
+		if _, err := uploader.UploadWithToken(ctx, result.Fields["token"], data); !errors.Is(err, ErrFileTooLarge) {
+			t.Errorf("expected file too large, got %v", err)
+		}
+	})
+
+	t.Run("extension not allowed", func(t *testing.T) {
+		if _, err := uploader.Presign(ctx, &PresignRequest{Filename: "evil.exe"}); err == nil {
+			t.Error("expected error for disallowed extension")
+		}
+	})
+}
+
+// recordingLocal 记录写入本地存储时收到的上传参数
+type recordingLocal struct {
+	*LocalUploader
+	options *UploadOptions
+}
+
+func (r *recordingLocal) UploadReader(ctx context.Context, reader io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	r.options = newUploadOptions(opts)
+	return r.LocalUploader.UploadReader(ctx, reader, name, size, opts...)
+}
+
+func (r *recordingLocal) Unwrap() Uploader {
+	return r.LocalUploader
+}
+
+func TestUploadWithTokenThrottled(t *testing.T) {
+	local, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{MaxFileSize: 1})
+	if err != nil {
+		t.Fatalf("NewLocalUploader failed: %v", err)
+	}
+	recorder := &recordingLocal{LocalUploader: local}
+	u := NewThrottleUploader(recorder, &config.RateLimitConfig{Bandwidth: 1 << 20})
+	ctx := context.Background()
+	presigned, err := u.Presign(ctx, &PresignRequest{Filename: "hello.txt"})
+	if err != nil {
+		t.Fatalf("Presign failed: %v", err)
+	}
+	signURL, err := url.Parse(presigned.URL)
+	if err != nil {
+		t.Fatalf("parse url failed: %v", err)
+	}
+
+	// 直传经过限速上传器，共享带宽对直传同样生效
+	result, err := UploadWithToken(ctx, u, signURL.Query().Get("token"), strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("UploadWithToken failed: %v", err)
+	}
+	if result.Key != presigned.Key || result.Size != 5 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if recorder.options == nil || recorder.options.Key != presigned.Key || len(recorder.options.limiters) != 1 {
+		t.Errorf("expected throttled write to the signed key, got %+v", recorder.options)
+	}
+
+	if _, err := UploadWithToken(ctx, &optionsUploader{}, "token", strings.NewReader("hello")); !errors.Is(err, ErrDirectUploadUnsupported) {
+		t.Errorf("expected ErrDirectUploadUnsupported, got %v", err)
+	}
+}
+
+func TestAliyunPresignPost(t *testing.T) {
+	uploader, err := NewAliyunOSSUploader(&config.AliyunOSSConfig{
+		Endpoint:        "oss-cn-hangzhou.aliyuncs.com",
+		AccessKeyID:     "test-key",
+		AccessKeySecret: "test-secret",
+		Bucket:          "test-bucket",
+		UseSSL:          true,
+	}, &config.UploadSettings{MaxFileSize: 2})
+	if err != nil {
+		t.Fatalf("NewAliyunOSSUploader failed: %v", err)
+	}
+	result, err := uploader.Presign(context.Background(), &PresignRequest{Filename: "a.jpg", Method: "POST"})
+	if err != nil {
+		t.Fatalf("Presign failed: %v", err)
+	}
+	if result.URL != "https://test-bucket.oss-cn-hangzhou.aliyuncs.com" {
+		t.Errorf("unexpected url: %s", result.URL)
+	}
+	policy, err := base64.StdEncoding.DecodeString(result.Fields["policy"])
+	if err != nil {
+		t.Fatalf("decode policy failed: %v", err)
+	}
+	if !strings.Contains(string(policy), `["content-length-range",0,2097152]`) {
+		t.Errorf("policy missing size limit: %s", policy)
+	}
+	if !strings.Contains(string(policy), result.Key) {
+		t.Errorf("policy missing key: %s", policy)
+	}
+}
+
+func TestURLOptionsExpires(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []URLOption
+		configured int64
+		want       time.Duration
+	}{
+		{"default", nil, 0, time.Hour},
+		{"configured", nil, 600, 10 * time.Minute},
+		{"per call", []URLOption{WithExpires(time.Minute)}, 600, time.Minute},
+		{"capped", []URLOption{WithExpires(30 * 24 * time.Hour)}, 0, 7 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newURLOptions(tt.opts).expires(tt.configured); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPrivateGetURL(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("aws", func(t *testing.T) {
+		uploader, err := NewAWSS3Uploader(&config.AWSS3Config{
+			Region:          "us-west-2",
+			AccessKeyID:     "test-key",
+			SecretAccessKey: "test-secret",
+			Bucket:          "test-bucket",
+			Domain:          "https://cdn.example.com",
+			UseSSL:          true,
+			Private:         true,
+			SignURLExpire:   600,
+		}, &config.UploadSettings{})
+		if err != nil {
+			t.Fatalf("NewAWSS3Uploader failed: %v", err)
+		}
+		signURL, err := uploader.GetURL(ctx, "a.jpg")
+		if err != nil {
+			t.Fatalf("GetURL failed: %v", err)
+		}
+		parsed, err := url.Parse(signURL)
+		if err != nil {
+			t.Fatalf("parse url failed: %v", err)
+		}
+		if parsed.Host == "cdn.example.com" || parsed.Query().Get("X-Amz-Signature") == "" {
+			t.Errorf("expected signed url, got %s", signURL)
+		}
+		if got := parsed.Query().Get("X-Amz-Expires"); got != "600" {
+			t.Errorf("expected expires 600, got %s", got)
+		}
+
+		signURL, err = uploader.GetURL(ctx, "a.jpg", WithExpires(time.Minute))
+		if err != nil {
+			t.Fatalf("GetURL failed: %v", err)
+		}
+		parsed, _ = url.Parse(signURL)
+		if got := parsed.Query().Get("X-Amz-Expires"); got != "60" {
+			t.Errorf("expected expires 60, got %s", got)
+		}
+	})
+
+	t.Run("aliyun", func(t *testing.T) {
+		uploader, err := NewAliyunOSSUploader(&config.AliyunOSSConfig{
+			Endpoint:        "oss-cn-hangzhou.aliyuncs.com",
+			AccessKeyID:     "test-key",
+			AccessKeySecret: "test-secret",
+			Bucket:          "test-bucket",
+			Domain:          "https://cdn.example.com",
+			UseSSL:          true,
+			Private:         true,
+		}, &config.UploadSettings{})
+		if err != nil {
+			t.Fatalf("NewAliyunOSSUploader failed: %v", err)
+		}
+		before := time.Now()
+		signURL, err := uploader.GetURL(ctx, "a.jpg", WithExpires(2*time.Minute))
+		if err != nil {
+			t.Fatalf("GetURL failed: %v", err)
+		}
+		parsed, err := url.Parse(signURL)
+		if err != nil {
+			t.Fatalf("parse url failed: %v", err)
+		}
+		expires, err := strconv.ParseInt(parsed.Query().Get("Expires"), 10, 64)
+		if err != nil {
+			t.Fatalf("expected Expires in signed url, got %s", signURL)
+		}
+		if d := expires - before.Unix(); d < 119 || d > 121 {
+			t.Errorf("expected url to expire in 120s, got %ds", d)
+		}
+	})
+
+	t.Run("public", func(t *testing.T) {
+		uploader, err := NewMinIOUploader(&config.MinioConfig{
+			Endpoint:  "localhost:9000",
+			AccessKey: "test-key",
+			SecretKey: "test-secret",
+			Bucket:    "test-bucket",
+		}, &config.UploadSettings{})
+		if err != nil {
+			t.Fatalf("NewMinIOUploader failed: %v", err)
+		}
+		publicURL, err := uploader.GetURL(ctx, "a.jpg", WithExpires(time.Minute))
+		if err != nil {
+			t.Fatalf("GetURL failed: %v", err)
+		}
+		if publicURL != "http://localhost:9000/test-bucket/a.jpg" {
+			t.Errorf("unexpected url: %s", publicURL)
+		}
+	})
+}
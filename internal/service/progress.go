@@ -0,0 +1,93 @@
+package service
+
+import (
+	"io"
+	"time"
+)
+
+// progressInterval 两次进度回调的最小间隔，读取结束时总会回调一次
+const progressInterval = 100 * time.Millisecond
+
+// Progress 上传进度
+type Progress struct {
+	// Transferred 已发送的字节数，重试时从头计算
+	Transferred int64
+	// Total 总字节数，大小未知时为 -1
+	Total int64
+	// Rate 开始上传以来的平均速度 (字节/秒)
+	Rate float64
+	// Elapsed 开始上传以来的时间
+	Elapsed time.Duration
+}
+
+// ETA 按平均速度估算的剩余时间，总大小未知或尚未开始发送时返回 -1
+func (p Progress) ETA() time.Duration {
+	if p.Total < 0 || p.Rate <= 0 {
+		return -1
+	}
+	return time.Duration(float64(max(p.Total-p.Transferred, 0)) / p.Rate * float64(time.Second))
+}
+
+// ProgressFunc 接收上传进度，在上传所在的协程中调用，不应阻塞
+type ProgressFunc func(Progress)
+
+// progressReader 统计上传内容的读取进度，Seek 后从新的位置继续计算
+type progressReader struct {
+	r      io.Reader
+	fn     ProgressFunc
+	total  int64
+	pos    int64
+	start  time.Time
+	notify time.Time
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.pos += int64(n)
+	now := time.Now()
+	if now.Sub(p.notify) >= progressInterval || err == io.EOF || p.pos == p.total {
+		p.notify = now
+		p.report(now)
+	}
+	return n, err
+}
+
+func (p *progressReader) report(now time.Time) {
+	elapsed := now.Sub(p.start)
+	progress := Progress{Transferred: p.pos, Total: p.total, Elapsed: elapsed}
+	if elapsed > 0 {
+		progress.Rate = float64(p.pos) / elapsed.Seconds()
+	}
+	p.fn(progress)
+}
+
+// progressReadSeeker 保留输入的 Seek，使 SDK 仍可按可 Seek 的数据源上传
+type progressReadSeeker struct {
+	*progressReader
+	seeker io.Seeker
+}
+
+func (p *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := p.seeker.Seek(offset, whence)
+	if err == nil {
+		p.pos = pos
+	}
+	return pos, err
+}
+
+// trackProgress 读取 r 时向 fn 报告进度，fn 为 nil 时原样返回
+func trackProgress(r io.Reader, total int64, fn ProgressFunc) io.Reader {
+	if fn == nil {
+		return r
+	}
+	now := time.Now()
+	reader := &progressReader{r: r, fn: fn, total: total, start: now, notify: now}
+	fn(Progress{Total: total})
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		if pos, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			reader.pos = pos
+			return &progressReadSeeker{progressReader: reader, seeker: seeker}
+		}
+	}
+	return reader
+}
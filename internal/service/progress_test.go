@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"upload-util/internal/config"
+)
+
+func TestUploadProgress(t *testing.T) {
+	u, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{MaxFileSize: 10, FilenameStrategy: "uuid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := strings.Repeat("a", 100000)
+	for _, tt := range []struct {
+		name  string
+		r     io.Reader
+		size  int64
+		total int64
+	}{
+		{"Sized", strings.NewReader(content), int64(len(content)), int64(len(content))},
+		{"Stream", io.NopCloser(strings.NewReader(content)), -1, -1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var updates []Progress
+			_, err := u.UploadReader(context.Background(), tt.r, "a.txt", tt.size, WithProgress(func(p Progress) {
+				updates = append(updates, p)
+			}))
+			if err != nil {
+				t.Fatalf("UploadReader failed: %v", err)
+			}
+			if len(updates) < 2 || updates[0].Transferred != 0 {
+				t.Fatalf("expected a start and a final update, got %+v", updates)
+			}
+			last := updates[len(updates)-1]
+			if last.Transferred != int64(len(content)) || last.Total != tt.total {
+				t.Errorf("unexpected final progress: %+v", last)
+			}
+		})
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	p := Progress{Transferred: 100, Total: 300, Rate: 100}
+	if eta := p.ETA(); eta != 2*time.Second {
+		t.Errorf("expected 2s, got %s", eta)
+	}
+	if eta := (Progress{Transferred: 100, Total: -1, Rate: 100}).ETA(); eta != -1 {
+		t.Errorf("expected unknown ETA, got %s", eta)
+	}
+}
@@ -1,12 +1,15 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 	"upload-util/internal/config"
 
 	"github.com/tencentyun/cos-go-sdk-v5"
@@ -51,23 +54,71 @@ func NewQCloudCOSUploader(config *config.QCloudCOSConfig, settings *config.Uploa
 }
 
 func (u *QCloudUploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	return u.UploadReader(ctx, file, header.Filename, header.Size)
+}
+
+// UploadReader 从任意 io.Reader 上传，size 为 -1 时以分片方式流式上传
+func (u *QCloudUploader) UploadReader(ctx context.Context, file io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	options := newUploadOptions(opts)
+	header := &multipart.FileHeader{Filename: name, Size: size}
+
 	// 验证文件
-	if err := validateFile(header, u.settings); err != nil {
+	if err := options.validate(header, u.settings); err != nil {
 		return nil, fmt.Errorf("file validation failed: %w", err)
 	}
+	file = options.limitSize(file, size, u.settings)
+	// 内容寻址策略先计算哈希，由哈希生成对象键
+	content, err := hashContent(file, size, u.settings, options)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	file, size = content.Reader, content.size
+	// 识别内容的真实类型，拒绝与扩展名不符的文件
+	file, sniffed, err := options.sniff(file, name, u.settings)
+	if err != nil {
+		return nil, err
+	}
 
 	// 生成文件名和对象键
-	filename := generateFileName(header, u.settings)
-	objectKey := buildObjectKey(filename, u.config.PathPrefix)
+	objectKey := options.Key
+	if objectKey == "" {
+		objectKey = buildObjectKey(generateFileName(header, u.settings, content.sum, options.User), u.config.PathPrefix)
+	}
+	contentType := options.contentType(name)
+	if result, err := findDuplicate(ctx, u, content, objectKey, contentType, sniffed); result != nil || err != nil {
+		return result, err
+	}
 
-	// 上传文件
-	_, err := u.client.Object.Put(ctx, objectKey, file, &cos.ObjectPutOptions{
-		ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{
-			ContentType: getMimeType(filename),
-		},
-	})
+	// 计算校验和，可 Seek 的输入携带 Content-MD5
+	source, err := newChecksumSource(ctx, file, size, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to upload file to qcloud cos: %w", err)
+		return nil, err
+	}
+
+	// 上传文件，超过阈值时使用分片上传
+	var etag string
+	if shouldUseMultipart(size, u.settings) {
+		etag, err = u.multipartUpload(ctx, objectKey, source, contentType, options.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to multipart upload file to qcloud cos: %w", err)
+		}
+	} else {
+		resp, err := u.client.Object.Put(ctx, objectKey, source.Reader, &cos.ObjectPutOptions{
+			ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{
+				ContentType:   contentType,
+				ContentLength: size,
+				ContentMD5:    source.ContentMD5(),
+				XCosMetaXXX:   cosMetaHeader(options.Metadata),
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload file to qcloud cos: %w", err)
+		}
+		etag = resp.Header.Get("ETag")
+		if err := verifyUpload(ctx, u, objectKey, source, etag, resp.Header.Get("X-Cos-Hash-Crc64ecma")); err != nil {
+			return nil, err
+		}
 	}
 
 	// 生成访问 URL
@@ -77,13 +128,63 @@ func (u *QCloudUploader) Upload(ctx context.Context, file multipart.File, header
 	}
 
 	return &UploadResult{
-		URL:      fileurl,
-		Key:      objectKey,
-		Size:     header.Size,
-		MimeType: getMimeType(filename),
+		URL:         fileurl,
+		Key:         objectKey,
+		Size:        source.Size(),
+		MimeType:    contentType,
+		SniffedType: sniffed,
+		ETag:        strings.Trim(etag, `"`),
+		Checksums:   source.Checksums(),
 	}, nil
 }
 
+// multipartUpload 分片上传，每个分片携带 Content-MD5，返回合并后对象的 ETag
+func (u *QCloudUploader) multipartUpload(ctx context.Context, objectKey string, file io.Reader, contentType string, metadata map[string]string) (string, error) {
+	init, _, err := u.client.Object.InitiateMultipartUpload(ctx, objectKey, &cos.InitiateMultipartUploadOptions{
+		ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{
+			ContentType: contentType,
+			XCosMetaXXX: cosMetaHeader(metadata),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	opt := &cos.CompleteMultipartUploadOptions{}
+	_, err = uploadLimitedParts(ctx, file, partSize(u.settings), func(partNumber int, part *bytes.Reader) error {
+		contentMD5, err := partMD5(part)
+		if err != nil {
+			return err
+		}
+		resp, err := u.client.Object.UploadPart(ctx, objectKey, init.UploadID, partNumber, part, &cos.ObjectUploadPartOptions{
+			ContentLength: part.Size(),
+			ContentMD5:    contentMD5,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+		}
+		opt.Parts = append(opt.Parts, cos.Object{
+			PartNumber: partNumber,
+			ETag:       resp.Header.Get("ETag"),
+		})
+		return nil
+	})
+	var completed *cos.CompleteMultipartUploadResult
+	if err == nil {
+		if completed, _, err = u.client.Object.CompleteMultipartUpload(ctx, objectKey, init.UploadID, opt); err != nil {
+			err = fmt.Errorf("failed to complete multipart upload: %w", err)
+		}
+	}
+	if err != nil {
+		// 中止未完成的分片上传，避免残留分片占用存储空间
+		abortCtx, cancel := abortContext(ctx)
+		defer cancel()
+		_, _ = u.client.Object.AbortMultipartUpload(abortCtx, objectKey, init.UploadID)
+		return "", err
+	}
+	return completed.ETag, nil
+}
+
 func (u *QCloudUploader) Delete(ctx context.Context, key string) error {
 	_, err := u.client.Object.Delete(ctx, key)
 	if err != nil {
@@ -92,7 +193,118 @@ func (u *QCloudUploader) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
-func (u *QCloudUploader) GetURL(ctx context.Context, key string) (string, error) {
+// Open 读取对象内容，可通过 WithRange 只读取一部分
+func (u *QCloudUploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	options := newOpenOptions(opts)
+	resp, err := u.client.Object.Get(ctx, key, &cos.ObjectGetOptions{
+		Range: options.rangeHeader(),
+	})
+	if err != nil {
+		if cosErr, ok := err.(*cos.ErrorResponse); ok && cosErr.Response != nil {
+			err = objectError(cosErr.Response.StatusCode, err)
+		}
+		return nil, fmt.Errorf("failed to get object from qcloud cos: %w", err)
+	}
+	return newObjectReaderFromHeader(resp.Body, key, resp.Header), nil
+}
+
+// Stat 获取对象元数据，对象不存在时返回 ErrObjectNotFound
+func (u *QCloudUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	resp, err := u.client.Object.Head(ctx, key, nil)
+	if err != nil {
+		if cosErr, ok := err.(*cos.ErrorResponse); ok && cosErr.Response != nil {
+			err = objectError(cosErr.Response.StatusCode, err)
+		}
+		return nil, fmt.Errorf("failed to stat object from qcloud cos: %w", err)
+	}
+	return newObjectInfoFromHeader(key, resp.Header, "X-Cos-Meta-"), nil
+}
+
+// List 按前缀列举对象，cursor 为上一页返回的 NextCursor
+func (u *QCloudUploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	output, _, err := u.client.Bucket.Get(ctx, &cos.BucketGetOptions{
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		Marker:    cursor,
+		MaxKeys:   listLimit(limit),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects from qcloud cos: %w", err)
+	}
+	result := &ListResult{
+		Objects:        make([]ObjectInfo, 0, len(output.Contents)),
+		CommonPrefixes: output.CommonPrefixes,
+		Truncated:      output.IsTruncated,
+	}
+	for _, object := range output.Contents {
+		lastModified, _ := time.Parse(time.RFC3339, object.LastModified)
+		result.Objects = append(result.Objects, ObjectInfo{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: lastModified,
+			ETag:         strings.Trim(object.ETag, `"`),
+		})
+	}
+	result.NextCursor = nextCursor(output.IsTruncated, output.NextMarker, result)
+	return result, nil
+}
+
+// Copy 服务端复制对象，数据不经过本服务
+func (u *QCloudUploader) Copy(ctx context.Context, src, dst string) error {
+	if err := checkCopyKeys(src, dst); err != nil {
+		return err
+	}
+	sourceURL := u.client.BaseURL.BucketURL.Host + "/" + src
+	if _, _, err := u.client.Object.Copy(ctx, dst, sourceURL, nil); err != nil {
+		if cosErr, ok := err.(*cos.ErrorResponse); ok && cosErr.Response != nil {
+			err = objectError(cosErr.Response.StatusCode, err)
+		}
+		return fmt.Errorf("failed to copy object in qcloud cos: %w", err)
+	}
+	return nil
+}
+
+// Move 服务端复制后删除源对象
+func (u *QCloudUploader) Move(ctx context.Context, src, dst string) error {
+	return moveObject(ctx, u, src, dst)
+}
+
+// DeleteMany 批量删除对象，每批最多 1000 个
+func (u *QCloudUploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	return deleteBatches(ctx, keys, func(batch []string, result *DeleteResult) error {
+		objects := make([]cos.Object, 0, len(batch))
+		for _, key := range batch {
+			objects = append(objects, cos.Object{Key: key})
+		}
+		output, _, err := u.client.Object.DeleteMulti(ctx, &cos.ObjectDeleteMultiOptions{Objects: objects})
+		if err != nil {
+			return err
+		}
+		for _, object := range output.DeletedObjects {
+			result.Deleted = append(result.Deleted, object.Key)
+		}
+		for _, deleteErr := range output.Errors {
+			result.addError(deleteErr.Key, deleteErr.Code+": "+deleteErr.Message)
+		}
+		return nil
+	})
+}
+
+// DeletePrefix 删除前缀下的所有对象，dryRun 时只列出将要删除的对象
+func (u *QCloudUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	return deletePrefix(ctx, u, prefix, dryRun)
+}
+
+// GetURL 返回对象的访问地址，私有模式下返回带有效期的签名链接
+func (u *QCloudUploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	if u.config.Private {
+		expires := newURLOptions(opts).expires(u.config.SignURLExpire)
+		signURL, err := u.client.Object.GetPresignedURL(ctx, http.MethodGet, key, u.config.AccessKeyID, u.config.SecretAccessKey, expires, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign get url: %w", err)
+		}
+		return signURL.String(), nil
+	}
 	if u.config.Domain != "" {
 		return fmt.Sprintf("%s/%s", strings.TrimRight(u.config.Domain, "/"), key), nil
 	}
@@ -103,3 +315,33 @@ func (u *QCloudUploader) GetURL(ctx context.Context, key string) (string, error)
 	}
 	return fmt.Sprintf("%s://%s/%s", protocol, u.config.Endpoint, key), nil
 }
+
+func (u *QCloudUploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	params, err := preparePresign(req, u.settings, u.config.PathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.method == http.MethodPut {
+		header := &http.Header{}
+		header.Set("Content-Type", params.contentType)
+		signURL, err := u.client.Object.GetPresignedURL(ctx, http.MethodPut, params.key, u.config.AccessKeyID, u.config.SecretAccessKey, params.expire, &cos.PresignedURLOptions{
+			Header: header,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign put url: %w", err)
+		}
+		return params.result(signURL.String()), nil
+	}
+
+	result, err := presignCOSPost(params, u.config.Bucket, u.config.AccessKeyID, u.config.SecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	result.URL = u.client.BaseURL.BucketURL.String()
+	return result, nil
+}
+
+func (u *QCloudUploader) pathPrefix() string {
+	return u.config.PathPrefix
+}
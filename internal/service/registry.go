@@ -0,0 +1,137 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"upload-util/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BackendConfig 传给存储后端构造函数的配置
+type BackendConfig struct {
+	// Name 后端名称，即 upload.type，type 为 oss 时是 oss.provider
+	Name string
+	// Settings 通用上传配置
+	Settings *config.UploadSettings
+
+	section yaml.Node
+	upload  *config.UploadConfig
+	chain   []string
+	// composite 构造函数通过 Profile 引用了其他 profile
+	composite bool
+}
+
+// Decode 将配置文件中 upload.<name> 下的配置解码到后端自己的结构体，未配置时不做修改
+func (c *BackendConfig) Decode(v any) error {
+	if c.section.IsZero() {
+		return nil
+	}
+	if err := c.section.Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s config: %w", c.Name, err)
+	}
+	return nil
+}
+
+// Profile 创建 profiles 下指定名称的上传器，供组合多个后端的上传器 (如 routing) 引用其他 profile，
+// 循环引用时返回错误
+func (c *BackendConfig) Profile(name string) (Uploader, error) {
+	if name == "" {
+		return nil, fmt.Errorf("profile name is required")
+	}
+	c.composite = true
+	if slices.Contains(c.chain, name) {
+		return nil, fmt.Errorf("circular profile reference: %s -> %s", strings.Join(c.chain, " -> "), name)
+	}
+	profile, err := c.upload.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+	uploader, err := createUploader(profile, append(slices.Clone(c.chain), name))
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	return uploader, nil
+}
+
+// ossConfig 返回 upload.oss 下的配置，内置云存储后端从中读取各自的配置
+func (c *BackendConfig) ossConfig() *config.OSSConfig {
+	if c.upload.Upload.OSS == nil {
+		return &config.OSSConfig{}
+	}
+	return c.upload.Upload.OSS
+}
+
+// BackendConstructor 根据配置创建上传器
+type BackendConstructor func(cfg *BackendConfig) (Uploader, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]BackendConstructor)
+)
+
+// RegisterBackend 注册存储后端，通常在后端所在包的 init 中调用。
+// 名称为空、构造函数为 nil 或重复注册时 panic
+func RegisterBackend(name string, constructor BackendConstructor) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if name == "" || constructor == nil {
+		panic("upload: RegisterBackend requires a name and a constructor")
+	}
+	if _, ok := backends[name]; ok {
+		panic("upload: RegisterBackend called twice for backend " + name)
+	}
+	backends[name] = constructor
+}
+
+// Backends 返回已注册的后端名称
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupBackend(name string) (BackendConstructor, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	constructor, ok := backends[name]
+	return constructor, ok
+}
+
+// 内置存储后端，oss 类型按 provider 注册
+func init() {
+	RegisterBackend("local", func(cfg *BackendConfig) (Uploader, error) {
+		uploader, err := NewLocalUploader(cfg.upload.Upload.Local, cfg.Settings)
+		if err != nil {
+			return nil, err
+		}
+		uploader.directPath = localDirectUploadPath(cfg.upload.ProfileName)
+		return uploader, nil
+	})
+	RegisterBackend("minio", func(cfg *BackendConfig) (Uploader, error) {
+		return NewMinIOUploader(cfg.upload.Upload.MinIO, cfg.Settings)
+	})
+	RegisterBackend("aliyun", func(cfg *BackendConfig) (Uploader, error) {
+		return NewAliyunOSSUploader(cfg.ossConfig().Aliyun, cfg.Settings)
+	})
+	RegisterBackend("tencent", func(cfg *BackendConfig) (Uploader, error) {
+		return NewTencentCOSUploader(cfg.ossConfig().Tencent, cfg.Settings)
+	})
+	RegisterBackend("huawei", func(cfg *BackendConfig) (Uploader, error) {
+		return NewHuaweiOBSUploader(cfg.ossConfig().Huawei, cfg.Settings)
+	})
+	RegisterBackend("aws", func(cfg *BackendConfig) (Uploader, error) {
+		return NewAWSS3Uploader(cfg.ossConfig().AWS, cfg.Settings)
+	})
+	RegisterBackend("qcloud", func(cfg *BackendConfig) (Uploader, error) {
+		return NewQCloudCOSUploader(cfg.ossConfig().QCloud, cfg.Settings)
+	})
+}
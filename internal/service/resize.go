@@ -0,0 +1,102 @@
+package service
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// variantSize 计算缩略图的尺寸和从原图中取用的区域。
+// fit 等比缩放到宽高之内且不放大；crop 等比缩放到覆盖宽高后居中裁剪，输出尺寸固定
+func variantSize(bounds image.Rectangle, width, height int, crop bool) (image.Rectangle, int, int) {
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	if crop {
+		scale := max(float64(width)/w, float64(height)/h)
+		cw := min(int(math.Round(float64(width)/scale)), bounds.Dx())
+		ch := min(int(math.Round(float64(height)/scale)), bounds.Dy())
+		x := bounds.Min.X + (bounds.Dx()-cw)/2
+		y := bounds.Min.Y + (bounds.Dy()-ch)/2
+		return image.Rect(x, y, x+cw, y+ch), width, height
+	}
+	scale := 1.0
+	if width > 0 {
+		scale = min(scale, float64(width)/w)
+	}
+	if height > 0 {
+		scale = min(scale, float64(height)/h)
+	}
+	dw := max(int(math.Round(w*scale)), 1)
+	dh := max(int(math.Round(h*scale)), 1)
+	return bounds, dw, dh
+}
+
+// resizeImage 将 src 中 region 区域缩放为 width x height。
+// 缩小时按面积加权平均 (box filter)，放大时取最近的像素
+func resizeImage(src image.Image, region image.Rectangle, width, height int) *image.RGBA {
+	// 先转换为预乘 alpha 的 RGBA，透明像素的颜色不会混入结果
+	rgba := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, region.Min, draw.Src)
+
+	xs := resampleWeights(region.Dx(), width)
+	ys := resampleWeights(region.Dy(), height)
+
+	// 水平方向：每行原图像素缩放为 width 个
+	tmp := make([]float64, region.Dy()*width*4)
+	for y := 0; y < region.Dy(); y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x, weights := range xs {
+			var sum [4]float64
+			for _, c := range weights {
+				p := row[c.index*4 : c.index*4+4]
+				for i := range sum {
+					sum[i] += float64(p[i]) * c.weight
+				}
+			}
+			copy(tmp[(y*width+x)*4:], sum[:])
+		}
+	}
+
+	// 垂直方向
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, weights := range ys {
+		out := dst.Pix[y*dst.Stride:]
+		for x := 0; x < width; x++ {
+			var sum [4]float64
+			for _, c := range weights {
+				p := tmp[(c.index*width+x)*4:]
+				for i := range sum {
+					sum[i] += p[i] * c.weight
+				}
+			}
+			for i, v := range sum {
+				out[x*4+i] = uint8(min(math.Round(v), 255))
+			}
+		}
+	}
+	return dst
+}
+
+type contribution struct {
+	index  int
+	weight float64
+}
+
+// resampleWeights 计算目标的每个像素覆盖原图的哪些像素以及各自所占的比例
+func resampleWeights(srcSize, dstSize int) [][]contribution {
+	scale := float64(srcSize) / float64(dstSize)
+	weights := make([][]contribution, dstSize)
+	for i := range weights {
+		if scale <= 1 {
+			weights[i] = []contribution{{index: min(int((float64(i)+0.5)*scale), srcSize-1), weight: 1}}
+			continue
+		}
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for j := int(start); j < srcSize && float64(j) < end; j++ {
+			overlap := min(float64(j+1), end) - max(float64(j), start)
+			if overlap > 0 {
+				weights[i] = append(weights[i], contribution{index: j, weight: overlap / scale})
+			}
+		}
+	}
+	return weights
+}
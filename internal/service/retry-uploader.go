@@ -0,0 +1,374 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+	"upload-util/internal/config"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
+	"github.com/minio/minio-go/v7"
+	"github.com/tencentyun/cos-go-sdk-v5"
+)
+
+const (
+	defaultRetryAttempts   = 3
+	defaultRetryBackoff    = 200  // 毫秒
+	defaultRetryMaxBackoff = 5000 // 毫秒
+	defaultRetryMultiplier = 2
+	defaultRetryJitter     = 0.5
+	defaultRetryBufferSize = 8 // MB
+)
+
+// retryableCodes 各云存储返回的表示临时性错误的错误码
+var retryableCodes = map[string]bool{
+	"InternalError":       true,
+	"ServiceUnavailable":  true,
+	"SlowDown":            true,
+	"RequestTimeout":      true,
+	"Throttling":          true,
+	"ThrottlingException": true,
+	"RequestError":        true, // aws-sdk-go 的连接错误
+}
+
+// RetryUploader 对临时性错误 (连接重置、超时、5xx、限流) 按指数退避加随机抖动重试的上传器。
+// 上传时可 Seek 的输入回到起始位置重新读取，其他输入在内存中缓冲不超过 buffer-size 的内容用于重试
+type RetryUploader struct {
+	next        Uploader
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	multiplier  float64
+	jitter      float64
+	bufferSize  int64
+	sleep       func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryUploader 创建重试上传器，cfg 中未设置的字段使用默认值
+func NewRetryUploader(next Uploader, cfg *config.RetryConfig) *RetryUploader {
+	u := &RetryUploader{
+		next:        next,
+		maxAttempts: cfg.MaxAttempts,
+		backoff:     time.Duration(cfg.InitialBackoff) * time.Millisecond,
+		maxBackoff:  time.Duration(cfg.MaxBackoff) * time.Millisecond,
+		multiplier:  cfg.Multiplier,
+		jitter:      defaultRetryJitter,
+		bufferSize:  cfg.BufferSize * 1024 * 1024,
+		sleep:       sleepContext,
+	}
+	if u.maxAttempts <= 0 {
+		u.maxAttempts = defaultRetryAttempts
+	}
+	if u.backoff <= 0 {
+		u.backoff = defaultRetryBackoff * time.Millisecond
+	}
+	if u.maxBackoff <= 0 {
+		u.maxBackoff = defaultRetryMaxBackoff * time.Millisecond
+	}
+	if u.multiplier < 1 {
+		u.multiplier = defaultRetryMultiplier
+	}
+	if cfg.Jitter != nil {
+		u.jitter = *cfg.Jitter
+	}
+	if u.bufferSize <= 0 {
+		u.bufferSize = defaultRetryBufferSize * 1024 * 1024
+	}
+	return u
+}
+
+// IsRetryable 判断错误是否为临时性错误，请求本身的错误和已取消或超时的请求不重试
+func IsRetryable(err error) bool {
+	var final *finalError
+	switch {
+	case err == nil, isClientError(err), errors.Is(err, context.DeadlineExceeded), errors.As(err, &final):
+		return false
+	case errors.Is(err, ErrChecksumMismatch), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE):
+		return true
+	}
+	if status, code, ok := serviceError(err); ok {
+		return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError || retryableCodes[code]
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// serviceError 从各云存储 SDK 的错误中取出 HTTP 状态码和错误码
+func serviceError(err error) (int, string, bool) {
+	var ossErr oss.ServiceError
+	if errors.As(err, &ossErr) {
+		return ossErr.StatusCode, ossErr.Code, true
+	}
+	var obsErr obs.ObsError
+	if errors.As(err, &obsErr) {
+		return obsErr.StatusCode, obsErr.Code, true
+	}
+	var cosErr *cos.ErrorResponse
+	if errors.As(err, &cosErr) {
+		if cosErr.Response == nil {
+			return 0, cosErr.Code, true
+		}
+		return cosErr.Response.StatusCode, cosErr.Code, true
+	}
+	var minioErr minio.ErrorResponse
+	if errors.As(err, &minioErr) {
+		return minioErr.StatusCode, minioErr.Code, true
+	}
+	var awsFailure awserr.RequestFailure
+	if errors.As(err, &awsFailure) {
+		return awsFailure.StatusCode(), awsFailure.Code(), true
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return 0, awsErr.Code(), true
+	}
+	return 0, "", false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// delay 第 attempt 次失败后的等待时间，按倍数增长到上限后随机减少 jitter 比例以内的时间
+func (u *RetryUploader) delay(attempt int) time.Duration {
+	d := float64(u.backoff) * math.Pow(u.multiplier, float64(attempt-1))
+	d = math.Min(d, float64(u.maxBackoff))
+	return time.Duration(d * (1 - u.jitter*rand.Float64()))
+}
+
+// retry 执行 fn 直到成功、遇到不可重试的错误或达到最大次数，返回尝试次数。
+// 等待时间超过 ctx 的截止时间时不再重试，直接返回最后一次的错误
+func (u *RetryUploader) retry(ctx context.Context, op, key string, fn func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt >= u.maxAttempts || !IsRetryable(err) || ctx.Err() != nil {
+			return attempt, retryError(err, attempt)
+		}
+		delay := u.delay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return attempt, retryError(err, attempt)
+		}
+		log.Printf("retry: %s %s failed (attempt %d/%d), retrying in %s: %v", op, key, attempt, u.maxAttempts, delay.Round(time.Millisecond), err)
+		if u.sleep(ctx, delay) != nil {
+			return attempt, retryError(err, attempt)
+		}
+	}
+}
+
+// retryError 重试后仍然失败时在错误中注明尝试次数
+func retryError(err error, attempts int) error {
+	if attempts <= 1 {
+		return err
+	}
+	return fmt.Errorf("%w (after %d attempts)", err, attempts)
+}
+
+// finalError 不能再重试的错误，如上传内容已无法重新读取
+type finalError struct {
+	error
+}
+
+func (e *finalError) Unwrap() error {
+	return e.error
+}
+
+// retryReader 重试上传时从头重新读取输入。可 Seek 的输入回到起始位置，
+// 否则在内存中缓冲已读取的内容，超过 limit 后无法再重试
+type retryReader struct {
+	src      io.Reader
+	seeker   io.ReadSeeker
+	start    int64
+	limit    int64
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func newRetryReader(r io.Reader, limit int64) *retryReader {
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			return &retryReader{seeker: seeker, start: start}
+		}
+	}
+	return &retryReader{src: r, limit: limit}
+}
+
+// reader 返回从头读取的 Reader，上一个 Reader 不能再使用
+func (r *retryReader) reader() io.Reader {
+	if r.seeker != nil {
+		return r.seeker
+	}
+	return io.MultiReader(bytes.NewReader(r.buf.Bytes()), &bufferReader{r})
+}
+
+// rewind 准备从头重新读取，已读取的内容超过缓冲区时返回 false
+func (r *retryReader) rewind() bool {
+	if r.seeker != nil {
+		_, err := r.seeker.Seek(r.start, io.SeekStart)
+		return err == nil
+	}
+	return !r.overflow
+}
+
+// bufferReader 读取原输入的同时追加到缓冲区
+type bufferReader struct {
+	r *retryReader
+}
+
+func (b *bufferReader) Read(p []byte) (int, error) {
+	n, err := b.r.src.Read(p)
+	if n > 0 && !b.r.overflow {
+		if int64(b.r.buf.Len()+n) > b.r.limit {
+			b.r.overflow = true
+			b.r.buf = bytes.Buffer{}
+		} else {
+			b.r.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+func (u *RetryUploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	return u.UploadReader(ctx, file, header.Filename, header.Size)
+}
+
+// UploadReader 失败时从头重新读取输入再次上传，结果中记录尝试次数
+func (u *RetryUploader) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	source := newRetryReader(r, u.bufferSize)
+	var result *UploadResult
+	attempts, err := u.retry(ctx, "upload", name, func() (err error) {
+		result, err = u.next.UploadReader(ctx, source.reader(), name, size, opts...)
+		if err != nil && !source.rewind() {
+			return &finalError{err}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Attempts = attempts
+	return result, nil
+}
+
+func (u *RetryUploader) Delete(ctx context.Context, key string) error {
+	_, err := u.retry(ctx, "delete", key, func() error {
+		return u.next.Delete(ctx, key)
+	})
+	return err
+}
+
+func (u *RetryUploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	var result *DeleteResult
+	_, err := u.retry(ctx, "delete", fmt.Sprintf("%d objects", len(keys)), func() (err error) {
+		result, err = u.next.DeleteMany(ctx, keys)
+		return err
+	})
+	return result, err
+}
+
+func (u *RetryUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	var result *DeleteResult
+	_, err := u.retry(ctx, "delete prefix", prefix, func() (err error) {
+		result, err = u.next.DeletePrefix(ctx, prefix, dryRun)
+		return err
+	})
+	return result, err
+}
+
+func (u *RetryUploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	var reader *ObjectReader
+	_, err := u.retry(ctx, "open", key, func() (err error) {
+		reader, err = u.next.Open(ctx, key, opts...)
+		return err
+	})
+	return reader, err
+}
+
+func (u *RetryUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	var info *ObjectInfo
+	_, err := u.retry(ctx, "stat", key, func() (err error) {
+		info, err = u.next.Stat(ctx, key)
+		return err
+	})
+	return info, err
+}
+
+func (u *RetryUploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	var result *ListResult
+	_, err := u.retry(ctx, "list", prefix, func() (err error) {
+		result, err = u.next.List(ctx, prefix, delimiter, cursor, limit)
+		return err
+	})
+	return result, err
+}
+
+func (u *RetryUploader) Copy(ctx context.Context, src, dst string) error {
+	_, err := u.retry(ctx, "copy", src, func() error {
+		return u.next.Copy(ctx, src, dst)
+	})
+	return err
+}
+
+// Move 分别重试复制和删除两步，避免删除成功但响应丢失后整体重试返回源对象不存在
+func (u *RetryUploader) Move(ctx context.Context, src, dst string) error {
+	return moveObject(ctx, u, src, dst)
+}
+
+func (u *RetryUploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	var url string
+	_, err := u.retry(ctx, "get url", key, func() (err error) {
+		url, err = u.next.GetURL(ctx, key, opts...)
+		return err
+	})
+	return url, err
+}
+
+// Presign 只在本地签名，不重试
+func (u *RetryUploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	return u.next.Presign(ctx, req)
+}
+
+// Unwrap 返回被包装的上传器
+func (u *RetryUploader) Unwrap() Uploader {
+	return u.next
+}
+
+// Health 返回被包装上传器的熔断状态
+func (u *RetryUploader) Health() []BackendHealth {
+	return UploaderHealth(u.next)
+}
+
+// Wait 等待被包装上传器的后台任务完成
+func (u *RetryUploader) Wait() {
+	WaitUploader(u.next)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"upload-util/internal/config"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/minio/minio-go/v7"
+)
+
+// transientUploader 前 failures 次上传读取部分内容后返回 err
+type transientUploader struct {
+	Uploader
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *transientUploader) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		_, _ = io.CopyN(io.Discard, r, 4)
+		return nil, fmt.Errorf("failed to upload file: %w", f.err)
+	}
+	return f.Uploader.UploadReader(ctx, r, name, size, opts...)
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func newRetryTestUploader(t *testing.T, failures int, err error, cfg config.RetryConfig) (*RetryUploader, *transientUploader, *[]time.Duration) {
+	t.Helper()
+	local, lerr := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{MaxFileSize: 10, FilenameStrategy: "uuid"})
+	if lerr != nil {
+		t.Fatal(lerr)
+	}
+	flaky := &transientUploader{Uploader: local, failures: failures, err: err}
+	u := NewRetryUploader(flaky, &cfg)
+	var delays []time.Duration
+	u.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return u, flaky, &delays
+}
+
+func TestRetryUpload(t *testing.T) {
+	unavailable := oss.ServiceError{StatusCode: http.StatusServiceUnavailable, Code: "ServiceUnavailable"}
+	u, flaky, delays := newRetryTestUploader(t, 2, unavailable, config.RetryConfig{InitialBackoff: 100, MaxBackoff: 150, Jitter: float64Ptr(0.5)})
+
+	// 不可 Seek 的输入从缓冲区重新读取
+	result, err := u.UploadReader(context.Background(), io.NopCloser(strings.NewReader("hello world")), "a.txt", -1)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if result.Attempts != 3 || flaky.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d (%d calls)", result.Attempts, flaky.calls)
+	}
+	if result.Checksums.MD5 != "5eb63bbbe01eeed093cb22bb8f5acdc3" {
+		t.Errorf("expected intact content, got md5 %s", result.Checksums.MD5)
+	}
+	if len(*delays) != 2 {
+		t.Fatalf("expected 2 backoffs, got %v", *delays)
+	}
+	if d := (*delays)[0]; d < 50*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("first backoff out of range: %s", d)
+	}
+	if d := (*delays)[1]; d < 75*time.Millisecond || d > 150*time.Millisecond {
+		t.Errorf("second backoff should be capped: %s", d)
+	}
+}
+
+func TestRetryJitter(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		jitter *float64
+		want   float64
+	}{
+		{"Default", nil, defaultRetryJitter},
+		{"Disabled", float64Ptr(0), 0},
+		{"Custom", float64Ptr(0.2), 0.2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			u := NewRetryUploader(nil, &config.RetryConfig{InitialBackoff: 100, Jitter: tt.jitter})
+			if u.jitter != tt.want {
+				t.Errorf("expected jitter %v, got %v", tt.want, u.jitter)
+			}
+			if tt.want == 0 && u.delay(1) != 100*time.Millisecond {
+				t.Errorf("expected exact backoff without jitter, got %s", u.delay(1))
+			}
+		})
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	t.Run("MaxAttempts", func(t *testing.T) {
+		u, flaky, _ := newRetryTestUploader(t, 5, syscall.ECONNRESET, config.RetryConfig{MaxAttempts: 2})
+		_, err := u.UploadReader(context.Background(), strings.NewReader("hello"), "a.txt", 5)
+		if !errors.Is(err, syscall.ECONNRESET) || flaky.calls != 2 {
+			t.Errorf("expected ECONNRESET after 2 calls, got %v (%d calls)", err, flaky.calls)
+		}
+	})
+	t.Run("ClientError", func(t *testing.T) {
+		u, flaky, _ := newRetryTestUploader(t, 5, ErrFileTooLarge, config.RetryConfig{})
+		_, err := u.UploadReader(context.Background(), strings.NewReader("hello"), "a.txt", 5)
+		if !errors.Is(err, ErrFileTooLarge) || flaky.calls != 1 {
+			t.Errorf("expected no retry, got %v (%d calls)", err, flaky.calls)
+		}
+	})
+	t.Run("BufferExceeded", func(t *testing.T) {
+		u, flaky, _ := newRetryTestUploader(t, 1, syscall.ECONNRESET, config.RetryConfig{})
+		u.bufferSize = 2
+		_, err := u.UploadReader(context.Background(), io.NopCloser(strings.NewReader("hello")), "a.txt", -1)
+		if !errors.Is(err, syscall.ECONNRESET) || flaky.calls != 1 {
+			t.Errorf("expected no retry once the buffer overflows, got %v (%d calls)", err, flaky.calls)
+		}
+	})
+	t.Run("Deadline", func(t *testing.T) {
+		u, flaky, _ := newRetryTestUploader(t, 1, syscall.ECONNRESET, config.RetryConfig{InitialBackoff: 60000, Jitter: float64Ptr(0.1)})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5)
+		if !errors.Is(err, syscall.ECONNRESET) || flaky.calls != 1 {
+			t.Errorf("expected no retry past the deadline, got %v (%d calls)", err, flaky.calls)
+		}
+	})
+}
+
+// lostDeleteUploader 第一次删除成功但返回连接错误，模拟响应丢失
+type lostDeleteUploader struct {
+	Uploader
+	deletes int
+}
+
+func (f *lostDeleteUploader) Delete(ctx context.Context, key string) error {
+	f.deletes++
+	if f.deletes > 1 {
+		return fmt.Errorf("failed to delete file: %w", ErrObjectNotFound)
+	}
+	if err := f.Uploader.Delete(ctx, key); err != nil {
+		return err
+	}
+	return syscall.ECONNRESET
+}
+
+func TestRetryMoveLostDelete(t *testing.T) {
+	u, flaky, _ := newRetryTestUploader(t, 0, nil, config.RetryConfig{})
+	lost := &lostDeleteUploader{Uploader: flaky.Uploader}
+	u.next = lost
+	ctx := context.Background()
+	if _, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5, WithKey("a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Move(ctx, "a.txt", "b.txt"); err != nil {
+		t.Fatalf("expected move to succeed after lost delete response, got %v", err)
+	}
+	if lost.deletes != 2 {
+		t.Errorf("expected delete to be retried once, got %d calls", lost.deletes)
+	}
+	if _, err := u.Stat(ctx, "b.txt"); err != nil {
+		t.Errorf("expected destination to exist: %v", err)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{oss.ServiceError{StatusCode: http.StatusInternalServerError}, true},
+		{oss.ServiceError{StatusCode: http.StatusForbidden, Code: "AccessDenied"}, false},
+		{minio.ErrorResponse{StatusCode: http.StatusTooManyRequests}, true},
+		{minio.ErrorResponse{StatusCode: http.StatusBadRequest, Code: "RequestTimeout"}, true},
+		{fmt.Errorf("put: %w", syscall.ECONNRESET), true},
+		{io.ErrUnexpectedEOF, true},
+		{ErrChecksumMismatch, true},
+		{ErrObjectNotFound, false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, false},
+		{errors.New("invalid argument"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+	"upload-util/internal/config"
+)
+
+// ErrNoRoute 没有路由规则匹配且未配置默认 profile
+var ErrNoRoute = errors.New("no routing rule matches")
+
+// RoutingUploader 按规则将上传分发到不同 profile 的上传器。
+// 按对象键操作时先匹配 key-prefix 规则，否则依次在各 profile 中查找对象
+type RoutingUploader struct {
+	rules []config.RoutingRule
+	// backends 按规则顺序排列的 profile，默认 profile 在最后
+	backends []string
+	children map[string]Uploader
+	fallback string
+}
+
+type routeInfo struct {
+	name string
+	// key 上传时指定的对象键，由上传器生成时为空
+	key         string
+	size        int64
+	contentType string
+}
+
+func init() {
+	RegisterBackend("routing", func(cfg *BackendConfig) (Uploader, error) {
+		var routing config.RoutingConfig
+		if err := cfg.Decode(&routing); err != nil {
+			return nil, err
+		}
+		return NewRoutingUploader(&routing, cfg.Profile)
+	})
+}
+
+// NewRoutingUploader 创建路由上传器，profile 根据名称创建规则引用的上传器
+func NewRoutingUploader(cfg *config.RoutingConfig, profile func(name string) (Uploader, error)) (*RoutingUploader, error) {
+	if len(cfg.Rules) == 0 && cfg.Default == "" {
+		return nil, fmt.Errorf("routing requires at least one rule or a default profile")
+	}
+	u := &RoutingUploader{
+		rules:    cfg.Rules,
+		children: make(map[string]Uploader),
+		fallback: cfg.Default,
+	}
+	names := make([]string, 0, len(cfg.Rules)+1)
+	for i, rule := range cfg.Rules {
+		if rule.Backend == "" {
+			return nil, fmt.Errorf("routing rule %d: backend is required", i+1)
+		}
+		if rule.MaxSize > 0 && rule.MinSize >= rule.MaxSize {
+			return nil, fmt.Errorf("routing rule %d: min-size must be less than max-size", i+1)
+		}
+		names = append(names, rule.Backend)
+	}
+	if cfg.Default != "" {
+		names = append(names, cfg.Default)
+	}
+	for _, name := range names {
+		if _, ok := u.children[name]; ok {
+			continue
+		}
+		child, err := profile(name)
+		if err != nil {
+			return nil, err
+		}
+		u.children[name] = child
+		u.backends = append(u.backends, name)
+	}
+	return u, nil
+}
+
+// route 返回第一条满足条件的规则对应的 profile
+func (u *RoutingUploader) route(info routeInfo) (string, Uploader, error) {
+	for _, rule := range u.rules {
+		if ruleMatches(rule, info) {
+			return rule.Backend, u.children[rule.Backend], nil
+		}
+	}
+	if u.fallback == "" {
+		return "", nil, fmt.Errorf("%w: %s", ErrNoRoute, info.name)
+	}
+	return u.fallback, u.children[u.fallback], nil
+}
+
+func ruleMatches(rule config.RoutingRule, info routeInfo) bool {
+	if rule.MinSize > 0 || rule.MaxSize > 0 {
+		if info.size < 0 {
+			return false
+		}
+		if rule.MinSize > 0 && info.size < rule.MinSize*1024*1024 {
+			return false
+		}
+		if rule.MaxSize > 0 && info.size >= rule.MaxSize*1024*1024 {
+			return false
+		}
+	}
+	if len(rule.Extensions) > 0 && !isAllowedExtension(filepath.Ext(info.name), rule.Extensions) {
+		return false
+	}
+	if len(rule.MimeTypes) > 0 && !mimeTypeAllowed(info.contentType, rule.MimeTypes) {
+		return false
+	}
+	if rule.NamePrefix != "" && !strings.HasPrefix(info.name, rule.NamePrefix) {
+		return false
+	}
+	// 与按对象键操作时一样匹配对象键，对象键未指定时不匹配
+	if rule.KeyPrefix != "" && !strings.HasPrefix(info.key, rule.KeyPrefix) {
+		return false
+	}
+	return true
+}
+
+// isAllowedExtension 不区分大小写比较扩展名
+func isAllowedExtension(ext string, extensions []string) bool {
+	for _, allowed := range extensions {
+		if strings.EqualFold(ext, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// locate 返回对象所在的 profile，key-prefix 规则匹配时直接使用，
+// 否则依次查找，都不存在时返回默认 profile (没有默认时为第一个)
+func (u *RoutingUploader) locate(ctx context.Context, key string) (string, Uploader, error) {
+	if name, ok := u.prefixBackend(key); ok {
+		return name, u.children[name], nil
+	}
+	for _, name := range u.backends {
+		_, err := u.children[name].Stat(ctx, key)
+		if err == nil {
+			return name, u.children[name], nil
+		}
+		if !errors.Is(err, ErrObjectNotFound) {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	name := u.fallback
+	if name == "" {
+		name = u.backends[0]
+	}
+	return name, u.children[name], nil
+}
+
+// prefixBackend 返回第一条 key-prefix 匹配对象键的规则对应的 profile
+func (u *RoutingUploader) prefixBackend(key string) (string, bool) {
+	for _, rule := range u.rules {
+		if rule.KeyPrefix != "" && strings.HasPrefix(key, rule.KeyPrefix) {
+			return rule.Backend, true
+		}
+	}
+	return "", false
+}
+
+func (u *RoutingUploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	name, child, err := u.route(routeInfo{name: header.Filename, size: header.Size, contentType: getMimeType(header.Filename)})
+	if err != nil {
+		return nil, err
+	}
+	result, err := child.Upload(ctx, file, header)
+	if err != nil {
+		return nil, err
+	}
+	result.Backend = joinBackend(name, result.Backend)
+	return result, nil
+}
+
+func (u *RoutingUploader) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	options := newUploadOptions(opts)
+	backend, child, err := u.route(routeInfo{name: name, key: options.Key, size: size, contentType: options.contentType(name)})
+	if err != nil {
+		return nil, err
+	}
+	result, err := child.UploadReader(ctx, r, name, size, opts...)
+	if err != nil {
+		return nil, err
+	}
+	result.Backend = joinBackend(backend, result.Backend)
+	return result, nil
+}
+
+func (u *RoutingUploader) Delete(ctx context.Context, key string) error {
+	_, child, err := u.locate(ctx, key)
+	if err != nil {
+		return err
+	}
+	return child.Delete(ctx, key)
+}
+
+// DeleteMany 按对象所在的 profile 分组删除
+func (u *RoutingUploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	groups := make(map[string][]string)
+	result := newDeleteResult()
+	for _, key := range keys {
+		name, _, err := u.locate(ctx, key)
+		if err != nil {
+			result.addError(key, err.Error())
+			continue
+		}
+		groups[name] = append(groups[name], key)
+	}
+	for _, name := range u.backends {
+		if len(groups[name]) == 0 {
+			continue
+		}
+		deleted, err := u.children[name].DeleteMany(ctx, groups[name])
+		if deleted != nil {
+			result.merge(deleted)
+		}
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return result, nil
+}
+
+// DeletePrefix 前缀属于某条 key-prefix 规则时只删除对应 profile，否则在所有 profile 中删除
+func (u *RoutingUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	backends := u.backends
+	if name, ok := u.prefixBackend(prefix); ok {
+		backends = []string{name}
+	}
+	result := newDeleteResult()
+	result.DryRun = dryRun
+	for _, name := range backends {
+		deleted, err := u.children[name].DeletePrefix(ctx, prefix, dryRun)
+		if deleted != nil {
+			result.merge(deleted)
+		}
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return result, nil
+}
+
+func (u *RoutingUploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	_, child, err := u.locate(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return child.Open(ctx, key, opts...)
+}
+
+func (u *RoutingUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	_, child, err := u.locate(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return child.Stat(ctx, key)
+}
+
+// List 前缀属于某条 key-prefix 规则时只列举对应 profile，否则依次列举各 profile
+func (u *RoutingUploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	if name, ok := u.prefixBackend(prefix); ok {
+		return u.children[name].List(ctx, prefix, delimiter, cursor, limit)
+	}
+	return listAcross(ctx, u.backends, func(name string) Uploader { return u.children[name] }, prefix, delimiter, cursor, limit)
+}
+
+// Copy 在源对象所在的 profile 内复制
+func (u *RoutingUploader) Copy(ctx context.Context, src, dst string) error {
+	_, child, err := u.locate(ctx, src)
+	if err != nil {
+		return err
+	}
+	return child.Copy(ctx, src, dst)
+}
+
+// Move 在源对象所在的 profile 内移动
+func (u *RoutingUploader) Move(ctx context.Context, src, dst string) error {
+	_, child, err := u.locate(ctx, src)
+	if err != nil {
+		return err
+	}
+	return child.Move(ctx, src, dst)
+}
+
+func (u *RoutingUploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	_, child, err := u.locate(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return child.GetURL(ctx, key, opts...)
+}
+
+// Presign 按文件名和声明的大小选择 profile 签发直传
+func (u *RoutingUploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	size := req.Size
+	if size <= 0 {
+		size = -1
+	}
+	_, child, err := u.route(routeInfo{name: req.Filename, size: size, contentType: getMimeType(req.Filename)})
+	if err != nil {
+		return nil, err
+	}
+	return child.Presign(ctx, req)
+}
+
+// Health 返回各 profile 中的熔断状态
+func (u *RoutingUploader) Health() []BackendHealth {
+	return childHealth(u.backends, func(name string) Uploader { return u.children[name] })
+}
+
+// Wait 等待各 profile 的后台任务完成
+func (u *RoutingUploader) Wait() {
+	for _, name := range u.backends {
+		WaitUploader(u.children[name])
+	}
+}
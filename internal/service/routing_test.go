@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"upload-util/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+func newRoutingTestConfig(t *testing.T, routing config.RoutingConfig) *config.UploadConfig {
+	t.Helper()
+	cfg := &config.UploadConfig{
+		UploadSettings: config.UploadSettings{MaxFileSize: 10, FilenameStrategy: "uuid"},
+		Profiles:       make(map[string]*config.ProfileConfig),
+	}
+	for _, name := range []string{"images", "large", "files"} {
+		cfg.Profiles[name] = &config.ProfileConfig{
+			Upload: config.UploadProvider{Type: "local", Local: &config.LocalConfig{Path: t.TempDir()}},
+		}
+	}
+	// large 的对象键都以 exports/ 开头
+	cfg.Profiles["large"].UploadSettings.KeyTemplate = "exports/{uuid}{ext}"
+	var node yaml.Node
+	if err := node.Encode(routing); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Upload = config.UploadProvider{Type: "routing", Backends: map[string]yaml.Node{"routing": node}}
+	return cfg
+}
+
+func TestRoutingUploader(t *testing.T) {
+	cfg := newRoutingTestConfig(t, config.RoutingConfig{
+		Rules: []config.RoutingRule{
+			{Backend: "large", MinSize: 1},
+			{Backend: "large", KeyPrefix: "exports/"},
+			{Backend: "images", MimeTypes: []string{"image/*"}},
+		},
+		Default: "files",
+	})
+	uploader, err := NewUploadFactory(cfg).CreateUploader()
+	if err != nil {
+		t.Fatalf("CreateUploader failed: %v", err)
+	}
+	ctx := context.Background()
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("x", 16)
+
+	tests := []struct {
+		name    string
+		content string
+		backend string
+	}{
+		{"a.png", png, "images"},
+		{"a.txt", "hello", "files"},
+		{"big.txt", strings.Repeat("x", 1024*1024), "large"},
+	}
+	keys := make(map[string]string)
+	for _, tt := range tests {
+		result, err := uploader.UploadReader(ctx, strings.NewReader(tt.content), tt.name, int64(len(tt.content)))
+		if err != nil {
+			t.Fatalf("UploadReader %s failed: %v", tt.name, err)
+		}
+		if result.Backend != tt.backend {
+			t.Errorf("%s: expected backend %s, got %s", tt.name, tt.backend, result.Backend)
+		}
+		keys[tt.backend] = result.Key
+	}
+
+	// 按对象键操作时在对应的 profile 中查找
+	for backend, key := range keys {
+		info, err := uploader.Stat(ctx, key)
+		if err != nil {
+			t.Fatalf("Stat %s in %s failed: %v", key, backend, err)
+		}
+		if backend == "large" && info.Size != 1024*1024 {
+			t.Errorf("unexpected size %d", info.Size)
+		}
+	}
+	if err := uploader.Delete(ctx, keys["images"]); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := uploader.Stat(ctx, keys["images"]); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected ErrObjectNotFound, got %v", err)
+	}
+
+	// 列举依次返回各 profile 的结果
+	var listed []string
+	cursor := ""
+	for {
+		page, err := uploader.List(ctx, "", "", cursor, 100)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		for _, object := range page.Objects {
+			listed = append(listed, object.Key)
+		}
+		if !page.Truncated {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(listed) != 2 {
+		t.Errorf("expected 2 objects, got %v", listed)
+	}
+}
+
+func TestRoutingPrefix(t *testing.T) {
+	cfg := newRoutingTestConfig(t, config.RoutingConfig{
+		Rules: []config.RoutingRule{
+			{Backend: "large", KeyPrefix: "exports/"},
+			{Backend: "images", NamePrefix: "avatars/"},
+		},
+		Default: "files",
+	})
+	uploader, err := NewUploadFactory(cfg).CreateUploader()
+	if err != nil {
+		t.Fatalf("CreateUploader failed: %v", err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		opts    []UploadOption
+		backend string
+	}{
+		// key-prefix 只匹配对象键，文件名相同但对象键由上传器生成时不匹配
+		{"exports/a.txt", nil, "files"},
+		{"a.txt", []UploadOption{WithKey("exports/a.txt")}, "large"},
+		{"avatars/a.txt", nil, "images"},
+	}
+	for _, tt := range tests {
+		result, err := uploader.UploadReader(ctx, strings.NewReader("hello"), tt.name, 5, tt.opts...)
+		if err != nil {
+			t.Fatalf("UploadReader %s failed: %v", tt.name, err)
+		}
+		if result.Backend != tt.backend {
+			t.Errorf("%s: expected backend %s, got %s", tt.name, tt.backend, result.Backend)
+		}
+		// 上传后按同样的规则找到对象
+		if _, err := uploader.Stat(ctx, result.Key); err != nil {
+			t.Errorf("Stat %s failed: %v", result.Key, err)
+		}
+	}
+}
+
+func TestRoutingNoRoute(t *testing.T) {
+	cfg := newRoutingTestConfig(t, config.RoutingConfig{
+		Rules: []config.RoutingRule{{Backend: "images", Extensions: []string{".png"}}},
+	})
+	uploader, err := NewUploadFactory(cfg).CreateUploader()
+	if err != nil {
+		t.Fatalf("CreateUploader failed: %v", err)
+	}
+	if _, err := uploader.UploadReader(context.Background(), strings.NewReader("hello"), "a.txt", 5); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected ErrNoRoute, got %v", err)
+	}
+}
+
+func TestRoutingCircularProfile(t *testing.T) {
+	cfg := newRoutingTestConfig(t, config.RoutingConfig{Default: "loop"})
+	cfg.Profiles["loop"] = &config.ProfileConfig{Upload: cfg.Upload}
+	if _, err := NewUploadFactory(cfg).CreateUploader(); err == nil || !strings.Contains(err.Error(), "circular") {
+		t.Errorf("expected circular reference error, got %v", err)
+	}
+}
@@ -1,13 +1,21 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 	"upload-util/internal/config"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -56,24 +64,73 @@ func NewAWSS3Uploader(config *config.AWSS3Config, settings *config.UploadSetting
 }
 
 func (u *AWSS3Uploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	return u.UploadReader(ctx, file, header.Filename, header.Size)
+}
+
+// UploadReader 从任意 io.Reader 上传，size 为 -1 时以分片方式流式上传
+func (u *AWSS3Uploader) UploadReader(ctx context.Context, file io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	options := newUploadOptions(opts)
+	header := &multipart.FileHeader{Filename: name, Size: size}
+
 	// 验证文件
-	if err := validateFile(header, u.settings); err != nil {
+	if err := options.validate(header, u.settings); err != nil {
 		return nil, fmt.Errorf("file validation failed: %w", err)
 	}
+	file = options.limitSize(file, size, u.settings)
+	// 内容寻址策略先计算哈希，由哈希生成对象键
+	content, err := hashContent(file, size, u.settings, options)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	file, size = content.Reader, content.size
+	// 识别内容的真实类型，拒绝与扩展名不符的文件
+	file, sniffed, err := options.sniff(file, name, u.settings)
+	if err != nil {
+		return nil, err
+	}
 
 	// 生成文件名和对象键
-	filename := generateFileName(header, u.settings)
-	objectKey := buildObjectKey(filename, u.config.PathPrefix)
+	objectKey := options.Key
+	if objectKey == "" {
+		objectKey = buildObjectKey(generateFileName(header, u.settings, content.sum, options.User), u.config.PathPrefix)
+	}
+	contentType := options.contentType(name)
+	if result, err := findDuplicate(ctx, u, content, objectKey, contentType, sniffed); result != nil || err != nil {
+		return result, err
+	}
 
-	// 上传文件
-	_, err := u.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(u.config.Bucket),
-		Key:         aws.String(objectKey),
-		Body:        file,
-		ContentType: aws.String(getMimeType(filename)),
-	})
+	// 计算校验和，可 Seek 的输入携带 Content-MD5
+	source, err := newChecksumSource(ctx, file, size, options)
 	if err != nil {
-		return nil, fmt.Errorf("failed to upload file to aws s3: %w", err)
+		return nil, err
+	}
+
+	// 上传文件，超过阈值时使用分片上传；PutObject 需要可 Seek 的数据源，
+	// 不可 Seek 的流同样走分片上传，按分片缓冲
+	var etag string
+	body, seekable := source.Reader.(io.ReadSeeker)
+	if shouldUseMultipart(size, u.settings) || !seekable {
+		etag, err = u.multipartUpload(ctx, objectKey, source, contentType, options.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to multipart upload file to aws s3: %w", err)
+		}
+	} else {
+		output, err := u.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket:      aws.String(u.config.Bucket),
+			Key:         aws.String(objectKey),
+			Body:        body,
+			ContentType: aws.String(contentType),
+			ContentMD5:  aws.String(source.ContentMD5()),
+			Metadata:    aws.StringMap(options.Metadata),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload file to aws s3: %w", err)
+		}
+		etag = aws.StringValue(output.ETag)
+		if err := verifyUpload(ctx, u, objectKey, source, etag, ""); err != nil {
+			return nil, err
+		}
 	}
 
 	// 生成访问 URL
@@ -83,13 +140,81 @@ func (u *AWSS3Uploader) Upload(ctx context.Context, file multipart.File, header
 	}
 
 	return &UploadResult{
-		URL:      url,
-		Key:      objectKey,
-		Size:     header.Size,
-		MimeType: getMimeType(filename),
+		URL:         url,
+		Key:         objectKey,
+		Size:        source.Size(),
+		MimeType:    contentType,
+		SniffedType: sniffed,
+		ETag:        strings.Trim(etag, `"`),
+		Checksums:   source.Checksums(),
 	}, nil
 }
 
+// multipartUpload 分片上传，每个分片携带 Content-MD5，返回合并后对象的 ETag
+func (u *AWSS3Uploader) multipartUpload(ctx context.Context, objectKey string, file io.Reader, contentType string, metadata map[string]string) (string, error) {
+	init, err := u.client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(u.config.Bucket),
+		Key:         aws.String(objectKey),
+		ContentType: aws.String(contentType),
+		Metadata:    aws.StringMap(metadata),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate multipart upload: %w", err)
+	}
+
+	var parts []*s3.CompletedPart
+	_, err = uploadLimitedParts(ctx, file, partSize(u.settings), func(partNumber int, part *bytes.Reader) error {
+		contentMD5, err := partMD5(part)
+		if err != nil {
+			return err
+		}
+		output, err := u.client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(u.config.Bucket),
+			Key:           aws.String(objectKey),
+			UploadId:      init.UploadId,
+			PartNumber:    aws.Int64(int64(partNumber)),
+			ContentLength: aws.Int64(part.Size()),
+			ContentMD5:    aws.String(contentMD5),
+			Body:          part,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+		}
+		parts = append(parts, &s3.CompletedPart{
+			ETag:       output.ETag,
+			PartNumber: aws.Int64(int64(partNumber)),
+		})
+		return nil
+	})
+	var etag string
+	if err == nil {
+		var output *s3.CompleteMultipartUploadOutput
+		output, err = u.client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(u.config.Bucket),
+			Key:             aws.String(objectKey),
+			UploadId:        init.UploadId,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to complete multipart upload: %w", err)
+		} else {
+			etag = aws.StringValue(output.ETag)
+		}
+	}
+	if err != nil {
+		// 中止未完成的分片上传，避免残留分片占用存储空间
+		abortCtx, cancel := abortContext(ctx)
+		defer cancel()
+		_, _ = u.client.AbortMultipartUploadWithContext(abortCtx, &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(u.config.Bucket),
+			Key:      aws.String(objectKey),
+			UploadId: init.UploadId,
+		})
+		return "", err
+	}
+	return etag, nil
+}
+
 func (u *AWSS3Uploader) Delete(ctx context.Context, key string) error {
 	_, err := u.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(u.config.Bucket),
@@ -101,7 +226,164 @@ func (u *AWSS3Uploader) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
-func (u *AWSS3Uploader) GetURL(ctx context.Context, key string) (string, error) {
+// Open 读取对象内容，可通过 WithRange 只读取一部分
+func (u *AWSS3Uploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	options := newOpenOptions(opts)
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(u.config.Bucket),
+		Key:    aws.String(key),
+	}
+	if rangeHeader := options.rangeHeader(); rangeHeader != "" {
+		input.Range = aws.String(rangeHeader)
+	}
+	output, err := u.client.GetObjectWithContext(ctx, input)
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok {
+			err = objectError(reqErr.StatusCode(), err)
+		}
+		return nil, fmt.Errorf("failed to get object from aws s3: %w", err)
+	}
+	return newObjectReader(
+		output.Body,
+		key,
+		aws.StringValue(output.ContentType),
+		aws.StringValue(output.ETag),
+		aws.Int64Value(output.ContentLength),
+		aws.StringValue(output.ContentRange),
+	), nil
+}
+
+// Stat 获取对象元数据，对象不存在时返回 ErrObjectNotFound
+func (u *AWSS3Uploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	output, err := u.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(u.config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok {
+			err = objectError(reqErr.StatusCode(), err)
+		}
+		return nil, fmt.Errorf("failed to stat object from aws s3: %w", err)
+	}
+	info := &ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(output.ContentLength),
+		ContentType:  aws.StringValue(output.ContentType),
+		LastModified: aws.TimeValue(output.LastModified),
+		ETag:         strings.Trim(aws.StringValue(output.ETag), `"`),
+	}
+	if len(output.Metadata) > 0 {
+		info.Metadata = make(map[string]string, len(output.Metadata))
+		for name, value := range output.Metadata {
+			info.Metadata[strings.ToLower(name)] = aws.StringValue(value)
+		}
+	}
+	return info, nil
+}
+
+// List 按前缀列举对象，cursor 为上一页返回的 NextCursor (ListObjectsV2 的 ContinuationToken)
+func (u *AWSS3Uploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(u.config.Bucket),
+		Prefix:  aws.String(prefix),
+		MaxKeys: aws.Int64(int64(listLimit(limit))),
+	}
+	if delimiter != "" {
+		input.Delimiter = aws.String(delimiter)
+	}
+	if cursor != "" {
+		input.ContinuationToken = aws.String(cursor)
+	}
+	output, err := u.client.ListObjectsV2WithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects from aws s3: %w", err)
+	}
+	result := &ListResult{
+		Objects:    make([]ObjectInfo, 0, len(output.Contents)),
+		Truncated:  aws.BoolValue(output.IsTruncated),
+		NextCursor: aws.StringValue(output.NextContinuationToken),
+	}
+	for _, object := range output.Contents {
+		result.Objects = append(result.Objects, ObjectInfo{
+			Key:          aws.StringValue(object.Key),
+			Size:         aws.Int64Value(object.Size),
+			LastModified: aws.TimeValue(object.LastModified),
+			ETag:         strings.Trim(aws.StringValue(object.ETag), `"`),
+		})
+	}
+	for _, commonPrefix := range output.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, aws.StringValue(commonPrefix.Prefix))
+	}
+	return result, nil
+}
+
+// Copy 服务端复制对象，数据不经过本服务
+func (u *AWSS3Uploader) Copy(ctx context.Context, src, dst string) error {
+	if err := checkCopyKeys(src, dst); err != nil {
+		return err
+	}
+	_, err := u.client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(u.config.Bucket),
+		Key:        aws.String(dst),
+		CopySource: aws.String(url.PathEscape(u.config.Bucket + "/" + src)),
+	})
+	if err != nil {
+		if reqErr, ok := err.(awserr.RequestFailure); ok {
+			err = objectError(reqErr.StatusCode(), err)
+		}
+		return fmt.Errorf("failed to copy object in aws s3: %w", err)
+	}
+	return nil
+}
+
+// Move 服务端复制后删除源对象
+func (u *AWSS3Uploader) Move(ctx context.Context, src, dst string) error {
+	return moveObject(ctx, u, src, dst)
+}
+
+// DeleteMany 批量删除对象，每批最多 1000 个
+func (u *AWSS3Uploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	return deleteBatches(ctx, keys, func(batch []string, result *DeleteResult) error {
+		objects := make([]*s3.ObjectIdentifier, 0, len(batch))
+		for _, key := range batch {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+		output, err := u.client.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(u.config.Bucket),
+			Delete: &s3.Delete{Objects: objects},
+		})
+		if err != nil {
+			return err
+		}
+		for _, deleted := range output.Deleted {
+			result.Deleted = append(result.Deleted, aws.StringValue(deleted.Key))
+		}
+		for _, deleteErr := range output.Errors {
+			result.addError(aws.StringValue(deleteErr.Key), aws.StringValue(deleteErr.Code)+": "+aws.StringValue(deleteErr.Message))
+		}
+		return nil
+	})
+}
+
+// DeletePrefix 删除前缀下的所有对象，dryRun 时只列出将要删除的对象
+func (u *AWSS3Uploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	return deletePrefix(ctx, u, prefix, dryRun)
+}
+
+// GetURL 返回对象的访问地址，私有模式下返回带有效期的签名链接
+func (u *AWSS3Uploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	if u.config.Private {
+		expires := newURLOptions(opts).expires(u.config.SignURLExpire)
+		req, _ := u.client.GetObjectRequest(&s3.GetObjectInput{
+			Bucket: aws.String(u.config.Bucket),
+			Key:    aws.String(key),
+		})
+		signURL, err := req.Presign(expires)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign get url: %w", err)
+		}
+		return signURL, nil
+	}
 	if u.config.Domain != "" {
 		return fmt.Sprintf("%s/%s", strings.TrimRight(u.config.Domain, "/"), key), nil
 	}
@@ -117,3 +399,78 @@ func (u *AWSS3Uploader) GetURL(ctx context.Context, key string) (string, error)
 
 	return fmt.Sprintf("%s://%s.s3.%s.amazonaws.com/%s", protocol, u.config.Bucket, u.config.Region, key), nil
 }
+
+func (u *AWSS3Uploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	params, err := preparePresign(req, u.settings, u.config.PathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.method == http.MethodPut {
+		input := &s3.PutObjectInput{
+			Bucket:      aws.String(u.config.Bucket),
+			Key:         aws.String(params.key),
+			ContentType: aws.String(params.contentType),
+		}
+		// 已知大小时将 Content-Length 签入 URL，防止上传超出声明大小的内容
+		if params.size > 0 {
+			input.ContentLength = aws.Int64(params.size)
+		}
+		request, _ := u.client.PutObjectRequest(input)
+		signURL, err := request.Presign(params.expire)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign put url: %w", err)
+		}
+		result := params.result(signURL)
+		if params.size > 0 {
+			result.Headers["Content-Length"] = strconv.FormatInt(params.size, 10)
+		}
+		return result, nil
+	}
+
+	// AWS Signature V4 POST 策略
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	amzDate := now.Format("20060102T150405Z")
+	credential := fmt.Sprintf("%s/%s/%s/s3/aws4_request", u.config.AccessKeyID, date, u.config.Region)
+	conditions := append(params.postConditions(u.config.Bucket),
+		map[string]string{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+		map[string]string{"x-amz-credential": credential},
+		map[string]string{"x-amz-date": amzDate},
+	)
+	policy, err := encodePostPolicy(params.expires, conditions)
+	if err != nil {
+		return nil, err
+	}
+	signingKey := hmacSHA256([]byte("AWS4"+u.config.SecretAccessKey), []byte(date))
+	signingKey = hmacSHA256(signingKey, []byte(u.config.Region))
+	signingKey = hmacSHA256(signingKey, []byte("s3"))
+	signingKey = hmacSHA256(signingKey, []byte("aws4_request"))
+
+	result := params.result(u.bucketURL())
+	result.Fields = map[string]string{
+		"key":              params.key,
+		"Content-Type":     params.contentType,
+		"policy":           policy,
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": credential,
+		"x-amz-date":       amzDate,
+		"x-amz-signature":  hex.EncodeToString(hmacSHA256(signingKey, []byte(policy))),
+	}
+	return result, nil
+}
+
+func (u *AWSS3Uploader) bucketURL() string {
+	protocol := "https"
+	if !u.config.UseSSL {
+		protocol = "http"
+	}
+	if u.config.Endpoint != "" {
+		return fmt.Sprintf("%s://%s/%s", protocol, u.config.Endpoint, u.config.Bucket)
+	}
+	return fmt.Sprintf("%s://%s.s3.%s.amazonaws.com", protocol, u.config.Bucket, u.config.Region)
+}
+
+func (u *AWSS3Uploader) pathPrefix() string {
+	return u.config.PathPrefix
+}
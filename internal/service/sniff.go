@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"upload-util/internal/config"
+)
+
+// sniffLength http.DetectContentType 最多检查的字节数
+const sniffLength = 512
+
+var (
+	ErrContentTypeMismatch = errors.New("file content does not match its extension")
+	ErrMimeTypeNotAllowed  = errors.New("file content type is not allowed")
+)
+
+// peMimeType Windows 可执行文件 (PE) 的类型，识别方式见 isPortableExecutable
+const peMimeType = "application/x-msdownload"
+
+// executableSignatures http.DetectContentType 不识别的可执行文件格式
+var executableSignatures = []struct {
+	magic    []byte
+	mimeType string
+}{
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("\xfe\xed\xfa\xce"), "application/x-mach-binary"},
+	{[]byte("\xfe\xed\xfa\xcf"), "application/x-mach-binary"},
+	{[]byte("\xce\xfa\xed\xfe"), "application/x-mach-binary"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+}
+
+// executableExtensions 可执行文件只能使用的扩展名，空字符串表示没有扩展名。
+// 不使用系统的 mime 表判断，不同系统中 .exe 等扩展名对应的类型不同
+var executableExtensions = map[string][]string{
+	peMimeType:                  {".exe", ".dll", ".sys"},
+	"application/x-executable":  {"", ".so", ".o", ".elf"},
+	"application/x-mach-binary": {"", ".dylib", ".bundle", ".o"},
+}
+
+// zipExtensions 基于 zip 容器的格式，内容识别为 application/zip
+var zipExtensions = map[string]bool{
+	".docx": true, ".xlsx": true, ".pptx": true,
+	".odt": true, ".ods": true, ".odp": true,
+	".jar": true, ".apk": true, ".epub": true,
+}
+
+// compatibleTypes 同一种格式的不同类型名称，识别结果为键时扩展名也可以对应值中的类型
+var compatibleTypes = map[string][]string{
+	"video/mp4":          {"audio/mp4", "audio/x-m4a", "video/x-m4v", "video/quicktime", "video/3gpp"},
+	"audio/wave":         {"audio/wav", "audio/x-wav"},
+	"audio/mpeg":         {"audio/mp3"},
+	"audio/aiff":         {"audio/x-aiff"},
+	"video/avi":          {"video/x-msvideo"},
+	"image/x-icon":       {"image/vnd.microsoft.icon"},
+	"application/ogg":    {"audio/ogg", "video/ogg"},
+	"application/x-gzip": {"application/gzip"},
+}
+
+// detectableTypes http.DetectContentType 能够通过特征识别的类型，
+// 扩展名为这些类型而内容无法识别时说明与扩展名不符
+var detectableTypes = map[string]bool{
+	"image/jpeg": true, "image/png": true, "image/gif": true, "image/webp": true, "image/bmp": true,
+	"image/x-icon": true, "image/vnd.microsoft.icon": true,
+	"audio/mpeg": true, "audio/wave": true, "audio/wav": true, "audio/x-wav": true, "audio/aiff": true,
+	"audio/midi": true, "audio/basic": true, "video/mp4": true, "video/webm": true, "video/avi": true,
+	"application/pdf": true, "application/postscript": true, "application/zip": true,
+	"application/x-gzip": true, "application/gzip": true, "application/x-rar-compressed": true,
+	"application/wasm": true, "font/ttf": true, "font/otf": true, "font/woff": true, "font/woff2": true,
+}
+
+// detectContentType 根据内容的前 512 字节识别类型，只返回不含参数的媒体类型
+func detectContentType(head []byte) string {
+	if isPortableExecutable(head) {
+		return peMimeType
+	}
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(head, sig.magic) {
+			return sig.mimeType
+		}
+	}
+	return mediaType(http.DetectContentType(head))
+}
+
+// isPortableExecutable 判断内容是否为 PE 文件。只有 MZ 开头的文本也很常见，
+// 因此还要求 0x3C 处的 e_lfanew 指向 PE\0\0 签名，签名不在前 512 字节内时无法识别
+func isPortableExecutable(head []byte) bool {
+	if len(head) < 0x40 || !bytes.HasPrefix(head, []byte("MZ")) {
+		return false
+	}
+	offset := int64(binary.LittleEndian.Uint32(head[0x3c:]))
+	return offset+4 <= int64(len(head)) && bytes.Equal(head[offset:offset+4], []byte("PE\x00\x00"))
+}
+
+// sniffContent 读取内容的前 512 字节识别真实类型，校验是否与扩展名一致以及是否在 allowed-mime-types 中。
+// 返回的 Reader 仍从头读取全部内容
+func sniffContent(r io.Reader, filename string, settings *config.UploadSettings) (io.Reader, string, error) {
+	r, head, err := readHead(r)
+	if err != nil {
+		return nil, "", err
+	}
+	sniffed := detectContentType(head)
+	if !contentMatchesExtension(sniffed, filename) {
+		return nil, "", fmt.Errorf("%w: %s is %s", ErrContentTypeMismatch, filepath.Base(filename), sniffed)
+	}
+	if !mimeTypeAllowed(sniffed, settings.AllowedMimeTypes) {
+		return nil, "", fmt.Errorf("%w: %s", ErrMimeTypeNotAllowed, sniffed)
+	}
+	return r, sniffed, nil
+}
+
+// readHead 读取前 512 字节，可 Seek 的输入读取后回到原位置，
+// 否则返回先输出已读取部分的 Reader
+func readHead(r io.Reader) (io.Reader, []byte, error) {
+	head := make([]byte, sniffLength)
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			n, err := io.ReadFull(seeker, head)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return nil, nil, fmt.Errorf("failed to read content: %w", err)
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, nil, fmt.Errorf("failed to rewind content: %w", err)
+			}
+			return seeker, head[:n], nil
+		}
+	}
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, fmt.Errorf("failed to read content: %w", err)
+	}
+	return io.MultiReader(bytes.NewReader(head[:n]), r), head[:n], nil
+}
+
+// contentMatchesExtension 判断识别出的类型是否与扩展名对应的类型一致。
+// 扩展名未知、或者内容没有可识别的特征 (如 .doc、.csv) 时无法判断，视为一致
+func contentMatchesExtension(sniffed, filename string) bool {
+	// 可执行文件只能使用对应的扩展名，扩展名未知时同样拒绝
+	if extensions, ok := executableExtensions[sniffed]; ok {
+		return slices.Contains(extensions, strings.ToLower(filepath.Ext(filename)))
+	}
+	expected := mediaType(getMimeType(filename))
+	switch {
+	case sniffed == expected, expected == "application/octet-stream":
+		return true
+	case zipExtensions[strings.ToLower(filepath.Ext(filename))]:
+		return sniffed == "application/zip"
+	case sniffed == "text/plain", sniffed == "text/xml":
+		return isTextual(expected)
+	case sniffed == "application/octet-stream":
+		return !detectableTypes[expected]
+	}
+	for _, compatible := range compatibleTypes[sniffed] {
+		if compatible == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// mimeTypeAllowed 判断类型是否在允许列表中，支持 image/* 形式的通配，列表为空时不限制
+func mimeTypeAllowed(mimeType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mimeType = mediaType(mimeType)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "*/*" || pattern == mimeType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok && strings.HasPrefix(mimeType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaType 去掉 charset 等参数并转为小写
+func mediaType(contentType string) string {
+	if parsed, _, err := mime.ParseMediaType(contentType); err == nil {
+		return parsed
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// isTextual 内容为纯文本的类型，识别结果只能是 text/plain 或 text/xml
+func isTextual(mimeType string) bool {
+	if strings.HasPrefix(mimeType, "text/") && mimeType != "text/html" {
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/xml", "application/javascript", "application/x-javascript",
+		"application/x-yaml", "application/yaml", "application/toml", "image/svg+xml":
+		return true
+	}
+	return false
+}
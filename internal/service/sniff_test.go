@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"upload-util/internal/config"
+)
+
+// peExecutable 最小的 PE 文件头，e_lfanew 指向紧接 DOS 头之后的 PE 签名
+const peExecutable = "MZ\x90\x00" + "\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x40\x00\x00\x00" +
+	"PE\x00\x00\x4c\x01"
+
+func TestDetectPortableExecutable(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"pe", peExecutable, "application/x-msdownload"},
+		{"text starting with MZ", "MZ is the postal code prefix for Mizoram", "text/plain"},
+		{"short MZ", "MZ\x90\x00", "application/octet-stream"},
+		{"e_lfanew out of range", peExecutable[:0x3c] + "\xff\x01\x00\x00", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectContentType([]byte(tt.content)); got != tt.want {
+				t.Errorf("detectContentType = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentMatchesExtension(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		filename string
+		want     bool
+	}{
+		{"jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF", "a.jpg", true},
+		{"png as jpg", "\x89PNG\r\n\x1a\n", "a.jpg", false},
+		{"exe as jpg", peExecutable, "a.jpg", false},
+		{"exe as txt", peExecutable, "a.txt", false},
+		{"MZ text as txt", "MZ is a text file", "a.txt", true},
+		{"elf as png", "\x7fELF\x02\x01", "a.png", false},
+		{"text as txt", "hello world", "a.txt", true},
+		{"text as json", `{"a": 1}`, "a.json", true},
+		{"text as pdf", "hello world", "a.pdf", false},
+		{"zip as docx", "PK\x03\x04", "a.docx", true},
+		{"unknown extension", "\x00\x01\x02\x03", "a.bin", true},
+		// 可执行文件按固定的扩展名表判断，不依赖系统的 mime 表
+		{"exe as exe", peExecutable, "setup.EXE", true},
+		{"exe as dll", peExecutable, "a.dll", true},
+		{"exe with unknown extension", peExecutable, "a.bin", false},
+		{"exe without extension", peExecutable, "setup", false},
+		{"elf without extension", "\x7fELF\x02\x01", "server", true},
+		{"elf as so", "\x7fELF\x02\x01", "libc.so", true},
+		{"elf with unknown extension", "\x7fELF\x02\x01", "a.bin", false},
+		{"mach-o as dylib", "\xcf\xfa\xed\xfe", "a.dylib", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sniffed := detectContentType([]byte(tt.content))
+			if got := contentMatchesExtension(sniffed, tt.filename); got != tt.want {
+				t.Errorf("contentMatchesExtension(%s, %s) = %v, want %v", sniffed, tt.filename, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMimeTypeAllowed(t *testing.T) {
+	allowed := []string{"image/*", "application/pdf"}
+	for mimeType, want := range map[string]bool{
+		"image/png":                 true,
+		"image/jpeg":                true,
+		"application/pdf":           true,
+		"text/plain; charset=utf-8": false,
+		"application/x-msdownload":  false,
+	} {
+		if got := mimeTypeAllowed(mimeType, allowed); got != want {
+			t.Errorf("mimeTypeAllowed(%s) = %v, want %v", mimeType, got, want)
+		}
+	}
+	if !mimeTypeAllowed("application/x-msdownload", nil) {
+		t.Error("empty allowed list should allow everything")
+	}
+}
+
+func TestSniffContent(t *testing.T) {
+	settings := &config.UploadSettings{AllowedMimeTypes: []string{"image/*"}}
+
+	// 不可 Seek 的输入读取前 512 字节后仍能读到全部内容
+	content := "\x89PNG\r\n\x1a\n" + strings.Repeat("x", 1024)
+	r, sniffed, err := sniffContent(io.NopCloser(strings.NewReader(content)), "a.png", settings)
+	if err != nil {
+		t.Fatalf("sniffContent failed: %v", err)
+	}
+	if sniffed != "image/png" {
+		t.Errorf("expected image/png, got %s", sniffed)
+	}
+	if data, _ := io.ReadAll(r); string(data) != content {
+		t.Errorf("content changed after sniffing, got %d bytes", len(data))
+	}
+
+	if _, _, err := sniffContent(strings.NewReader("hello"), "a.txt", settings); !errors.Is(err, ErrMimeTypeNotAllowed) {
+		t.Errorf("expected ErrMimeTypeNotAllowed, got %v", err)
+	}
+	if _, _, err := sniffContent(strings.NewReader(peExecutable), "a.png", settings); !errors.Is(err, ErrContentTypeMismatch) {
+		t.Errorf("expected ErrContentTypeMismatch, got %v", err)
+	}
+}
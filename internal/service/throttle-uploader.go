@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"io"
+	"math"
+	"mime/multipart"
+	"sync"
+	"upload-util/internal/config"
+
+	"golang.org/x/time/rate"
+)
+
+// maxThrottleBurst 带宽令牌桶的容量上限，单次读取不超过该大小，使速度比较平稳
+const maxThrottleBurst = 256 * 1024
+
+// ThrottleUploader 限制上传带宽和请求频率的上传器。带宽令牌桶由所有并发上传共享，
+// 通过上传参数传递到存储后端，在读取上传内容时按令牌桶限速；每次请求存储前等待请求频率令牌
+type ThrottleUploader struct {
+	next      Uploader
+	bandwidth *rate.Limiter
+	perUpload int64
+	ops       *rate.Limiter
+}
+
+// NewThrottleUploader 创建限速上传器，cfg 中为 0 的项不限制
+func NewThrottleUploader(next Uploader, cfg *config.RateLimitConfig) *ThrottleUploader {
+	return newThrottleUploader(next, cfg, newThrottleLimiters(cfg))
+}
+
+func newThrottleUploader(next Uploader, cfg *config.RateLimitConfig, limiters *throttleLimiters) *ThrottleUploader {
+	return &ThrottleUploader{
+		next:      next,
+		bandwidth: limiters.bandwidth,
+		perUpload: cfg.PerUploadBandwidth,
+		ops:       limiters.ops,
+	}
+}
+
+// throttleLimiters 一组限速配置对应的带宽和请求频率令牌桶
+type throttleLimiters struct {
+	bandwidth *rate.Limiter
+	ops       *rate.Limiter
+}
+
+func newThrottleLimiters(cfg *config.RateLimitConfig) *throttleLimiters {
+	limiters := &throttleLimiters{bandwidth: newBandwidthLimiter(cfg.Bandwidth)}
+	if cfg.OpsPerSecond > 0 {
+		burst := cfg.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(cfg.OpsPerSecond))
+		}
+		limiters.ops = rate.NewLimiter(rate.Limit(cfg.OpsPerSecond), burst)
+	}
+	return limiters
+}
+
+// sharedThrottles 按 throttleKey 共享的令牌桶
+var sharedThrottles sync.Map
+
+type throttleKey struct {
+	root    *config.UploadConfig
+	profile string
+	limit   config.RateLimitConfig
+}
+
+// sharedThrottleLimiters 返回同一份配置创建的上传器共用的令牌桶，服务端为每个 profile 创建的上传器因此共享带宽上限。
+// 沿用顶层 rate-limit 的 profile 与顶层配置共用，单独配置了 rate-limit 的 profile 使用自己的令牌桶
+func sharedThrottleLimiters(cfg *config.UploadConfig) *throttleLimiters {
+	root := cfg.Root()
+	key := throttleKey{root: root, limit: cfg.UploadSettings.RateLimit}
+	if cfg.UploadSettings.RateLimit != root.UploadSettings.RateLimit {
+		key.profile = cfg.ProfileName
+	}
+	if limiters, ok := sharedThrottles.Load(key); ok {
+		return limiters.(*throttleLimiters)
+	}
+	limiters, _ := sharedThrottles.LoadOrStore(key, newThrottleLimiters(&cfg.UploadSettings.RateLimit))
+	return limiters.(*throttleLimiters)
+}
+
+// newBandwidthLimiter 创建每秒 bytesPerSecond 字节的令牌桶，不限制时返回 nil
+func newBandwidthLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(min(bytesPerSecond, maxThrottleBurst)))
+}
+
+// throttledReader 读取后按读取的字节数从各令牌桶取令牌，令牌不足时等待
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*rate.Limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	for _, limiter := range t.limiters {
+		if burst := limiter.Burst(); len(p) > burst {
+			p = p[:burst]
+		}
+	}
+	n, err := t.r.Read(p)
+	for _, limiter := range t.limiters {
+		if n == 0 {
+			break
+		}
+		if werr := limiter.WaitN(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// throttledReadSeeker 保留输入的 Seek，使 SDK 仍可按可 Seek 的数据源上传
+type throttledReadSeeker struct {
+	*throttledReader
+	seeker io.Seeker
+}
+
+func (t *throttledReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return t.seeker.Seek(offset, whence)
+}
+
+// throttleReader 按令牌桶限制 r 的读取速度，没有令牌桶时原样返回
+func throttleReader(ctx context.Context, r io.Reader, limiters []*rate.Limiter) io.Reader {
+	if len(limiters) == 0 {
+		return r
+	}
+	reader := &throttledReader{ctx: ctx, r: r, limiters: limiters}
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		return &throttledReadSeeker{throttledReader: reader, seeker: seeker}
+	}
+	return reader
+}
+
+func (u *ThrottleUploader) wait(ctx context.Context) error {
+	if u.ops == nil {
+		return nil
+	}
+	return u.ops.Wait(ctx)
+}
+
+func (u *ThrottleUploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	return u.UploadReader(ctx, file, header.Filename, header.Size)
+}
+
+// UploadReader 单个上传的带宽上限可以通过 WithBandwidthLimit 覆盖配置
+func (u *ThrottleUploader) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	if err := u.wait(ctx); err != nil {
+		return nil, err
+	}
+	if u.perUpload > 0 {
+		opts = append([]UploadOption{WithBandwidthLimit(u.perUpload)}, opts...)
+	}
+	if u.bandwidth != nil {
+		opts = append(opts[:len(opts):len(opts)], withLimiter(u.bandwidth))
+	}
+	return u.next.UploadReader(ctx, r, name, size, opts...)
+}
+
+func (u *ThrottleUploader) Delete(ctx context.Context, key string) error {
+	if err := u.wait(ctx); err != nil {
+		return err
+	}
+	return u.next.Delete(ctx, key)
+}
+
+func (u *ThrottleUploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	if err := u.wait(ctx); err != nil {
+		return nil, err
+	}
+	return u.next.DeleteMany(ctx, keys)
+}
+
+func (u *ThrottleUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	if err := u.wait(ctx); err != nil {
+		return nil, err
+	}
+	return u.next.DeletePrefix(ctx, prefix, dryRun)
+}
+
+func (u *ThrottleUploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	if err := u.wait(ctx); err != nil {
+		return nil, err
+	}
+	return u.next.Open(ctx, key, opts...)
+}
+
+func (u *ThrottleUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	if err := u.wait(ctx); err != nil {
+		return nil, err
+	}
+	return u.next.Stat(ctx, key)
+}
+
+func (u *ThrottleUploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	if err := u.wait(ctx); err != nil {
+		return nil, err
+	}
+	return u.next.List(ctx, prefix, delimiter, cursor, limit)
+}
+
+func (u *ThrottleUploader) Copy(ctx context.Context, src, dst string) error {
+	if err := u.wait(ctx); err != nil {
+		return err
+	}
+	return u.next.Copy(ctx, src, dst)
+}
+
+func (u *ThrottleUploader) Move(ctx context.Context, src, dst string) error {
+	if err := u.wait(ctx); err != nil {
+		return err
+	}
+	return u.next.Move(ctx, src, dst)
+}
+
+// GetURL 和 Presign 只在本地签名，不计入请求频率
+func (u *ThrottleUploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	return u.next.GetURL(ctx, key, opts...)
+}
+
+func (u *ThrottleUploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	return u.next.Presign(ctx, req)
+}
+
+// Unwrap 返回被包装的上传器
+func (u *ThrottleUploader) Unwrap() Uploader {
+	return u.next
+}
+
+// Health 返回被包装上传器的熔断状态
+func (u *ThrottleUploader) Health() []BackendHealth {
+	return UploaderHealth(u.next)
+}
+
+// Wait 等待被包装上传器的后台任务完成
+func (u *ThrottleUploader) Wait() {
+	WaitUploader(u.next)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"upload-util/internal/config"
+)
+
+// optionsUploader 记录上传时收到的参数
+type optionsUploader struct {
+	Uploader
+	options *UploadOptions
+}
+
+func (o *optionsUploader) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	o.options = newUploadOptions(opts)
+	return &UploadResult{Key: name}, nil
+}
+
+func (o *optionsUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	return &ObjectInfo{Key: key}, nil
+}
+
+func TestThrottleReader(t *testing.T) {
+	options := &UploadOptions{BandwidthLimit: 10000}
+	reader := options.throttle(context.Background(), strings.NewReader(strings.Repeat("a", 15000)))
+	if _, ok := reader.(io.Seeker); !ok {
+		t.Error("expected throttled reader to keep Seek")
+	}
+	start := time.Now()
+	n, err := io.Copy(io.Discard, reader)
+	if err != nil || n != 15000 {
+		t.Fatalf("unexpected copy result: %d, %v", n, err)
+	}
+	// 令牌桶初始有 10000 字节，剩余 5000 字节需要约 0.5 秒
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected throttled read to take about 0.5s, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reader = options.throttle(ctx, strings.NewReader(strings.Repeat("a", 15000)))
+	if _, err := io.Copy(io.Discard, reader); err == nil {
+		t.Error("expected canceled context to stop throttled read")
+	}
+}
+
+func TestThrottleUploader(t *testing.T) {
+	next := &optionsUploader{}
+	u := NewThrottleUploader(next, &config.RateLimitConfig{Bandwidth: 1 << 20, PerUploadBandwidth: 1 << 10, OpsPerSecond: 10, Burst: 1})
+
+	if _, err := u.UploadReader(context.Background(), strings.NewReader("a"), "a.txt", 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(next.options.limiters) != 1 || next.options.BandwidthLimit != 1<<10 {
+		t.Errorf("expected shared limiter and per-upload limit, got %+v", next.options)
+	}
+	// 单次上传指定的带宽上限覆盖配置
+	if _, err := u.UploadReader(context.Background(), strings.NewReader("a"), "a.txt", 1, WithBandwidthLimit(1<<12)); err != nil {
+		t.Fatal(err)
+	}
+	if next.options.BandwidthLimit != 1<<12 {
+		t.Errorf("expected per-upload override, got %d", next.options.BandwidthLimit)
+	}
+
+	// 每秒 10 次，容量 1，再请求 3 次约需 0.3 秒
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := u.Stat(context.Background(), "a.txt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("expected ops limit to delay requests, took %s", elapsed)
+	}
+}
+
+func TestSharedThrottleLimiters(t *testing.T) {
+	local := config.UploadProvider{Type: "local", Local: &config.LocalConfig{Path: t.TempDir()}}
+	cfg := &config.UploadConfig{
+		Upload: local,
+		UploadSettings: config.UploadSettings{
+			MaxFileSize: 1,
+			RateLimit:   config.RateLimitConfig{PerUploadBandwidth: 10000, OpsPerSecond: 10},
+		},
+		Profiles: map[string]*config.ProfileConfig{
+			"inherit": {Upload: local},
+			"own": {Upload: local, UploadSettings: config.UploadSettings{
+				RateLimit: config.RateLimitConfig{Bandwidth: 1 << 20},
+			}},
+		},
+	}
+	create := func(name string) *ThrottleUploader {
+		t.Helper()
+		profile, err := cfg.Profile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := NewUploadFactory(profile).CreateUploader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		throttle, ok := u.(*ThrottleUploader)
+		if !ok {
+			t.Fatalf("%s: expected local storage to be throttled, got %T", name, u)
+		}
+		return throttle
+	}
+	root, inherit, own := create(""), create("inherit"), create("own")
+	if root.ops == nil || inherit.ops != root.ops || create("").ops != root.ops {
+		t.Error("expected uploaders created from the same settings to share limiters")
+	}
+	if own.bandwidth == nil || own.ops != nil {
+		t.Errorf("expected profile with its own rate-limit to use its own limiters, got %+v", own)
+	}
+
+	// 本地存储按带宽上限限速，令牌桶初始有 10000 字节，剩余 5000 字节需要约 0.5 秒
+	start := time.Now()
+	if _, err := root.UploadReader(context.Background(), strings.NewReader(strings.Repeat("a", 15000)), "a.txt", 15000); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected local upload to be throttled, took %s", elapsed)
+	}
+}
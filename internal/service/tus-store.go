@@ -0,0 +1,265 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"upload-util/internal/config"
+
+	"github.com/google/uuid"
+)
+
+const defaultTusExpire = 24 * 60 * 60 // 秒
+
+var (
+	ErrTusUploadNotFound = errors.New("tus upload not found")
+	ErrTusOffsetMismatch = errors.New("tus upload offset mismatch")
+	ErrTusUploadLocked   = errors.New("tus upload is locked by another request")
+)
+
+// TusUpload 断点续传的上传状态，以 JSON 形式持久化在本地
+type TusUpload struct {
+	ID        string            `json:"id"`
+	Size      int64             `json:"size"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Result    *UploadResult     `json:"result,omitempty"`
+}
+
+// Filename 返回客户端在 Upload-Metadata 中声明的文件名
+func (t *TusUpload) Filename() string {
+	if name := t.Metadata["filename"]; name != "" {
+		return name
+	}
+	if name := t.Metadata["name"]; name != "" {
+		return name
+	}
+	return t.ID
+}
+
+// Completed 是否已接收全部数据
+func (t *TusUpload) Completed() bool {
+	return t.Offset >= t.Size
+}
+
+// TusStore 在本地目录中保存未完成的上传数据和偏移量
+type TusStore struct {
+	dir    string
+	expire time.Duration
+
+	mu     sync.Mutex
+	locked map[string]bool
+}
+
+func NewTusStore(cfg *config.TusConfig) (*TusStore, error) {
+	dir := filepath.Join(os.TempDir(), "upload-util-tus")
+	expire := int64(defaultTusExpire)
+	if cfg != nil {
+		if cfg.Path != "" {
+			dir = cfg.Path
+		}
+		if cfg.Expire > 0 {
+			expire = cfg.Expire
+		}
+	}
+	if strings.HasPrefix(dir, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user home dir: %w", err)
+		}
+		dir = filepath.Join(homeDir, dir[2:])
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tus path: %w", err)
+	}
+	return &TusStore{
+		dir:    dir,
+		expire: time.Duration(expire) * time.Second,
+		locked: make(map[string]bool),
+	}, nil
+}
+
+// Create 创建新的上传并清理已过期的上传
+func (s *TusStore) Create(size int64, metadata map[string]string) (*TusUpload, error) {
+	s.CleanupExpired()
+
+	now := time.Now()
+	upload := &TusUpload{
+		ID:        strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Size:      size,
+		Metadata:  metadata,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.expire),
+	}
+	data, err := os.Create(s.dataPath(upload.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tus data file: %w", err)
+	}
+	if err := data.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close tus data file: %w", err)
+	}
+	if err := s.save(upload); err != nil {
+		_ = os.Remove(s.dataPath(upload.ID))
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Get 读取上传状态，已过期的上传视为不存在
+func (s *TusStore) Get(id string) (*TusUpload, error) {
+	if !isValidTusID(id) {
+		return nil, ErrTusUploadNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrTusUploadNotFound
+		}
+		return nil, fmt.Errorf("failed to read tus info: %w", err)
+	}
+	var upload TusUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, fmt.Errorf("failed to parse tus info: %w", err)
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		_ = s.Terminate(id)
+		return nil, ErrTusUploadNotFound
+	}
+	return &upload, nil
+}
+
+// Lock 独占一个上传，防止同一上传被并发写入
+func (s *TusStore) Lock(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked[id] {
+		return ErrTusUploadLocked
+	}
+	s.locked[id] = true
+	return nil
+}
+
+func (s *TusStore) Unlock(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.locked, id)
+}
+
+// Append 从 offset 处追加数据，最多写入剩余长度，返回更新后的上传状态。
+// 即使读取 r 中途失败，已写入的数据也会被记录，客户端可从新的偏移量继续上传。
+func (s *TusStore) Append(upload *TusUpload, offset int64, r io.Reader) (*TusUpload, error) {
+	if offset != upload.Offset {
+		return upload, ErrTusOffsetMismatch
+	}
+	data, err := os.OpenFile(s.dataPath(upload.ID), os.O_WRONLY, 0644)
+	if err != nil {
+		return upload, fmt.Errorf("failed to open tus data file: %w", err)
+	}
+	defer data.Close()
+	if _, err := data.Seek(offset, io.SeekStart); err != nil {
+		return upload, fmt.Errorf("failed to seek tus data file: %w", err)
+	}
+
+	written, copyErr := io.Copy(data, io.LimitReader(r, upload.Size-offset))
+	upload.Offset += written
+	upload.ExpiresAt = time.Now().Add(s.expire)
+	if err := s.save(upload); err != nil {
+		return upload, err
+	}
+	if copyErr != nil {
+		return upload, fmt.Errorf("failed to write tus data: %w", copyErr)
+	}
+	return upload, nil
+}
+
+// Open 打开已接收的数据用于转存
+func (s *TusStore) Open(upload *TusUpload) (*os.File, error) {
+	return os.Open(s.dataPath(upload.ID))
+}
+
+// Finish 记录转存结果并删除本地数据，保留状态直到过期以便客户端查询
+func (s *TusStore) Finish(upload *TusUpload, result *UploadResult) error {
+	upload.Result = result
+	if err := s.save(upload); err != nil {
+		return err
+	}
+	if err := os.Remove(s.dataPath(upload.ID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove tus data file: %w", err)
+	}
+	return nil
+}
+
+// Terminate 删除上传的数据和状态
+func (s *TusStore) Terminate(id string) error {
+	if !isValidTusID(id) {
+		return ErrTusUploadNotFound
+	}
+	if err := os.Remove(s.dataPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove tus data file: %w", err)
+	}
+	if err := os.Remove(s.infoPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrTusUploadNotFound
+		}
+		return fmt.Errorf("failed to remove tus info file: %w", err)
+	}
+	return nil
+}
+
+// CleanupExpired 删除所有已过期的上传
+func (s *TusStore) CleanupExpired() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".info"); ok {
+			// Get 会删除已过期的上传
+			_, _ = s.Get(id)
+		}
+	}
+}
+
+func (s *TusStore) save(upload *TusUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("failed to encode tus info: %w", err)
+	}
+	tmpPath := s.infoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write tus info: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.infoPath(upload.ID)); err != nil {
+		return fmt.Errorf("failed to save tus info: %w", err)
+	}
+	return nil
+}
+
+func (s *TusStore) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *TusStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+// isValidTusID 防止通过 id 访问存储目录之外的文件
+func isValidTusID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"upload-util/internal/config"
+)
+
+func TestTusStore(t *testing.T) {
+	store, err := NewTusStore(&config.TusConfig{Path: t.TempDir(), Expire: 60})
+	if err != nil {
+		t.Fatalf("NewTusStore failed: %v", err)
+	}
+
+	t.Run("resume from offset", func(t *testing.T) {
+		upload, err := store.Create(11, map[string]string{"filename": "hello.txt"})
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if upload.Filename() != "hello.txt" {
+			t.Errorf("expected filename hello.txt, got %s", upload.Filename())
+		}
+
+		upload, err = store.Append(upload, 0, strings.NewReader("hello"))
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		if upload.Offset != 5 || upload.Completed() {
+			t.Fatalf("expected offset 5 and incomplete, got %d", upload.Offset)
+		}
+
+		// 模拟中断后重新读取状态
+		upload, err = store.Get(upload.ID)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if _, err := store.Append(upload, 0, strings.NewReader("hello")); !errors.Is(err, ErrTusOffsetMismatch) {
+			t.Errorf("expected offset mismatch, got %v", err)
+		}
+		upload, err = store.Append(upload, 5, strings.NewReader(" world and more"))
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		if upload.Offset != 11 || !upload.Completed() {
+			t.Fatalf("expected completed upload, got offset %d", upload.Offset)
+		}
+
+		file, err := store.Open(upload)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		data, _ := io.ReadAll(file)
+		file.Close()
+		if string(data) != "hello world" {
+			t.Errorf("expected hello world, got %q", data)
+		}
+	})
+
+	t.Run("terminate", func(t *testing.T) {
+		upload, err := store.Create(1, nil)
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := store.Terminate(upload.ID); err != nil {
+			t.Fatalf("Terminate failed: %v", err)
+		}
+		if _, err := store.Get(upload.ID); !errors.Is(err, ErrTusUploadNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		if _, err := store.Get("../../etc/passwd"); !errors.Is(err, ErrTusUploadNotFound) {
+			t.Errorf("expected not found, got %v", err)
+		}
+	})
+
+	t.Run("lock", func(t *testing.T) {
+		if err := store.Lock("abc"); err != nil {
+			t.Fatalf("Lock failed: %v", err)
+		}
+		if err := store.Lock("abc"); !errors.Is(err, ErrTusUploadLocked) {
+			t.Errorf("expected locked, got %v", err)
+		}
+		store.Unlock("abc")
+		if err := store.Lock("abc"); err != nil {
+			t.Errorf("Lock after unlock failed: %v", err)
+		}
+		store.Unlock("abc")
+	})
+}
@@ -1,7 +1,9 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"mime"
 	"mime/multipart"
 	"path"
@@ -13,7 +15,14 @@ import (
 	"github.com/google/uuid"
 )
 
-func generateFileName(header *multipart.FileHeader, settings *config.UploadSettings) string {
+// generateFileName 生成文件名，配置了 key-template 时按模板生成，否则按文件名策略生成。
+// sum 为内容哈希，仅在 sha256 和 md5 策略下使用，为空时 (如直传签名) 退化为 uuid；
+// user 用于模板中的 {user}
+func generateFileName(header *multipart.FileHeader, settings *config.UploadSettings, sum, user string) string {
+	if parts, err := config.ParseKeyTemplate(settings.KeyTemplate); err == nil && parts != nil {
+		return renderKeyTemplate(parts, header.Filename, sum, user, time.Now())
+	}
+
 	originalName := header.Filename
 	extension := filepath.Ext(originalName)
 	nameWithoutExtension := strings.TrimSuffix(originalName, extension)
@@ -26,6 +35,11 @@ func generateFileName(header *multipart.FileHeader, settings *config.UploadSetti
 		newName = fmt.Sprintf("%d", time.Now().Unix())
 	case "original":
 		newName = nameWithoutExtension
+	case "sha256", "md5":
+		newName = sum
+		if newName == "" {
+			newName = uuid.New().String()
+		}
 	default:
 		newName = uuid.New().String()
 
@@ -39,7 +53,7 @@ func generateFileName(header *multipart.FileHeader, settings *config.UploadSetti
 func validateFile(header *multipart.FileHeader, settings *config.UploadSettings) error {
 	maxSize := settings.MaxFileSize * 1024 * 1024
 	if header.Size > maxSize {
-		return fmt.Errorf("file size %d exceeds maximum allowed size %d", header.Size, maxSize)
+		return fmt.Errorf("%w: %d > %d", ErrFileTooLarge, header.Size, maxSize)
 	}
 	if len(settings.AllowedExtensions) > 0 {
 		ext := strings.ToLower(filepath.Ext(header.Filename))
@@ -51,12 +65,21 @@ func validateFile(header *multipart.FileHeader, settings *config.UploadSettings)
 			}
 		}
 		if !allowed {
-			return fmt.Errorf("file extension %s is not allowed", ext)
+			return fmt.Errorf("%w: %s", ErrExtensionNotAllowed, ext)
 		}
 	}
+	// 扩展名对应的类型不在允许列表中时提前拒绝，实际内容在上传时再次校验
+	if mimeType := mediaType(getMimeType(header.Filename)); mimeType != "application/octet-stream" && !mimeTypeAllowed(mimeType, settings.AllowedMimeTypes) {
+		return fmt.Errorf("%w: %s", ErrMimeTypeNotAllowed, mimeType)
+	}
 	return nil
 }
 
+// ValidateFile 校验文件大小和扩展名，供实际上传前的预检使用
+func ValidateFile(header *multipart.FileHeader, settings *config.UploadSettings) error {
+	return validateFile(header, settings)
+}
+
 func getMimeType(filename string) string {
 	ext := filepath.Ext(filename)
 	mimeType := mime.TypeByExtension(ext)
@@ -88,3 +111,39 @@ func buildURL(domain, bucket, endpoint, key string, useSSL bool) string {
 	}
 	return fmt.Sprintf("%s://%s.%s/%s", protocol, bucket, endpoint, key)
 }
+
+// limitUploadSize 大小未知时限制实际读取的字节数不超过 MaxFileSize
+func limitUploadSize(r io.Reader, size int64, settings *config.UploadSettings) io.Reader {
+	if size >= 0 || settings.MaxFileSize <= 0 {
+		return r
+	}
+	return &maxSizeReader{r: r, remaining: settings.MaxFileSize * 1024 * 1024}
+}
+
+// maxSizeReader 读取超过 remaining 字节时返回 ErrFileTooLarge
+type maxSizeReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	if m.remaining < 0 {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
+
+// contextReader ctx 被取消后读取返回 ctx 的错误
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
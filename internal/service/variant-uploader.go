@@ -0,0 +1,423 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"path"
+	"strings"
+	"upload-util/internal/config"
+)
+
+const (
+	// maxVariantSource 生成缩略图时在内存中保留的原图大小上限，超过时跳过缩略图
+	maxVariantSource = 64 * 1024 * 1024
+	// maxVariantPixels 解码的原图像素数上限，防止尺寸很大的图片占用过多内存
+	maxVariantPixels      = 50_000_000
+	defaultVariantQuality = 85
+)
+
+// variantFormats 生成缩略图的内容类型及对应的 image 包格式名称
+var variantFormats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Variant 上传图片时生成的缩略图
+type Variant struct {
+	Name   string `json:"name"`
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// VariantUploader 上传 JPEG、PNG、GIF 图片后按配置生成缩略图，与原图存放在同一存储，
+// 对象键见 VariantKey。删除、复制和移动原图时同时处理缩略图
+type VariantUploader struct {
+	next     Uploader
+	variants []config.ImageVariant
+}
+
+// NewVariantUploader 创建生成缩略图的上传器
+func NewVariantUploader(next Uploader, variants []config.ImageVariant) *VariantUploader {
+	return &VariantUploader{next: next, variants: variants}
+}
+
+// VariantKey 返回缩略图的对象键，在原图键的扩展名前加上 _名称，如 2024/01/a1b2.jpg 的 thumb 为 2024/01/a1b2_thumb.jpg
+func VariantKey(key, name string) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_" + name + ext
+}
+
+// variantKeys 返回图片对象所有缩略图的键，不是图片时返回 nil
+func (u *VariantUploader) variantKeys(key string) []string {
+	if _, ok := variantFormats[getMimeType(key)]; !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(u.variants))
+	for _, v := range u.variants {
+		keys = append(keys, VariantKey(key, v.Name))
+	}
+	return keys
+}
+
+// variantSource 保留上传的原图内容，可 Seek 的输入上传后回到起始位置重新读取，
+// 否则在读取时复制到内存
+type variantSource struct {
+	reader io.Reader
+	seeker io.ReadSeeker
+	start  int64
+	buf    *cappedBuffer
+}
+
+func newVariantSource(r io.Reader) (*variantSource, error) {
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		return &variantSource{reader: r, seeker: seeker, start: start}, nil
+	}
+	buf := &cappedBuffer{limit: maxVariantSource}
+	return &variantSource{reader: io.TeeReader(r, buf), buf: buf}, nil
+}
+
+// bytes 返回原图内容
+func (s *variantSource) bytes() ([]byte, error) {
+	if s.seeker == nil {
+		if s.buf.overflow {
+			return nil, fmt.Errorf("image larger than %d MB", maxVariantSource/1024/1024)
+		}
+		return s.buf.Bytes(), nil
+	}
+	if _, err := s.seeker.Seek(s.start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(s.seeker, maxVariantSource+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxVariantSource {
+		return nil, fmt.Errorf("image larger than %d MB", maxVariantSource/1024/1024)
+	}
+	return data, nil
+}
+
+// cappedBuffer 超过 limit 后丢弃内容，写入始终成功，不影响 TeeReader 的读取
+type cappedBuffer struct {
+	bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if !b.overflow && b.Len()+len(p) <= b.limit {
+		return b.Buffer.Write(p)
+	}
+	b.overflow = true
+	b.Reset()
+	return len(p), nil
+}
+
+func (u *VariantUploader) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
+	return u.UploadReader(ctx, file, header.Filename, header.Size)
+}
+
+// UploadReader 原图上传成功后生成缩略图。原图无法解码时只记录日志，不生成缩略图；
+// 缩略图写入失败时删除本次写入的原图和缩略图并返回错误
+func (u *VariantUploader) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	options := newUploadOptions(opts)
+	if _, ok := variantFormats[options.contentType(name)]; !ok {
+		return u.next.UploadReader(ctx, r, name, size, opts...)
+	}
+	source, err := newVariantSource(r)
+	if err != nil {
+		return nil, err
+	}
+	result, err := u.next.UploadReader(ctx, source.reader, name, size, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := variantFormats[result.SniffedType]; result.SniffedType != "" && !ok {
+		return result, nil
+	}
+	variants, err := u.createVariants(ctx, result, source, options)
+	if err != nil {
+		// 请求被取消时也要删除原图，避免留下没有缩略图的对象
+		if !result.Deduplicated {
+			cleanupCtx, cancel := abortContext(ctx)
+			_ = u.next.Delete(cleanupCtx, result.Key)
+			cancel()
+		}
+		return nil, err
+	}
+	result.Variants = variants
+	return result, nil
+}
+
+// createVariants 生成并上传缩略图。去重命中时原图的缩略图通常已经存在，只补充缺少的
+func (u *VariantUploader) createVariants(ctx context.Context, result *UploadResult, source *variantSource, options *UploadOptions) ([]Variant, error) {
+	variants := make([]Variant, 0, len(u.variants))
+	var missing []config.ImageVariant
+	for _, v := range u.variants {
+		key := VariantKey(result.Key, v.Name)
+		if !result.Deduplicated {
+			missing = append(missing, v)
+			continue
+		}
+		info, err := u.next.Stat(ctx, key)
+		if errors.Is(err, ErrObjectNotFound) {
+			missing = append(missing, v)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("variant %s: %w", v.Name, err)
+		}
+		url, err := u.next.GetURL(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("variant %s: %w", v.Name, err)
+		}
+		variants = append(variants, Variant{Name: v.Name, Key: key, URL: url, Size: info.Size})
+	}
+	if len(missing) == 0 {
+		return variants, nil
+	}
+
+	img, format, err := decodeVariantSource(source)
+	if err != nil {
+		log.Printf("variants: skip %s: %v", result.Key, err)
+		return variants, nil
+	}
+	var created []string
+	for _, v := range missing {
+		variant, err := u.uploadVariant(ctx, result.Key, img, format, v, options)
+		if err != nil {
+			if len(created) > 0 {
+				cleanupCtx, cancel := abortContext(ctx)
+				_, _ = u.next.DeleteMany(cleanupCtx, created)
+				cancel()
+			}
+			return nil, fmt.Errorf("variant %s: %w", v.Name, err)
+		}
+		created = append(created, variant.Key)
+		variants = append(variants, *variant)
+	}
+	return variants, nil
+}
+
+// decodeVariantSource 解码原图，GIF 只取第一帧
+func decodeVariantSource(source *variantSource) (image.Image, string, error) {
+	data, err := source.bytes()
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width*cfg.Height > maxVariantPixels {
+		return nil, "", fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	return img, format, nil
+}
+
+func (u *VariantUploader) uploadVariant(ctx context.Context, key string, img image.Image, format string, v config.ImageVariant, options *UploadOptions) (*Variant, error) {
+	region, width, height := variantSize(img.Bounds(), v.Width, v.Height, v.Mode == "crop")
+	resized := resizeImage(img, region, width, height)
+	var buf bytes.Buffer
+	if err := encodeVariant(&buf, resized, format, v.Quality); err != nil {
+		return nil, err
+	}
+	variantKey := VariantKey(key, v.Name)
+	result, err := u.next.UploadReader(ctx, bytes.NewReader(buf.Bytes()), path.Base(variantKey), int64(buf.Len()),
+		WithKey(variantKey), WithContentType("image/"+format), WithMetadata(options.Metadata))
+	if err != nil {
+		return nil, err
+	}
+	return &Variant{Name: v.Name, Key: result.Key, URL: result.URL, Width: width, Height: height, Size: result.Size}, nil
+}
+
+// encodeVariant 按原图的格式编码缩略图
+func encodeVariant(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case "jpeg":
+		if quality <= 0 {
+			quality = defaultVariantQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "gif":
+		return gif.Encode(w, img, nil)
+	case "png":
+		return png.Encode(w, img)
+	default:
+		return fmt.Errorf("unsupported image format: %s", format)
+	}
+}
+
+// Delete 删除原图后尽力删除其缩略图
+func (u *VariantUploader) Delete(ctx context.Context, key string) error {
+	if err := u.next.Delete(ctx, key); err != nil {
+		return err
+	}
+	u.deleteVariants(ctx, []string{key})
+	return nil
+}
+
+// DeleteMany 返回原图的删除结果，删除成功的原图再尽力删除其缩略图
+func (u *VariantUploader) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	result, err := u.next.DeleteMany(ctx, keys)
+	if result != nil {
+		u.deleteVariants(ctx, result.Deleted)
+	}
+	return result, err
+}
+
+// deleteVariants 缩略图可能不存在 (如配置修改前上传的图片)，忽略删除结果
+func (u *VariantUploader) deleteVariants(ctx context.Context, keys []string) {
+	var variantKeys []string
+	for _, key := range keys {
+		variantKeys = append(variantKeys, u.variantKeys(key)...)
+	}
+	if len(variantKeys) > 0 {
+		_, _ = u.next.DeleteMany(ctx, variantKeys)
+	}
+}
+
+// DeletePrefix 缩略图与原图前缀相同，一并删除
+func (u *VariantUploader) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	return u.next.DeletePrefix(ctx, prefix, dryRun)
+}
+
+func (u *VariantUploader) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	return u.next.Open(ctx, key, opts...)
+}
+
+func (u *VariantUploader) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	return u.next.Stat(ctx, key)
+}
+
+// List 不列出缩略图，同步、迁移等按列举结果处理对象时只处理原图，缩略图随原图一起处理。
+// 过滤后单页的对象可能少于 limit，按 NextCursor 继续列举即可
+func (u *VariantUploader) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	result, err := u.next.List(ctx, prefix, delimiter, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool, len(result.Objects))
+	for _, object := range result.Objects {
+		listed[object.Key] = true
+	}
+	objects := make([]ObjectInfo, 0, len(result.Objects))
+	for _, object := range result.Objects {
+		variant, err := u.isVariant(ctx, object.Key, listed)
+		if err != nil {
+			return nil, err
+		}
+		if !variant {
+			objects = append(objects, object)
+		}
+	}
+	result.Objects = objects
+	return result, nil
+}
+
+// isVariant 对象键形如 原图键_名称 且原图存在时为缩略图，原图不在本页列举结果中时查询存储，
+// 避免将恰好以 _名称 结尾的原图当作缩略图
+func (u *VariantUploader) isVariant(ctx context.Context, key string, listed map[string]bool) (bool, error) {
+	if _, ok := variantFormats[getMimeType(key)]; !ok {
+		return false, nil
+	}
+	ext := path.Ext(key)
+	base := strings.TrimSuffix(key, ext)
+	for _, v := range u.variants {
+		if !strings.HasSuffix(base, "_"+v.Name) {
+			continue
+		}
+		original := strings.TrimSuffix(base, "_"+v.Name) + ext
+		if listed[original] {
+			return true, nil
+		}
+		_, err := u.next.Stat(ctx, original)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, ErrObjectNotFound) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// Copy 复制原图及已存在的缩略图
+func (u *VariantUploader) Copy(ctx context.Context, src, dst string) error {
+	if err := u.next.Copy(ctx, src, dst); err != nil {
+		return err
+	}
+	return u.eachVariant(ctx, src, dst, u.next.Copy)
+}
+
+// Move 移动原图及已存在的缩略图
+func (u *VariantUploader) Move(ctx context.Context, src, dst string) error {
+	if err := u.next.Move(ctx, src, dst); err != nil {
+		return err
+	}
+	return u.eachVariant(ctx, src, dst, u.next.Move)
+}
+
+// eachVariant 对 src 已存在的每个缩略图调用 fn，目标为 dst 对应的缩略图键
+func (u *VariantUploader) eachVariant(ctx context.Context, src, dst string, fn func(ctx context.Context, src, dst string) error) error {
+	if path.Ext(src) != path.Ext(dst) || u.variantKeys(src) == nil {
+		return nil
+	}
+	for _, v := range u.variants {
+		srcKey, dstKey := VariantKey(src, v.Name), VariantKey(dst, v.Name)
+		if _, err := u.next.Stat(ctx, srcKey); errors.Is(err, ErrObjectNotFound) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("variant %s: %w", v.Name, err)
+		}
+		if err := fn(ctx, srcKey, dstKey); err != nil {
+			return fmt.Errorf("variant %s: %w", v.Name, err)
+		}
+	}
+	return nil
+}
+
+func (u *VariantUploader) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	return u.next.GetURL(ctx, key, opts...)
+}
+
+// Presign 直传的内容不经过服务端，不生成缩略图
+func (u *VariantUploader) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	return u.next.Presign(ctx, req)
+}
+
+// Unwrap 返回被包装的上传器
+func (u *VariantUploader) Unwrap() Uploader {
+	return u.next
+}
+
+// Health 返回被包装上传器的熔断状态
+func (u *VariantUploader) Health() []BackendHealth {
+	return UploaderHealth(u.next)
+}
+
+// Wait 等待被包装上传器的后台任务完成
+func (u *VariantUploader) Wait() {
+	WaitUploader(u.next)
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"upload-util/internal/config"
+)
+
+func testImage(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVariantUploader(t *testing.T) {
+	local, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{MaxFileSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := NewVariantUploader(local, []config.ImageVariant{
+		{Name: "thumb", Width: 100, Height: 100, Mode: "crop"},
+		{Name: "medium", Width: 150},
+	})
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		format string
+		name   string
+		reader func([]byte) io.Reader
+	}{
+		{"png", "photo.png", func(data []byte) io.Reader { return bytes.NewReader(data) }},
+		// 不可 Seek 的输入在上传时复制到内存
+		{"jpeg", "photo.jpg", func(data []byte) io.Reader { return io.MultiReader(bytes.NewReader(data)) }},
+		{"gif", "photo.gif", func(data []byte) io.Reader { return io.MultiReader(bytes.NewReader(data)) }},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			data := testImage(t, tt.format, 300, 200)
+			result, err := u.UploadReader(ctx, tt.reader(data), tt.name, int64(len(data)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Variants) != 2 {
+				t.Fatalf("expected 2 variants, got %+v", result.Variants)
+			}
+			want := map[string][2]int{"thumb": {100, 100}, "medium": {150, 100}}
+			for _, v := range result.Variants {
+				if v.Key != VariantKey(result.Key, v.Name) || v.URL == "" {
+					t.Errorf("unexpected variant %+v", v)
+				}
+				reader, err := local.Open(ctx, v.Key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cfg, format, err := image.DecodeConfig(reader)
+				reader.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if format != tt.format || [2]int{cfg.Width, cfg.Height} != want[v.Name] || [2]int{v.Width, v.Height} != want[v.Name] {
+					t.Errorf("%s: expected %s %v, got %s %dx%d", v.Name, tt.format, want[v.Name], format, cfg.Width, cfg.Height)
+				}
+			}
+
+			if err := u.Delete(ctx, result.Key); err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range result.Variants {
+				if _, err := local.Stat(ctx, v.Key); err == nil {
+					t.Errorf("expected variant %s to be deleted", v.Key)
+				}
+			}
+		})
+	}
+
+	t.Run("NotImage", func(t *testing.T) {
+		result, err := u.UploadReader(ctx, strings.NewReader("hello"), "a.txt", 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Variants != nil {
+			t.Errorf("expected no variants, got %+v", result.Variants)
+		}
+	})
+}
+
+func TestVariantSize(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 200)
+	tests := []struct {
+		name          string
+		width, height int
+		crop          bool
+		region        image.Rectangle
+		dw, dh        int
+	}{
+		{"FitWidth", 100, 0, false, bounds, 100, 50},
+		{"FitBox", 100, 100, false, bounds, 100, 50},
+		{"FitNoUpscale", 800, 800, false, bounds, 400, 200},
+		{"Crop", 100, 100, true, image.Rect(100, 0, 300, 200), 100, 100},
+		{"CropWide", 400, 100, true, image.Rect(0, 50, 400, 150), 400, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			region, dw, dh := variantSize(bounds, tt.width, tt.height, tt.crop)
+			if region != tt.region || dw != tt.dw || dh != tt.dh {
+				t.Errorf("expected %v %dx%d, got %v %dx%d", tt.region, tt.dw, tt.dh, region, dw, dh)
+			}
+		})
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	// 左半黑右半白的图片缩小为 2x1 后仍为一黑一白，缩小为 1x1 时为灰色
+	src := image.NewGray(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 4; x < 8; x++ {
+			src.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	dst := resizeImage(src, src.Bounds(), 2, 1)
+	if r, _, _, _ := dst.At(0, 0).RGBA(); r != 0 {
+		t.Errorf("expected black left pixel, got %v", dst.At(0, 0))
+	}
+	if r, _, _, _ := dst.At(1, 0).RGBA(); r != 0xffff {
+		t.Errorf("expected white right pixel, got %v", dst.At(1, 0))
+	}
+	dst = resizeImage(src, src.Bounds(), 1, 1)
+	if c := dst.RGBAAt(0, 0); c.R < 127 || c.R > 128 {
+		t.Errorf("expected gray pixel, got %v", c)
+	}
+}
+
+func TestVariantListAndMigrate(t *testing.T) {
+	variants := []config.ImageVariant{{Name: "thumb", Width: 50, Height: 50, Mode: "crop"}}
+	newVariantUploader := func() (*LocalUploader, *VariantUploader) {
+		local, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{MaxFileSize: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return local, NewVariantUploader(local, variants)
+	}
+	listKeys := func(u Uploader) []string {
+		var keys []string
+		if err := listAll(context.Background(), u, "", func(info ObjectInfo) bool {
+			keys = append(keys, info.Key)
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return keys
+	}
+	ctx := context.Background()
+	srcLocal, src := newVariantUploader()
+	data := testImage(t, "png", 100, 100)
+	for _, key := range []string{"a.png", "b.png"} {
+		if _, err := src.UploadReader(ctx, bytes.NewReader(data), key, int64(len(data)), WithKey(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 原图不存在时以 _thumb 结尾的对象是普通原图
+	if _, err := srcLocal.UploadReader(ctx, bytes.NewReader(data), "c_thumb.png", int64(len(data)), WithKey("c_thumb.png")); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(listKeys(src), ","); got != "a.png,b.png,c_thumb.png" {
+		t.Errorf("expected variants hidden from List, got %s", got)
+	}
+	if err := srcLocal.Delete(ctx, "c_thumb.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 迁移只复制原图，目标存储按配置重新生成缩略图，删除源对象时一并删除源缩略图
+	dstLocal, dst := newVariantUploader()
+	result, err := Migrate(ctx, src, dst, MigrateOptions{DeleteSource: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Migrated != 2 || len(result.Failed) != 0 {
+		t.Fatalf("unexpected migrate result: %+v", result)
+	}
+	if got := strings.Join(listKeys(dstLocal), ","); got != "a.png,a_thumb.png,b.png,b_thumb.png" {
+		t.Errorf("unexpected destination objects: %s", got)
+	}
+	if got := listKeys(srcLocal); len(got) != 0 {
+		t.Errorf("expected source emptied, got %v", got)
+	}
+}
+
+// cancelingUploader 上传缩略图时取消请求并失败，删除时检查 ctx
+type cancelingUploader struct {
+	*LocalUploader
+	cancel context.CancelFunc
+}
+
+func (c *cancelingUploader) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	if key := newUploadOptions(opts).Key; strings.HasSuffix(strings.TrimSuffix(key, ".png"), "_thumb") {
+		c.cancel()
+		return nil, ctx.Err()
+	}
+	return c.LocalUploader.UploadReader(ctx, r, name, size, opts...)
+}
+
+func (c *cancelingUploader) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.LocalUploader.Delete(ctx, key)
+}
+
+func TestVariantCleanupAfterCancel(t *testing.T) {
+	local, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{MaxFileSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	u := NewVariantUploader(&cancelingUploader{LocalUploader: local, cancel: cancel}, []config.ImageVariant{{Name: "thumb", Width: 50}})
+	data := testImage(t, "png", 100, 100)
+	if _, err := u.UploadReader(ctx, bytes.NewReader(data), "a.png", int64(len(data)), WithKey("a.png")); err == nil {
+		t.Fatal("expected variant failure")
+	}
+	// 请求已取消，原图仍被删除
+	if _, err := local.Stat(context.Background(), "a.png"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected original to be deleted, got %v", err)
+	}
+}
+
+func TestVariantDirectUpload(t *testing.T) {
+	local, err := NewLocalUploader(&config.LocalConfig{Path: t.TempDir()}, &config.UploadSettings{MaxFileSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := NewVariantUploader(local, []config.ImageVariant{{Name: "thumb", Width: 50}})
+	ctx := context.Background()
+	presigned, err := u.Presign(ctx, &PresignRequest{Filename: "photo.png"})
+	if err != nil {
+		t.Fatalf("Presign failed: %v", err)
+	}
+	signURL, err := url.Parse(presigned.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 本地直传与普通上传一样生成缩略图
+	data := testImage(t, "png", 100, 100)
+	result, err := UploadWithToken(ctx, u, signURL.Query().Get("token"), bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("UploadWithToken failed: %v", err)
+	}
+	if len(result.Variants) != 1 || result.Variants[0].Key != VariantKey(presigned.Key, "thumb") {
+		t.Fatalf("expected thumb variant, got %+v", result.Variants)
+	}
+	if _, err := local.Stat(ctx, result.Variants[0].Key); err != nil {
+		t.Errorf("expected variant stored: %v", err)
+	}
+}
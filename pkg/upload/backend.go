@@ -0,0 +1,88 @@
+package upload
+
+import (
+	"fmt"
+	"upload-util/internal/service"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Backend 自定义存储后端需要实现的接口，与内置的云存储后端相同
+type Backend = service.Uploader
+
+// BackendConfig 传给后端构造函数的配置，Decode 将 upload.<name> 下的配置解码到后端自己的结构体，
+// Settings 为通用上传配置
+type BackendConfig = service.BackendConfig
+
+// BackendConstructor 根据配置创建存储后端
+type BackendConstructor = service.BackendConstructor
+
+// UploadOptions 单次上传选项的取值
+type UploadOptions = service.UploadOptions
+
+// OpenOptions 读取对象选项的取值
+type OpenOptions = service.OpenOptions
+
+// URLOptions 获取访问地址选项的取值
+type URLOptions = service.URLOptions
+
+// RegisterBackend 注册自定义存储后端，通常在后端所在包的 init 中调用。
+// 注册后配置 upload.type 为 name 即可在服务端、命令行工具和 NewUploader 中使用：
+//
+//	upload:
+//	  type: mystore
+//	  mystore:
+//	    endpoint: https://storage.example.com
+//
+// 名称与已注册的后端重复时 panic
+func RegisterBackend(name string, constructor BackendConstructor) {
+	service.RegisterBackend(name, constructor)
+}
+
+// Backends 返回已注册的后端名称，包括内置后端
+func Backends() []string {
+	return service.Backends()
+}
+
+// WithBackend 使用已注册的自定义存储后端，cfg 为后端的配置结构体，
+// 按 yaml 标签编码后由后端通过 BackendConfig.Decode 读取
+func (b *ConfigBuilder) WithBackend(name string, cfg any) *ConfigBuilder {
+	b.cfg.Upload.Type = name
+	var node yaml.Node
+	if err := node.Encode(cfg); err != nil {
+		panic(fmt.Sprintf("upload: failed to encode %s config: %v", name, err))
+	}
+	if b.cfg.Upload.Backends == nil {
+		b.cfg.Upload.Backends = make(map[string]yaml.Node)
+	}
+	b.cfg.Upload.Backends[name] = node
+	return b
+}
+
+// ApplyUploadOptions 返回上传选项的取值，供自定义存储后端使用
+func ApplyUploadOptions(opts ...UploadOption) *UploadOptions {
+	options := &UploadOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// ApplyOpenOptions 返回读取对象选项的取值，Offset 为负数时表示读取最后 -Offset 个字节，
+// Length 小于等于 0 时读到末尾
+func ApplyOpenOptions(opts ...OpenOption) *OpenOptions {
+	options := &OpenOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// ApplyURLOptions 返回获取访问地址选项的取值
+func ApplyURLOptions(opts ...URLOption) *URLOptions {
+	options := &URLOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
@@ -0,0 +1,96 @@
+package upload
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// prefixBackend 测试用的自定义后端，在本地存储的基础上给访问地址加上配置的前缀
+type prefixBackend struct {
+	Backend
+	prefix string
+}
+
+type prefixBackendConfig struct {
+	Path   string `yaml:"path"`
+	Prefix string `yaml:"prefix"`
+}
+
+func (b *prefixBackend) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	return b.prefix + key, nil
+}
+
+func init() {
+	RegisterBackend("prefix-test", func(cfg *BackendConfig) (Backend, error) {
+		var c prefixBackendConfig
+		if err := cfg.Decode(&c); err != nil {
+			return nil, err
+		}
+		innerCfg := NewConfigBuilder().WithLocal(c.Path, "").Build()
+		innerCfg.UploadSettings = *cfg.Settings
+		inner, err := NewUploader(innerCfg)
+		if err != nil {
+			return nil, err
+		}
+		return &prefixBackend{Backend: inner, prefix: c.Prefix}, nil
+	})
+}
+
+func Test_RegisterBackend(t *testing.T) {
+	if !slices.Contains(Backends(), "prefix-test") || !slices.Contains(Backends(), ProviderAliyun) {
+		t.Fatalf("unexpected backends: %v", Backends())
+	}
+
+	t.Run("ConfigBuilder", func(t *testing.T) {
+		cfg := NewConfigBuilder().
+			WithAllowedExtensions([]string{".txt"}).
+			WithBackend("prefix-test", prefixBackendConfig{Path: t.TempDir(), Prefix: "https://cdn.example.com/"}).
+			Build()
+		uploader, err := NewUploader(cfg)
+		if err != nil {
+			t.Fatalf("NewUploader failed: %v", err)
+		}
+		url, err := uploader.GetURL(context.Background(), "a.txt")
+		if err != nil || url != "https://cdn.example.com/a.txt" {
+			t.Errorf("unexpected url %s: %v", url, err)
+		}
+	})
+
+	t.Run("LoadConfig", func(t *testing.T) {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, "config.yaml")
+		content := "upload:\n  type: prefix-test\n  prefix-test:\n    path: " + dir + "\n    prefix: /files/\n" +
+			"upload-settings:\n  max-file-size: 1\n  allowed-extensions: [.txt]\n"
+		if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfig(configPath)
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		uploader, err := NewUploader(cfg)
+		if err != nil {
+			t.Fatalf("NewUploader failed: %v", err)
+		}
+		result, err := uploader.UploadReader(context.Background(), strings.NewReader("hello"), "a.txt", 5)
+		if err != nil {
+			t.Fatalf("UploadReader failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, result.Key)); err != nil {
+			t.Errorf("expected file in backend path: %v", err)
+		}
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for duplicate backend")
+			}
+		}()
+		RegisterBackend(ProviderAliyun, func(cfg *BackendConfig) (Backend, error) { return nil, nil })
+	})
+}
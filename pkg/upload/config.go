@@ -7,6 +7,38 @@ import (
 // Config 配置类型别名
 type Config = config.UploadConfig
 
+// Profile 命名的上传配置，upload-settings 未设置的字段沿用顶层配置。
+// 通过代码构建时 UploadSettings.KeepOriginalName 为 false 视为未设置，需要关闭时设置 KeepOriginalName
+type Profile = config.ProfileConfig
+
+// RoutingConfig 按规则将上传分发到不同 profile 的配置，通过 WithBackend("routing", cfg) 使用
+type RoutingConfig = config.RoutingConfig
+
+// RoutingRule 路由规则，按大小范围、扩展名、内容类型和键前缀匹配
+type RoutingRule = config.RoutingRule
+
+// MirrorConfig 将每次上传同时写入多个 profile 的配置，通过 WithBackend("mirror", cfg) 使用
+type MirrorConfig = config.MirrorConfig
+
+// FailoverConfig 按顺序在多个 profile 之间故障转移的配置，通过 WithBackend("failover", cfg) 使用
+type FailoverConfig = config.FailoverConfig
+
+// RetryConfig 临时性错误的重试配置，对应 upload-settings.retry
+type RetryConfig = config.RetryConfig
+
+// RateLimitConfig 上传带宽和请求频率限制，对应 upload-settings.rate-limit
+type RateLimitConfig = config.RateLimitConfig
+
+// ImageVariant 上传图片时生成的缩略图配置，对应 upload-settings.variants 中的一项
+type ImageVariant = config.ImageVariant
+
+// mirror 写入策略
+const (
+	WritePolicyAll    = config.WritePolicyAll
+	WritePolicyAsync  = config.WritePolicyAsync
+	WritePolicyQuorum = config.WritePolicyQuorum
+)
+
 // ConfigBuilder 配置构建器
 type ConfigBuilder struct {
 	cfg *config.UploadConfig
@@ -17,10 +49,12 @@ func NewConfigBuilder() *ConfigBuilder {
 	return &ConfigBuilder{
 		cfg: &config.UploadConfig{
 			UploadSettings: config.UploadSettings{
-				MaxFileSize:       100,
-				AllowedExtensions: []string{".jpg", ".jpeg", ".png", ".pdf"},
-				FilenameStrategy:  "uuid",
-				KeepOriginalName:  false,
+				MaxFileSize:        100,
+				AllowedExtensions:  []string{".jpg", ".jpeg", ".png", ".pdf"},
+				FilenameStrategy:   "uuid",
+				KeepOriginalName:   false,
+				MultipartThreshold: 100,
+				PartSize:           8,
 			},
 		},
 	}
@@ -118,6 +152,46 @@ func (b *ConfigBuilder) WithFilenameStrategy(strategy string) *ConfigBuilder {
 	return b
 }
 
+// WithKeyTemplate 设置对象键模板，如 {yyyy}/{mm}/{dd}/{uuid}{ext}
+func (b *ConfigBuilder) WithKeyTemplate(template string) *ConfigBuilder {
+	b.cfg.UploadSettings.KeyTemplate = template
+	return b
+}
+
+// WithMultipart 设置分片上传阈值和分片大小 (MB)
+func (b *ConfigBuilder) WithMultipart(threshold, partSize int64) *ConfigBuilder {
+	b.cfg.UploadSettings.MultipartThreshold = threshold
+	b.cfg.UploadSettings.PartSize = partSize
+	return b
+}
+
+// WithRetry 设置临时性错误的重试次数和退避时间，MaxAttempts 为 1 时关闭重试
+func (b *ConfigBuilder) WithRetry(retry RetryConfig) *ConfigBuilder {
+	b.cfg.UploadSettings.Retry = retry
+	return b
+}
+
+// WithRateLimit 设置上传带宽 (字节/秒) 和请求频率限制，同一上传器的并发上传共享带宽
+func (b *ConfigBuilder) WithRateLimit(rateLimit RateLimitConfig) *ConfigBuilder {
+	b.cfg.UploadSettings.RateLimit = rateLimit
+	return b
+}
+
+// WithVariants 设置上传 JPEG、PNG、GIF 图片时生成的缩略图
+func (b *ConfigBuilder) WithVariants(variants ...ImageVariant) *ConfigBuilder {
+	b.cfg.UploadSettings.Variants = variants
+	return b
+}
+
+// WithProfile 添加命名的上传配置，通过 Config.Profile 取得完整配置
+func (b *ConfigBuilder) WithProfile(name string, profile *Profile) *ConfigBuilder {
+	if b.cfg.Profiles == nil {
+		b.cfg.Profiles = make(map[string]*config.ProfileConfig)
+	}
+	b.cfg.Profiles[name] = profile
+	return b
+}
+
 // Build 构建配置
 func (b *ConfigBuilder) Build() *config.UploadConfig {
 	return b.cfg
@@ -1,9 +1,17 @@
 package upload
 
+import (
+	"upload-util/internal/config"
+	"upload-util/internal/service"
+)
+
 const (
 	FilenameStrategyOriginal  = "original"
 	FilenameStrategyUUID      = "uuid"
 	FilenameStrategyTimestamp = "timestamp"
+	// 内容寻址策略，以内容哈希命名，相同内容只存储一份
+	FilenameStrategySHA256 = "sha256"
+	FilenameStrategyMD5    = "md5"
 )
 
 const (
@@ -19,3 +27,30 @@ const (
 	ProviderAWS     = "aws"
 	ProviderQCloud  = "qcloud"
 )
+
+var (
+	// ErrObjectNotFound 对象不存在，可通过 errors.Is 判断
+	ErrObjectNotFound = service.ErrObjectNotFound
+	// ErrInvalidRange 读取范围超出对象大小
+	ErrInvalidRange = service.ErrInvalidRange
+	// ErrFileTooLarge 文件超过 max-file-size 限制
+	ErrFileTooLarge = service.ErrFileTooLarge
+	// ErrExtensionNotAllowed 文件扩展名不在 allowed-extensions 中
+	ErrExtensionNotAllowed = service.ErrExtensionNotAllowed
+	// ErrContentTypeMismatch 文件内容与扩展名不符
+	ErrContentTypeMismatch = service.ErrContentTypeMismatch
+	// ErrMimeTypeNotAllowed 文件内容类型不在 allowed-mime-types 中
+	ErrMimeTypeNotAllowed = service.ErrMimeTypeNotAllowed
+	// ErrNoRoute 没有路由规则匹配且未配置默认 profile
+	ErrNoRoute = service.ErrNoRoute
+	// ErrQuorumNotReached mirror 写入成功的存储数量未达到要求
+	ErrQuorumNotReached = service.ErrQuorumNotReached
+	// ErrNoHealthyBackend failover 的所有后端都已熔断或请求失败
+	ErrNoHealthyBackend = service.ErrNoHealthyBackend
+	// ErrTooManyParts 分片数超过云存储的 10000 个上限，需要增大 part-size
+	ErrTooManyParts = service.ErrTooManyParts
+	// ErrChecksumMismatch 存储返回的 ETag、CRC64 或迁移后的内容与本地计算的校验和不一致
+	ErrChecksumMismatch = service.ErrChecksumMismatch
+	// ErrProfileNotFound 配置中没有指定的 profile
+	ErrProfileNotFound = config.ErrProfileNotFound
+)
@@ -2,22 +2,137 @@ package upload
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"mime/multipart"
+	"time"
 	"upload-util/internal/config"
 	"upload-util/internal/service"
 )
 
-type UploadResult struct {
-	URL      string `json:"url"`
-	Key      string `json:"key"`
-	Size     int64  `json:"size"`
-	MimeType string `json:"mime_type"`
+// UploadResult 上传结果，包含对象键、访问地址、大小和类型
+type UploadResult = service.UploadResult
+
+// Checksums 上传内容的 MD5、SHA-256 和 CRC64
+type Checksums = service.Checksums
+
+// ETagMD5 ETag 是内容的 MD5 时返回小写的十六进制 MD5，分片上传等情况下返回 false
+func ETagMD5(etag string) (string, bool) {
+	return service.ETagMD5(etag)
+}
+
+// Variant 上传图片时生成的缩略图，包括名称、对象键、访问地址和尺寸
+type Variant = service.Variant
+
+// VariantKey 返回缩略图的对象键，在原图键的扩展名前加上 _名称
+func VariantKey(key, name string) string {
+	return service.VariantKey(key, name)
+}
+
+// PresignRequest 直传签名请求，Method 为 PUT (默认) 或 POST
+type PresignRequest = service.PresignRequest
+
+// PresignResult 客户端直传所需的 URL、请求头和表单字段
+type PresignResult = service.PresignResult
+
+// UploadOption 单次上传的可选参数
+type UploadOption = service.UploadOption
+
+// WithContentType 指定内容类型，默认根据文件扩展名推断
+func WithContentType(contentType string) UploadOption {
+	return service.WithContentType(contentType)
+}
+
+// WithKey 指定完整的对象键 (包含 path-prefix)，不再按文件名策略和 key-template 生成
+func WithKey(key string) UploadOption {
+	return service.WithKey(key)
+}
+
+// WithMetadata 设置对象的自定义元数据，本地存储不保存
+func WithMetadata(metadata map[string]string) UploadOption {
+	return service.WithMetadata(metadata)
+}
+
+// WithBandwidthLimit 限制本次上传的带宽 (字节/秒)，与配置中 rate-limit 的共享带宽同时生效
+func WithBandwidthLimit(bytesPerSecond int64) UploadOption {
+	return service.WithBandwidthLimit(bytesPerSecond)
+}
+
+// Progress 上传进度，包括已发送的字节数、总大小和平均速度
+type Progress = service.Progress
+
+// ProgressFunc 接收上传进度
+type ProgressFunc = service.ProgressFunc
+
+// WithProgress 在上传过程中报告进度，大约每 100ms 调用一次，去重跳过时不会调用
+func WithProgress(fn ProgressFunc) UploadOption {
+	return service.WithProgress(fn)
+}
+
+// WithUser 指定上传者标识，用于 key-template 中的 {user}
+func WithUser(user string) UploadOption {
+	return service.WithUser(user)
+}
+
+// ObjectReader 对象内容及其大小、类型和 ETag，读取完毕后需要关闭
+type ObjectReader = service.ObjectReader
+
+// ObjectInfo 对象的大小、类型、修改时间、ETag 和自定义元数据
+type ObjectInfo = service.ObjectInfo
+
+// ListResult 列举结果，包含对象、按分隔符折叠的公共前缀和下一页游标
+type ListResult = service.ListResult
+
+// DeleteResult 批量删除结果，包含已删除的对象键和逐个对象的错误
+type DeleteResult = service.DeleteResult
+
+// DeleteError 单个对象删除失败的原因
+type DeleteError = service.DeleteError
+
+// OpenOption 读取对象的可选参数
+type OpenOption = service.OpenOption
+
+// WithRange 只读取对象的一部分，length 小于等于 0 时读到末尾，
+// offset 为负数时读取最后 -offset 个字节
+func WithRange(offset, length int64) OpenOption {
+	return service.WithRange(offset, length)
+}
+
+// URLOption 获取访问地址的可选参数
+type URLOption = service.URLOption
+
+// WithExpires 指定签名链接的有效期，仅对私有存储生效，最长 7 天
+func WithExpires(expires time.Duration) URLOption {
+	return service.WithExpires(expires)
 }
 
 type Uploader interface {
 	Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error)
+	// UploadReader 从任意 io.Reader 上传，size 未知时传 -1，以分片方式流式上传
+	UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error)
 	Delete(ctx context.Context, key string) error
-	GetURL(ctx context.Context, key string) (string, error)
+	// DeleteMany 使用存储后端的批量删除接口，每批最多 1000 个，失败的对象记录在 Errors 中
+	DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error)
+	// DeletePrefix 删除前缀下的所有对象，dryRun 时只返回将要删除的对象
+	DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error)
+	// Open 读取对象内容，可通过 WithRange 按范围读取
+	Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error)
+	// Stat 获取对象元数据，对象不存在时返回 ErrObjectNotFound
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Exists 判断对象是否存在
+	Exists(ctx context.Context, key string) (bool, error)
+	// List 按前缀分页列举对象，delimiter 不为空时将其后的部分折叠为公共前缀，
+	// cursor 为上一页的 NextCursor，limit 最大 1000
+	List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error)
+	// Copy 使用存储后端的服务端复制，数据不经过本地
+	Copy(ctx context.Context, src, dst string) error
+	// Move 移动或重命名对象，对象存储上为复制后删除源对象
+	Move(ctx context.Context, src, dst string) error
+	// GetURL 返回对象的访问地址，私有存储返回签名链接，可通过 WithExpires 指定有效期
+	GetURL(ctx context.Context, key string, opts ...URLOption) (string, error)
+	Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error)
+	// Wait 等待后台任务 (如 mirror async 策略的副本复制) 完成，程序退出前调用
+	Wait()
 }
 
 type uploaderWrapper struct {
@@ -25,24 +140,112 @@ type uploaderWrapper struct {
 }
 
 func (w *uploaderWrapper) Upload(ctx context.Context, file multipart.File, header *multipart.FileHeader) (*UploadResult, error) {
-	result, err := w.internal.Upload(ctx, file, header)
-	if err != nil {
-		return nil, err
-	}
-	return &UploadResult{
-		URL:      result.URL,
-		Key:      result.Key,
-		Size:     result.Size,
-		MimeType: result.MimeType,
-	}, nil
+	return w.internal.Upload(ctx, file, header)
+}
+
+func (w *uploaderWrapper) UploadReader(ctx context.Context, r io.Reader, name string, size int64, opts ...UploadOption) (*UploadResult, error) {
+	return w.internal.UploadReader(ctx, r, name, size, opts...)
 }
 
 func (w *uploaderWrapper) Delete(ctx context.Context, key string) error {
 	return w.internal.Delete(ctx, key)
 }
 
-func (w *uploaderWrapper) GetURL(ctx context.Context, key string) (string, error) {
-	return w.internal.GetURL(ctx, key)
+func (w *uploaderWrapper) DeleteMany(ctx context.Context, keys []string) (*DeleteResult, error) {
+	return w.internal.DeleteMany(ctx, keys)
+}
+
+func (w *uploaderWrapper) DeletePrefix(ctx context.Context, prefix string, dryRun bool) (*DeleteResult, error) {
+	return w.internal.DeletePrefix(ctx, prefix, dryRun)
+}
+
+func (w *uploaderWrapper) Open(ctx context.Context, key string, opts ...OpenOption) (*ObjectReader, error) {
+	return w.internal.Open(ctx, key, opts...)
+}
+
+func (w *uploaderWrapper) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	return w.internal.Stat(ctx, key)
+}
+
+func (w *uploaderWrapper) Exists(ctx context.Context, key string) (bool, error) {
+	return service.Exists(ctx, w.internal, key)
+}
+
+func (w *uploaderWrapper) List(ctx context.Context, prefix, delimiter, cursor string, limit int) (*ListResult, error) {
+	return w.internal.List(ctx, prefix, delimiter, cursor, limit)
+}
+
+func (w *uploaderWrapper) Copy(ctx context.Context, src, dst string) error {
+	return w.internal.Copy(ctx, src, dst)
+}
+
+func (w *uploaderWrapper) Move(ctx context.Context, src, dst string) error {
+	return w.internal.Move(ctx, src, dst)
+}
+
+func (w *uploaderWrapper) GetURL(ctx context.Context, key string, opts ...URLOption) (string, error) {
+	return w.internal.GetURL(ctx, key, opts...)
+}
+
+func (w *uploaderWrapper) Presign(ctx context.Context, req *PresignRequest) (*PresignResult, error) {
+	return w.internal.Presign(ctx, req)
+}
+
+func (w *uploaderWrapper) Wait() {
+	service.WaitUploader(w.internal)
+}
+
+// RepairEntry mirror 修复日志中的一条记录
+type RepairEntry = service.RepairEntry
+
+// Repair 按 repair-log 补齐 mirror 副本中缺失或未删除的对象，返回修复成功的数量和仍然失败的记录。
+// 上传器不是 mirror 时返回错误
+func Repair(ctx context.Context, u Uploader) (int, []RepairEntry, error) {
+	w, ok := u.(*uploaderWrapper)
+	if !ok {
+		return 0, nil, fmt.Errorf("uploader does not support repair")
+	}
+	return service.RepairUploader(ctx, w.internal)
+}
+
+// MigrateOptions 迁移参数
+type MigrateOptions = service.MigrateOptions
+
+// MigrateObject 单个对象的迁移结果
+type MigrateObject = service.MigrateObject
+
+// MigrateResult 迁移汇总
+type MigrateResult = service.MigrateResult
+
+// Migrate 将 src 中前缀下的所有对象复制到 dst，保持对象键、内容类型和自定义元数据不变，
+// 写入后比对 MD5，可通过 checkpoint 中断后继续
+func Migrate(ctx context.Context, src, dst Uploader, opts MigrateOptions) (*MigrateResult, error) {
+	return service.Migrate(ctx, unwrapUploader(src), unwrapUploader(dst), opts)
+}
+
+// unwrapUploader 返回包装的内部上传器，其他实现适配为内部接口
+func unwrapUploader(u Uploader) service.Uploader {
+	if w, ok := u.(*uploaderWrapper); ok {
+		return w.internal
+	}
+	return u
+}
+
+// IsRetryable 判断错误是否为存储的临时性错误 (连接重置、超时、5xx、限流)
+func IsRetryable(err error) bool {
+	return service.IsRetryable(err)
+}
+
+// BackendHealth 存储后端的熔断状态
+type BackendHealth = service.BackendHealth
+
+// Health 返回组合上传器中各后端的熔断状态，没有熔断器的上传器返回 nil
+func Health(u Uploader) []BackendHealth {
+	w, ok := u.(*uploaderWrapper)
+	if !ok {
+		return nil
+	}
+	return service.UploaderHealth(w.internal)
 }
 
 func NewUploader(cfg *Config) (Uploader, error) {
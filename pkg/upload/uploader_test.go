@@ -1,6 +1,9 @@
 package upload
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -38,3 +41,34 @@ func Test_NewUploader(t *testing.T) {
 	})
 
 }
+
+func Test_UploadReader(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := NewConfigBuilder().
+		WithLocal(tmpDir, "http://localhost:8080/files").
+		Build()
+	uploader, err := NewUploader(cfg)
+	if err != nil {
+		t.Fatalf("NewUploader failed: %v", err)
+	}
+
+	// 内容需以 JPEG 文件头开始，否则会因与扩展名不符被拒绝
+	result, err := uploader.UploadReader(context.Background(), strings.NewReader("\xff\xd8\xffhello world"), "hello.jpg", -1)
+	if err != nil {
+		t.Fatalf("UploadReader failed: %v", err)
+	}
+	if result.Size != 14 {
+		t.Errorf("Expected size 14, got %d", result.Size)
+	}
+	if result.SniffedType != "image/jpeg" {
+		t.Errorf("Expected sniffed type image/jpeg, got %s", result.SniffedType)
+	}
+
+	_, err = uploader.UploadReader(context.Background(), strings.NewReader("MZ\x90\x00"), "cat.jpg", 4)
+	if !errors.Is(err, ErrContentTypeMismatch) {
+		t.Errorf("Expected content type mismatch, got %v", err)
+	}
+	if !strings.HasPrefix(result.URL, "http://localhost:8080/files/") {
+		t.Errorf("Unexpected URL: %s", result.URL)
+	}
+}
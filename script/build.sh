@@ -16,6 +16,7 @@ declare -a COMMANDS=(
     "cmd/upload:upload-cli"
     "cmd/batch:batch-upload"
     "cmd/interactive:upload-interactive"
+    "cmd/migrate:upload-migrate"
 )
 
 declare -a PLATFORMS=(